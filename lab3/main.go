@@ -1,11 +1,197 @@
+// Command lab3 serves a database of fixed-size records with SimplePIR, and fetches records from it privately
+//
+// Usage:
+//
+//	lab3 serve -db records.bin -record-size 32 [-addr :8080] [-p 991] [-security 128] [-fetches 0] [-batch-window 0] [-matfile db.mat] [-mode lwe]
+//	lab3 query -server http://localhost:8080 -index 7 [-root hex] > record.bin
 package main
 
-import "github.com/yu-val-weiss/p79_cryptography_engineering/lab3/simplepir"
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/yu-val-weiss/p79_cryptography_engineering/lab3/pirhttp"
+	"github.com/yu-val-weiss/p79_cryptography_engineering/lab3/simplepir"
+)
 
 func main() {
-	// simplepir.TestRandFloat()
-	f := func(s simplepir.Sampler) {
-		s.Sample()
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "serve":
+		err = serve(os.Args[2:])
+	case "query":
+		err = query(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %v serve|query [flags]\n", os.Args[0])
+	os.Exit(2)
+}
+
+// serve loads the database file and answers PIR requests over HTTP until killed
+func serve(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	dbPath := fs.String("db", "", "file holding the database, split into records of -record-size bytes")
+	recordSize := fs.Int("record-size", 32, "size of each record in bytes")
+	addr := fs.String("addr", ":8080", "address to listen on")
+	p := fs.Uint64("p", 991, "plaintext modulus")
+	security := fs.Float64("security", 128, "target bit-security, used to choose the LWE parameters")
+	fetches := fs.Int("fetches", 0, "expected fetches per client, used to balance the database shape (0 keeps it square)")
+	window := fs.Duration("batch-window", 0, "how long to collect concurrent queries into one pass over the database (0 answers each alone)")
+	maxBatch := fs.Int("max-batch", 64, "most queries answered in one pass, with -batch-window")
+	matPath := fs.String("matfile", "", "if set, the database matrix is written to this file and served mapped from it rather than from memory")
+	modeName := fs.String("mode", "lwe", "scheme to serve with, lwe or ring")
+	fs.Parse(args)
+	if *dbPath == "" {
+		return fmt.Errorf("serve: -db is required")
+	}
+	mode, err := simplepir.ParseMode(*modeName)
+	if err != nil {
+		return fmt.Errorf("serve: %v", err)
+	}
+	if *window > 0 && mode != simplepir.LWEMode {
+		return fmt.Errorf("serve: -batch-window is only supported in lwe mode")
+	}
+
+	if *recordSize <= 0 {
+		return fmt.Errorf("serve: -record-size must be positive, got %v", *recordSize)
+	}
+	info, err := os.Stat(*dbPath)
+	if err != nil {
+		return fmt.Errorf("could not read database: %v", err)
+	}
+	// the last record is padded, as by SplitRecords
+	numRecords := int((info.Size() + int64(*recordSize) - 1) / int64(*recordSize))
+	plaintext := new(big.Int).SetUint64(*p)
+	layout, err := simplepir.SquareLayout(numRecords, *recordSize, plaintext)
+	if err != nil {
+		return fmt.Errorf("could not load database: %v", err)
+	}
+	params, err := chooseParams(mode, layout, plaintext, *security)
+	if err != nil {
+		return fmt.Errorf("could not choose parameters: %v", err)
+	}
+	if *fetches > 0 {
+		// n does not depend on the shape, so reshape the database for it and check the new shape's correctness
+		if mode == simplepir.RingMode {
+			layout, err = simplepir.ChooseRingLayout(numRecords, *recordSize, plaintext, params.N, *fetches)
+		} else {
+			layout, err = simplepir.ChooseLayout(numRecords, *recordSize, plaintext, params.N, *fetches)
+		}
+		if err != nil {
+			return fmt.Errorf("could not choose layout: %v", err)
+		}
+		if params, err = chooseParams(mode, layout, plaintext, *security); err != nil {
+			return fmt.Errorf("could not choose parameters: %v", err)
+		}
+	}
+
+	var mat *simplepir.Mat
+	if *matPath != "" {
+		// pack the records straight from the -db file into the matrix file and serve them from the mapping, so
+		// neither the records nor the matrix are ever held in memory
+		if err := simplepir.WriteDatabaseFileFrom(*matPath, *dbPath, *recordSize, layout, plaintext); err != nil {
+			return fmt.Errorf("could not write matrix file: %v", err)
+		}
+		mm, err := simplepir.OpenMatFile(*matPath)
+		if err != nil {
+			return err
+		}
+		defer mm.Close()
+		mat = mm.Mat()
+	} else {
+		blob, err := os.ReadFile(*dbPath)
+		if err != nil {
+			return fmt.Errorf("could not read database: %v", err)
+		}
+		records, err := simplepir.SplitRecords(blob, *recordSize)
+		if err != nil {
+			return fmt.Errorf("could not load database: %v", err)
+		}
+		db, err := simplepir.NewDatabaseWithLayout(records, layout, plaintext)
+		if err != nil {
+			return fmt.Errorf("could not load database: %v", err)
+		}
+		mat = db.Mat()
+	}
+	server, err := simplepir.NewModeServer(mode, params, mat)
+	if err != nil {
+		return fmt.Errorf("could not set up server: %v", err)
+	}
+	var handler *pirhttp.Handler
+	if *window > 0 {
+		var batch *simplepir.BatchServer
+		if batch, err = simplepir.NewBatchServer(server.(*simplepir.Server), *window, *maxBatch); err != nil {
+			return fmt.Errorf("could not set up batching: %v", err)
+		}
+		defer batch.Close()
+		handler, err = pirhttp.NewBatchHandler(batch, layout)
+	} else {
+		handler, err = pirhttp.NewHandler(server, layout)
+	}
+	if err != nil {
+		return err
+	}
+
+	rows, cols := params.Shape()
+	log.Printf("serving %v records of %v bytes (%vx%v database, %v mode, n = %v, q = %v) on %v",
+		layout.NumRecords, *recordSize, rows, cols, mode, params.N, params.Q, *addr)
+	if lwe, ok := server.(*simplepir.Server); ok {
+		root := lwe.Commitment().Root()
+		log.Printf("database commitment root %x, pass it to query -root to verify answers", root)
+	}
+	return http.ListenAndServe(*addr, handler)
+}
+
+// chooseParams picks the parameters for a database with the given layout served in mode, see
+// [simplepir.ChooseParamsForLayout] and [simplepir.ChooseRingParams]
+func chooseParams(mode simplepir.Mode, layout simplepir.Layout, p *big.Int, security float64) (simplepir.Params, error) {
+	params, err := simplepir.ChooseParamsForLayout(layout, p, security)
+	if err != nil || mode != simplepir.RingMode {
+		return params, err
+	}
+	return simplepir.ChooseRingParams(params, security)
+}
+
+// query fetches one record privately and writes it to stdout
+func query(args []string) error {
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	url := fs.String("server", "http://localhost:8080", "URL of the PIR server")
+	index := fs.Int("index", 0, "index of the record to fetch")
+	root := fs.String("root", "", "hex Merkle root of the database, if set answers are verified against the server's commitment")
+	fs.Parse(args)
+
+	client, err := pirhttp.NewClient(*url, nil)
+	if err != nil {
+		return err
+	}
+	if *root != "" {
+		if err := client.Verify(); err != nil {
+			return err
+		}
+		if got, _ := client.Root(); hex.EncodeToString(got[:]) != strings.ToLower(*root) {
+			return fmt.Errorf("server committed to root %x, expected %v", got, *root)
+		}
+	}
+	record, err := client.Fetch(*index)
+	if err != nil {
+		return err
 	}
-	f(&simplepir.GaussSampler{})
+	_, err = os.Stdout.Write(record)
+	return err
 }
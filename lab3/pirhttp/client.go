@@ -0,0 +1,225 @@
+package pirhttp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/yu-val-weiss/p79_cryptography_engineering/lab3/simplepir"
+)
+
+// Client fetches records privately from a [Handler]
+//
+// hidden fields, construct with [NewClient]
+type Client struct {
+	url        string
+	http       *http.Client
+	client     simplepir.PIRClient
+	layout     simplepir.Layout
+	commitment *simplepir.Commitment // commitment answers are verified against, nil unless [Client.Verify] was called
+}
+
+// Creates a new [*Client] for the server at url, downloading its parameters and hint
+//
+// httpClient is used for every request, if nil [http.DefaultClient] is used
+//
+// Usage:
+//
+//	client, err := NewClient("http://localhost:8080", nil)
+//	record, err := client.Fetch(i)
+func NewClient(url string, httpClient *http.Client) (*Client, error) {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	c := &Client{url: strings.TrimSuffix(url, "/"), http: httpClient}
+	if err := c.download(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// verifier is implemented by simplepir clients that can check answers against a commitment, such as
+// [simplepir.Client]
+type verifier interface {
+	SetCommitment(commitment *simplepir.Commitment) error
+}
+
+// download fetches the parameters, layout and hint from the server and sets up the simplepir client for the
+// server's mode from them, along with the commitment if the client verifies answers
+func (c *Client) download() error {
+	body, err := c.get("/params")
+	if err != nil {
+		return err
+	}
+	frames, err := readFrames(body, 4)
+	if err != nil {
+		return fmt.Errorf("could not read parameters: %v", err)
+	}
+	if len(frames) != 4 {
+		return fmt.Errorf("could not read parameters: expected params, seed, layout and mode, got %v frames", len(frames))
+	}
+	var params simplepir.Params
+	if err := params.UnmarshalBinary(frames[0]); err != nil {
+		return err
+	}
+	seed := frames[1]
+	if err := c.layout.UnmarshalBinary(frames[2]); err != nil {
+		return err
+	}
+	mode, err := simplepir.ParseMode(string(frames[3]))
+	if err != nil {
+		return err
+	}
+
+	body, err = c.get("/hint")
+	if err != nil {
+		return err
+	}
+	hint := new(simplepir.Mat)
+	if err := hint.UnmarshalBinary(body); err != nil {
+		return err
+	}
+
+	client, err := simplepir.NewModeClient(mode, params, seed, hint)
+	if err != nil {
+		return fmt.Errorf("could not set up client: %v", err)
+	}
+	// a verifying client must never be swapped for one that does not verify
+	if c.commitment != nil {
+		if err := c.downloadCommitment(client); err != nil {
+			return err
+		}
+	}
+	c.client = client
+	return nil
+}
+
+// downloadCommitment fetches the server's commitment and has client check later answers against it
+func (c *Client) downloadCommitment(client simplepir.PIRClient) error {
+	v, ok := client.(verifier)
+	if !ok {
+		return fmt.Errorf("answers cannot be verified in the server's mode")
+	}
+	body, err := c.get("/commitment")
+	if err != nil {
+		return err
+	}
+	commitment := new(simplepir.Commitment)
+	if err := commitment.UnmarshalBinary(body); err != nil {
+		return err
+	}
+	if err := v.SetCommitment(commitment); err != nil {
+		return fmt.Errorf("could not verify against commitment: %w", err)
+	}
+	c.commitment = commitment
+	return nil
+}
+
+// Verify downloads the server's commitment to its database, and from then on checks every answer against it,
+// so that a [Client.Fetch] answered from any other database fails with an error wrapping
+// [simplepir.ErrInconsistentAnswer]
+//
+// The commitment is downloaded again along with the hint when the database changes. Compare [Client.Root] with a
+// root published for the database, or with other clients, to check the server committed to the right one.
+func (c *Client) Verify() error {
+	return c.downloadCommitment(c.client)
+}
+
+// Root returns the Merkle root of the commitment answers are verified against, and false if [Client.Verify]
+// has not been called
+func (c *Client) Root() ([sha256.Size]byte, bool) {
+	if c.commitment == nil {
+		return [sha256.Size]byte{}, false
+	}
+	return c.commitment.Root(), true
+}
+
+// Layout returns the layout of the records held by the server
+func (c *Client) Layout() simplepir.Layout {
+	return c.layout
+}
+
+// Fetch retrieves record i without revealing i to the server
+//
+// If the server reports that the client's hint is stale, the hint is downloaded again and the fetch retried once,
+// if that also fails the error wraps [simplepir.ErrStaleHint]
+func (c *Client) Fetch(i int) ([]byte, error) {
+	record, err := c.fetch(i)
+	if errors.Is(err, simplepir.ErrStaleHint) {
+		if err := c.download(); err != nil {
+			return nil, fmt.Errorf("could not refresh stale hint: %v", err)
+		}
+		record, err = c.fetch(i)
+	}
+	return record, err
+}
+
+// fetch queries the server once for record i
+func (c *Client) fetch(i int) ([]byte, error) {
+	st, queries, err := c.client.QueryRecord(c.layout, i)
+	if err != nil {
+		return nil, err
+	}
+	var body []byte
+	for _, qu := range queries {
+		if body, err = appendFrame(body, qu); err != nil {
+			return nil, fmt.Errorf("could not encode query: %v", err)
+		}
+	}
+
+	resp, err := c.do(http.MethodPost, "/query", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	frames, err := readFrames(resp, len(queries))
+	if err != nil {
+		return nil, fmt.Errorf("could not read answers: %v", err)
+	}
+	answers := make([]*simplepir.Vec, len(frames))
+	for k, frame := range frames {
+		answers[k] = new(simplepir.Vec)
+		if err := answers[k].UnmarshalBinary(frame); err != nil {
+			return nil, fmt.Errorf("answer %v: %v", k, err)
+		}
+	}
+	return c.client.RecoverRecord(st, answers)
+}
+
+// get fetches the body at path
+func (c *Client) get(path string) ([]byte, error) {
+	return c.do(http.MethodGet, path, nil)
+}
+
+// do sends a request to path and returns the response body, turning non-200 responses into errors
+func (c *Client) do(method, path string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequest(method, c.url+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("could not create request: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	if c.client != nil {
+		req.Header.Set(versionHeader, c.client.Version().String())
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not %v %v: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read response to %v %v: %v", method, path, err)
+	}
+	if resp.StatusCode == http.StatusConflict {
+		return nil, fmt.Errorf("%v %v: %w", method, path, simplepir.ErrStaleHint)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%v %v: %v: %v", method, path, resp.Status, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
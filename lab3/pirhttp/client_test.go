@@ -0,0 +1,366 @@
+package pirhttp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yu-val-weiss/p79_cryptography_engineering/lab3/simplepir"
+)
+
+func TestEndToEnd(t *testing.T) {
+	tests := []struct {
+		name       string
+		numRecords int
+		recordSize int
+	}{
+		{"small records", 50, 3},
+		{"records spanning columns", 6, 40},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, records := newTestHandler(t, tt.numRecords, tt.recordSize)
+			ts := httptest.NewServer(handler)
+			defer ts.Close()
+
+			client, err := NewClient(ts.URL+"/", ts.Client())
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			if got := client.Layout().NumRecords; got != tt.numRecords {
+				t.Errorf("Layout().NumRecords = %v, want %v", got, tt.numRecords)
+			}
+			for _, i := range []int{0, tt.numRecords / 2, tt.numRecords - 1} {
+				record, err := client.Fetch(i)
+				if err != nil {
+					t.Fatalf("Fetch(%v) error = %v", i, err)
+				}
+				if !bytes.Equal(record, records[i]) {
+					t.Errorf("Fetch(%v) = %x, want %x", i, record, records[i])
+				}
+			}
+			if _, err := client.Fetch(tt.numRecords); err == nil {
+				t.Errorf("Fetch(%v) succeeded, want error", tt.numRecords)
+			}
+		})
+	}
+}
+
+func TestBatchHandler(t *testing.T) {
+	server, layout, records := newTestServer(t, 30, 6)
+	batch, err := simplepir.NewBatchServer(server, time.Millisecond, 32)
+	if err != nil {
+		t.Fatalf("NewBatchServer() error = %v", err)
+	}
+	handler, err := NewBatchHandler(batch, layout)
+	if err != nil {
+		t.Fatalf("NewBatchHandler() error = %v", err)
+	}
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	// concurrent clients, run with -race to check the handler is safe for concurrent use
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client, err := NewClient(ts.URL, ts.Client())
+			if err != nil {
+				t.Errorf("NewClient() error = %v", err)
+				return
+			}
+			for _, i := range []int{g, 29 - g} {
+				if record, err := client.Fetch(i); err != nil || !bytes.Equal(record, records[i]) {
+					t.Errorf("Fetch(%v) = %x, %v, want %x", i, record, err, records[i])
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	resp, err := ts.Client().Get(ts.URL + "/stats")
+	if err != nil {
+		t.Fatalf("GET /stats error = %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	want := fmt.Sprintf("queries %v\n", batch.Stats().Queries)
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(string(body), want) || batch.Stats().Queries == 0 {
+		t.Errorf("GET /stats = %v %q, want it to start with %q", resp.StatusCode, body, want)
+	}
+
+	// once the batch server is closed, queries are refused rather than left hanging
+	batch.Close()
+	client, err := NewClient(ts.URL, ts.Client())
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	if _, err := client.Fetch(0); err == nil {
+		t.Errorf("Fetch() after Close() succeeded, want error")
+	}
+
+	plain, _ := newTestHandler(t, 30, 6)
+	rec := httptest.NewRecorder()
+	plain.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET /stats without batching status = %v, want %v", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestStaleHint(t *testing.T) {
+	old, _ := newTestHandler(t, 20, 4)
+	current, records := newTestHandler(t, 20, 4)
+	var handler atomic.Value // the http.HandlerFunc currently serving
+	handler.Store(http.HandlerFunc(old.ServeHTTP))
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.Load().(http.HandlerFunc)(w, r)
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(ts.URL, ts.Client())
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	// the server's database changes, so the client must download the new hint before its query is answered
+	handler.Store(http.HandlerFunc(current.ServeHTTP))
+	record, err := client.Fetch(7)
+	if err != nil {
+		t.Fatalf("Fetch() after database change error = %v", err)
+	}
+	if !bytes.Equal(record, records[7]) {
+		t.Errorf("Fetch() = %x, want %x", record, records[7])
+	}
+
+	// a server that always reports the hint as stale gives up after one refresh
+	handler.Store(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/query" {
+			http.Error(w, "stale", http.StatusConflict)
+			return
+		}
+		current.ServeHTTP(w, r)
+	}))
+	if _, err := client.Fetch(7); !errors.Is(err, simplepir.ErrStaleHint) {
+		t.Errorf("Fetch() error = %v, want %v", err, simplepir.ErrStaleHint)
+	}
+}
+
+func TestServerUpdate(t *testing.T) {
+	handler, records := newTestHandler(t, 20, 4)
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+	client, err := NewClient(ts.URL, ts.Client())
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	record := []byte{1, 2, 3, 4}
+	changes, err := client.Layout().Changes(9, record)
+	if err != nil {
+		t.Fatalf("Changes() error = %v", err)
+	}
+	if _, err := handler.server.(*simplepir.Server).UpdateBatch(changes); err != nil {
+		t.Fatalf("UpdateBatch() error = %v", err)
+	}
+
+	// the client's hint is now stale, so it picks up the re-encoded hint before fetching
+	for i, want := range map[int][]byte{8: records[8], 9: record} {
+		got, err := client.Fetch(i)
+		if err != nil {
+			t.Fatalf("Fetch(%v) error = %v", i, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("Fetch(%v) = %x, want %x", i, got, want)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	records := [][]byte{[]byte("honest"), []byte("always"), []byte("trusty"), []byte("record")}
+	newHandler := func(records [][]byte) *Handler {
+		// the same randomness gives both servers the same A, so only their databases differ
+		params := simplepir.Params{N: 64, Q: big.NewInt(1 << 32), P: big.NewInt(991), Backend: simplepir.Uint32Backend, Rand: simplepir.NewPRG([]byte("verify"))}
+		db, err := simplepir.NewDatabase(records, params.P)
+		if err != nil {
+			t.Fatalf("NewDatabase() error = %v", err)
+		}
+		params.SqrtN = db.Layout().SqrtN
+		server, err := simplepir.NewServer(params, db.Mat())
+		if err != nil {
+			t.Fatalf("NewServer() error = %v", err)
+		}
+		handler, err := NewHandler(server, db.Layout())
+		if err != nil {
+			t.Fatalf("NewHandler() error = %v", err)
+		}
+		return handler
+	}
+	honest := newHandler(records)
+	forged := newHandler([][]byte{[]byte("honest"), []byte("always"), []byte("forged"), []byte("record")})
+
+	// the forging server hands out the honest hint and commitment, but answers queries from another database,
+	// ignoring the hint version so as not to give itself away
+	var answerer atomic.Pointer[Handler]
+	answerer.Store(honest)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/query" {
+			if h := answerer.Load(); h != honest {
+				r.Header.Del(versionHeader)
+				h.ServeHTTP(w, r)
+				return
+			}
+			honest.ServeHTTP(w, r)
+			return
+		}
+		honest.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(ts.URL, ts.Client())
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	if _, ok := client.Root(); ok {
+		t.Errorf("Root() before Verify() reports a commitment")
+	}
+	answerer.Store(forged)
+	// without verification, the client accepts whatever the answer decodes to
+	if got, err := client.Fetch(2); err != nil || string(got) == "trusty" {
+		t.Errorf("Fetch() without verification = %q, %v, want a wrong record and no error", got, err)
+	}
+
+	if err := client.Verify(); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if root, ok := client.Root(); !ok || root != honest.server.(*simplepir.Server).Commitment().Root() {
+		t.Errorf("Root() = %x, %v, want %x", root, ok, honest.server.(*simplepir.Server).Commitment().Root())
+	}
+	if _, err := client.Fetch(2); !errors.Is(err, simplepir.ErrInconsistentAnswer) {
+		t.Errorf("Fetch() from forging server error = %v, want %v", err, simplepir.ErrInconsistentAnswer)
+	}
+	answerer.Store(honest)
+	if got, err := client.Fetch(2); err != nil || string(got) != "trusty" {
+		t.Errorf("Fetch() from honest server = %q, %v, want %q", got, err, "trusty")
+	}
+
+	// after an update the client downloads the new commitment along with the new hint
+	changes, _ := client.Layout().Changes(1, []byte("update"))
+	if _, err := honest.server.(*simplepir.Server).UpdateBatch(changes); err != nil {
+		t.Fatalf("UpdateBatch() error = %v", err)
+	}
+	if got, err := client.Fetch(1); err != nil || string(got) != "update" {
+		t.Errorf("Fetch() after update = %q, %v, want %q", got, err, "update")
+	}
+	if root, _ := client.Root(); root != honest.server.(*simplepir.Server).Commitment().Root() {
+		t.Errorf("Root() after update = %x, want %x", root, honest.server.(*simplepir.Server).Commitment().Root())
+	}
+}
+
+func TestNewClientErrors(t *testing.T) {
+	handler, _ := newTestHandler(t, 10, 4)
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"server error", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}},
+		{"garbage params", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte{1, 0, 0, 0, 7})
+		}},
+		{"unknown mode", func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/params" {
+				frames, _ := readFrames(handler.params, 4)
+				var body []byte
+				for _, f := range append(frames[:3], []byte("double")) {
+					body, _ = appendFrame(body, rawBytes(f))
+				}
+				w.Write(body)
+				return
+			}
+			handler.ServeHTTP(w, r)
+		}},
+		{"missing hint", func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/hint" {
+				http.NotFound(w, r)
+				return
+			}
+			handler.ServeHTTP(w, r)
+		}},
+		{"garbage hint", func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/hint" {
+				w.Write([]byte("not a hint"))
+				return
+			}
+			handler.ServeHTTP(w, r)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(tt.handler)
+			defer ts.Close()
+			if _, err := NewClient(ts.URL, ts.Client()); err == nil {
+				t.Errorf("NewClient() succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestRingMode(t *testing.T) {
+	records := make([][]byte, 30)
+	for i := range records {
+		records[i] = make([]byte, 5)
+		rand.Read(records[i])
+	}
+	db, err := simplepir.NewDatabase(records, big.NewInt(991))
+	if err != nil {
+		t.Fatalf("NewDatabase() error = %v", err)
+	}
+	// a ring degree far too small to be secure, so that the test runs quickly
+	params := simplepir.Params{N: 16, Q: big.NewInt(simplepir.RingModulus), P: big.NewInt(991), SqrtN: db.Layout().SqrtN, Backend: simplepir.Uint32Backend}
+	server, err := simplepir.NewRingServer(params, db.Mat())
+	if err != nil {
+		t.Fatalf("NewRingServer() error = %v", err)
+	}
+	handler, err := NewHandler(server, db.Layout())
+	if err != nil {
+		t.Fatalf("NewHandler() error = %v", err)
+	}
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	client, err := NewClient(ts.URL, ts.Client())
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	for i, want := range records {
+		if got, err := client.Fetch(i); err != nil || !bytes.Equal(got, want) {
+			t.Errorf("Fetch(%v) = %x, %v, want %x", i, got, err, want)
+		}
+	}
+	// the ring mode has no commitments to verify against
+	if err := client.Verify(); err == nil {
+		t.Errorf("Verify() in ring mode succeeded, want error")
+	}
+	resp, err := ts.Client().Get(ts.URL + "/commitment")
+	if err != nil {
+		t.Fatalf("GET /commitment error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET /commitment in ring mode = %v, want %v", resp.StatusCode, http.StatusNotFound)
+	}
+}
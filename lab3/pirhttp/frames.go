@@ -0,0 +1,44 @@
+package pirhttp
+
+import (
+	"encoding"
+	"encoding/binary"
+	"fmt"
+)
+
+// appendFrame appends the encoding of v, prefixed by its length as a little-endian uint32
+func appendFrame(b []byte, v encoding.BinaryMarshaler) ([]byte, error) {
+	data, err := v.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(data)))
+	return append(b, data...), nil
+}
+
+// readFrames splits data into the frames written by [appendFrame], rejecting more than max frames or trailing bytes
+func readFrames(data []byte, max int) ([][]byte, error) {
+	var frames [][]byte
+	for len(data) > 0 {
+		if len(frames) == max {
+			return nil, fmt.Errorf("more than %v frames", max)
+		}
+		if len(data) < 4 {
+			return nil, fmt.Errorf("truncated frame length")
+		}
+		n := binary.LittleEndian.Uint32(data)
+		if uint64(n) > uint64(len(data)-4) {
+			return nil, fmt.Errorf("frame of %v bytes exceeds the %v bytes remaining", n, len(data)-4)
+		}
+		frames = append(frames, data[4:4+n])
+		data = data[4+n:]
+	}
+	return frames, nil
+}
+
+// rawBytes adapts a byte slice, such as the seed of A, to [encoding.BinaryMarshaler]
+type rawBytes []byte
+
+func (r rawBytes) MarshalBinary() ([]byte, error) {
+	return r, nil
+}
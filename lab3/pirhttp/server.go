@@ -0,0 +1,249 @@
+// Package pirhttp serves a SimplePIR database over HTTP, and fetches records from it privately
+//
+// The server exposes four endpoints, with every body in the binary wire format of the simplepir package:
+//
+//   - GET /params returns frames holding the [simplepir.Params], the seed of A, the [simplepir.Layout] and the
+//     [simplepir.Mode] the server runs, as text
+//   - GET /hint returns the hint hintC as a [simplepir.Mat]
+//   - GET /commitment returns the [simplepir.Commitment] to the database, for clients that verify answers, only
+//     served by modes with commitments
+//   - POST /query takes frames holding query vectors and returns frames holding the answers, in the same order
+//
+// where each frame is a little-endian uint32 length followed by that many bytes. A handler built with
+// [NewBatchHandler] also serves GET /stats, the load on its [simplepir.BatchServer] as plain text.
+//
+// Clients may send the [simplepir.HintVersion] of their hint in the X-Hint-Version header of a query. If it does not
+// match the server's hint, the server answers 409 Conflict rather than an answer the client would decode wrongly,
+// and the client should download the hint again.
+package pirhttp
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/yu-val-weiss/p79_cryptography_engineering/lab3/simplepir"
+)
+
+// versionHeader carries the hex [simplepir.HintVersion] of the client's hint on /query requests
+const versionHeader = "X-Hint-Version"
+
+// frameOverhead bounds the bytes a frame adds on top of the entries of an encoded vector
+const frameOverhead = 64
+
+// Handler answers PIR requests for a [simplepir.PIRServer] holding a [simplepir.Database], in either mode
+//
+// hidden fields, construct with [NewHandler]
+type Handler struct {
+	server     simplepir.PIRServer
+	params     []byte // encoded response to /params
+	mu         sync.Mutex
+	hint       []byte                 // encoded response to /hint, guarded by mu
+	version    simplepir.HintVersion  // version of the encoded hint, guarded by mu
+	maxQueries int                    // queries needed for one record
+	maxBody    int64                  // size limit for /query bodies
+	batch      *simplepir.BatchServer // answers queries if set, see [NewBatchHandler]
+	mux        *http.ServeMux
+}
+
+// committer is implemented by servers whose answers clients can verify, such as [simplepir.Server]
+type committer interface {
+	Commitment() *simplepir.Commitment
+}
+
+// Creates a new [*Handler] serving server, whose database is laid out as described by layout
+//
+// The parameters and hint are encoded once up front, as every client downloads them, and the hint is encoded
+// again after the server's database is updated.
+//
+// Usage:
+//
+//	db, err := simplepir.NewDatabase(records, params.P)
+//	server, err := simplepir.NewServer(params, db.Mat())
+//	handler, err := NewHandler(server, db.Layout())
+//	http.ListenAndServe(addr, handler)
+//
+// server may be a [*simplepir.Server], a [*simplepir.RingServer] or any other [simplepir.PIRServer]. GET /commitment
+// is only served if it also has a Commitment method as [simplepir.Server] does.
+func NewHandler(server simplepir.PIRServer, layout simplepir.Layout) (*Handler, error) {
+	params := server.Params()
+	rows, cols := layout.Shape()
+	if pRows, pCols := params.Shape(); rows != pRows || cols != pCols {
+		return nil, fmt.Errorf("layout is for a %vx%v database, server holds %vx%v", rows, cols, pRows, pCols)
+	}
+	recordCols, err := layout.Columns(0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid layout: %v", err)
+	}
+
+	var paramsBody []byte
+	mode := rawBytes(server.Mode().String())
+	for _, v := range []encoding.BinaryMarshaler{params, rawBytes(server.Seed()), layout, mode} {
+		if paramsBody, err = appendFrame(paramsBody, v); err != nil {
+			return nil, fmt.Errorf("could not encode parameters: %v", err)
+		}
+	}
+	entryBytes := (params.Q.BitLen() + 7) / 8
+	h := &Handler{
+		server:     server,
+		params:     paramsBody,
+		maxQueries: len(recordCols),
+		maxBody:    int64(len(recordCols) * (frameOverhead + server.Mode().QuerySize(params)*entryBytes)),
+		mux:        http.NewServeMux(),
+	}
+	h.mux.HandleFunc("GET /params", h.serveBytes(h.params))
+	if _, err := h.encodedHint(); err != nil {
+		return nil, err
+	}
+	h.mux.HandleFunc("GET /hint", h.serveHint)
+	if _, ok := server.(committer); ok {
+		h.mux.HandleFunc("GET /commitment", h.serveCommitment)
+	}
+	h.mux.HandleFunc("POST /query", h.serveQuery)
+	return h, nil
+}
+
+// Creates a new [*Handler] answering queries through batch, so that queries from concurrent requests share
+// passes over the database, see [NewHandler]
+//
+// The handler also serves GET /stats. The caller still owns batch and must close it once the handler is done.
+func NewBatchHandler(batch *simplepir.BatchServer, layout simplepir.Layout) (*Handler, error) {
+	h, err := NewHandler(batch.Server(), layout)
+	if err != nil {
+		return nil, err
+	}
+	h.batch = batch
+	h.mux.HandleFunc("GET /stats", h.serveStats)
+	return h, nil
+}
+
+// ServeHTTP dispatches to the /params, /hint, /commitment and /query endpoints
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// serveBytes returns a handler writing a fixed, pre-encoded body
+func (h *Handler) serveBytes(body []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(body)
+	}
+}
+
+// encodedHint returns the encoding of the server's current hint, re-encoding it only if it has changed
+func (h *Handler) encodedHint() ([]byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if version := h.server.Version(); h.hint == nil || version != h.version {
+		hint, err := h.server.Hint().MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("could not encode hint: %v", err)
+		}
+		h.hint, h.version = hint, version
+	}
+	return h.hint, nil
+}
+
+// serveHint writes the server's current hint
+func (h *Handler) serveHint(w http.ResponseWriter, r *http.Request) {
+	hint, err := h.encodedHint()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.serveBytes(hint)(w, r)
+}
+
+// serveCommitment writes the commitment to the server's current database
+func (h *Handler) serveCommitment(w http.ResponseWriter, r *http.Request) {
+	body, err := h.server.(committer).Commitment().MarshalBinary()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("could not encode commitment: %v", err), http.StatusInternalServerError)
+		return
+	}
+	h.serveBytes(body)(w, r)
+}
+
+// serveQuery answers each query vector in the request body
+func (h *Handler) serveQuery(w http.ResponseWriter, r *http.Request) {
+	if header := r.Header.Get(versionHeader); header != "" {
+		v, err := simplepir.ParseHintVersion(header)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := h.server.CheckVersion(v); errors.Is(err, simplepir.ErrStaleHint) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxBody))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("could not read queries: %v", err), http.StatusRequestEntityTooLarge)
+		return
+	}
+	frames, err := readFrames(body, h.maxQueries)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("could not read queries: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(frames) == 0 {
+		http.Error(w, "no queries in request", http.StatusBadRequest)
+		return
+	}
+
+	qus := make([]*simplepir.Vec, len(frames))
+	for k, frame := range frames {
+		qus[k] = new(simplepir.Vec)
+		if err := qus[k].UnmarshalBinary(frame); err != nil {
+			http.Error(w, fmt.Sprintf("query %v: %v", k, err), http.StatusBadRequest)
+			return
+		}
+	}
+	answers, err := h.answer(qus)
+	if errors.Is(err, simplepir.ErrClosed) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var resp []byte
+	for k, ans := range answers {
+		if resp, err = appendFrame(resp, ans); err != nil {
+			http.Error(w, fmt.Sprintf("could not encode answer %v: %v", k, err), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(resp)
+}
+
+// answer answers each of qus, all in the same batch if the handler has a batch server
+func (h *Handler) answer(qus []*simplepir.Vec) ([]*simplepir.Vec, error) {
+	if h.batch != nil {
+		return h.batch.AnswerEach(qus)
+	}
+	answers := make([]*simplepir.Vec, len(qus))
+	for k, qu := range qus {
+		ans, err := h.server.Answer(qu)
+		if err != nil {
+			return nil, fmt.Errorf("query %v: %v", k, err)
+		}
+		answers[k] = ans
+	}
+	return answers, nil
+}
+
+// serveStats writes the load on the batch server, one "name value" pair per line
+func (h *Handler) serveStats(w http.ResponseWriter, r *http.Request) {
+	stats := h.batch.Stats()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "queries %v\nrejected %v\nbatches %v\nmean_batch %.2f\nqps %.2f\nbusy_seconds %.3f\nuptime_seconds %.3f\n",
+		stats.Queries, stats.Rejected, stats.Batches, stats.MeanBatch(), stats.QPS(),
+		stats.Busy.Seconds(), stats.Elapsed.Seconds())
+}
@@ -0,0 +1,137 @@
+package pirhttp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/yu-val-weiss/p79_cryptography_engineering/lab3/simplepir"
+)
+
+// newTestServer sets up a server for numRecords random records of recordSize bytes, small enough for the tests to
+// run quickly
+func newTestServer(t *testing.T, numRecords, recordSize int) (*simplepir.Server, simplepir.Layout, [][]byte) {
+	t.Helper()
+	records := make([][]byte, numRecords)
+	for i := range records {
+		records[i] = make([]byte, recordSize)
+		rand.Read(records[i])
+	}
+	params := simplepir.Params{N: 64, Q: new(big.Int).Lsh(big.NewInt(1), 32), P: big.NewInt(991), Backend: simplepir.Uint32Backend}
+	db, err := simplepir.NewDatabase(records, params.P)
+	if err != nil {
+		t.Fatalf("NewDatabase() error = %v", err)
+	}
+	params.SqrtN = db.Layout().SqrtN
+	server, err := simplepir.NewServer(params, db.Mat())
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	return server, db.Layout(), records
+}
+
+// newTestHandler serves numRecords random records of recordSize bytes, see [newTestServer]
+func newTestHandler(t *testing.T, numRecords, recordSize int) (*Handler, [][]byte) {
+	t.Helper()
+	server, layout, records := newTestServer(t, numRecords, recordSize)
+	handler, err := NewHandler(server, layout)
+	if err != nil {
+		t.Fatalf("NewHandler() error = %v", err)
+	}
+	return handler, records
+}
+
+func TestNewHandlerLayoutMismatch(t *testing.T) {
+	handler, _ := newTestHandler(t, 10, 4)
+	layout := simplepir.Layout{NumRecords: 1, RecordSize: 1, BitsPerCell: 8, SqrtN: handler.server.Params().SqrtN + 1}
+	if _, err := NewHandler(handler.server, layout); err == nil {
+		t.Errorf("NewHandler() with mismatched layout succeeded, want error")
+	}
+}
+
+func TestHandlerErrors(t *testing.T) {
+	handler, _ := newTestHandler(t, 10, 4)
+	sqrtN := handler.server.Params().SqrtN
+
+	frame := func(v *simplepir.Vec) []byte {
+		b, err := appendFrame(nil, v)
+		if err != nil {
+			t.Fatalf("appendFrame() error = %v", err)
+		}
+		return b
+	}
+	query := frame(simplepir.NewVecWith(sqrtN, simplepir.Uint32Backend))
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		body    []byte
+		version string
+		status  int
+	}{
+		{"params", http.MethodGet, "/params", nil, "", http.StatusOK},
+		{"hint", http.MethodGet, "/hint", nil, "", http.StatusOK},
+		{"query", http.MethodPost, "/query", query, "", http.StatusOK},
+		{"unknown path", http.MethodGet, "/records", nil, "", http.StatusNotFound},
+		{"get query", http.MethodGet, "/query", nil, "", http.StatusMethodNotAllowed},
+		{"post hint", http.MethodPost, "/hint", nil, "", http.StatusMethodNotAllowed},
+		{"empty query", http.MethodPost, "/query", nil, "", http.StatusBadRequest},
+		{"truncated frame", http.MethodPost, "/query", query[:len(query)-1], "", http.StatusBadRequest},
+		{"not a vector", http.MethodPost, "/query", append([]byte{3, 0, 0, 0}, "abc"...), "", http.StatusBadRequest},
+		{"wrong size", http.MethodPost, "/query", frame(simplepir.NewVecWith(sqrtN+1, simplepir.Uint32Backend)), "", http.StatusBadRequest},
+		{"too many queries", http.MethodPost, "/query", append(bytes.Clone(query), query...), "", http.StatusBadRequest},
+		{"body too large", http.MethodPost, "/query", make([]byte, handler.maxBody+1), "", http.StatusRequestEntityTooLarge},
+		{"current version", http.MethodPost, "/query", query, handler.server.Version().String(), http.StatusOK},
+		{"stale version", http.MethodPost, "/query", query, simplepir.HintVersion{}.String(), http.StatusConflict},
+		{"malformed version", http.MethodPost, "/query", query, "v1", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, bytes.NewReader(tt.body))
+			if tt.version != "" {
+				req.Header.Set(versionHeader, tt.version)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Errorf("%v %v status = %v, want %v (body %q)", tt.method, tt.path, rec.Code, tt.status, rec.Body)
+			}
+		})
+	}
+}
+
+func TestReadFrames(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		max     int
+		frames  int
+		wantErr bool
+	}{
+		{"empty", nil, 1, 0, false},
+		{"one frame", []byte{1, 0, 0, 0, 7}, 1, 1, false},
+		{"empty frame", []byte{0, 0, 0, 0}, 1, 1, false},
+		{"two frames", []byte{1, 0, 0, 0, 7, 2, 0, 0, 0, 8, 9}, 2, 2, false},
+		{"too many frames", []byte{1, 0, 0, 0, 7, 2, 0, 0, 0, 8, 9}, 1, 0, true},
+		{"truncated length", []byte{1, 0}, 1, 0, true},
+		{"truncated frame", []byte{2, 0, 0, 0, 7}, 1, 0, true},
+		{"huge length", []byte{0xff, 0xff, 0xff, 0xff, 7}, 1, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frames, err := readFrames(tt.data, tt.max)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readFrames() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(frames) != tt.frames {
+				t.Errorf("readFrames() returned %v frames, want %v", len(frames), tt.frames)
+			}
+		})
+	}
+}
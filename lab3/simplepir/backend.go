@@ -0,0 +1,102 @@
+package simplepir
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// Backend selects how the entries of a [Mat] or [Vec] are stored
+type Backend int
+
+const (
+	// BigBackend stores every entry as a [*big.Int], which works for any modulus
+	BigBackend Backend = iota
+	// Uint32Backend packs the entries into a []uint32, which requires a modulus of at most 2^32
+	//
+	// With q = 2^32, as in the Simple PIR paper, arithmetic is native wrapping uint32 arithmetic,
+	// smaller moduli fall back to uint64 intermediates reduced after every operation
+	Uint32Backend
+)
+
+// String returns the name of the backend
+func (b Backend) String() string {
+	switch b {
+	case BigBackend:
+		return "big"
+	case Uint32Backend:
+		return "uint32"
+	default:
+		return fmt.Sprintf("Backend(%d)", int(b))
+	}
+}
+
+// checkBackend returns an error if b is not a known [Backend]
+func checkBackend(b Backend) error {
+	if b != BigBackend && b != Uint32Backend {
+		return fmt.Errorf("unknown backend %v", b)
+	}
+	return nil
+}
+
+// checkMod returns an error if mod cannot be used as the modulus for arithmetic with backend b
+func checkMod(b Backend, mod *big.Int) error {
+	if mod == nil || mod.Sign() <= 0 {
+		return fmt.Errorf("modulus must be positive, got %v", mod)
+	}
+	if b == Uint32Backend && mod.Cmp(two32) > 0 {
+		return fmt.Errorf("uint32 backend requires modulus in [1, 2^32], got %v", mod)
+	}
+	return nil
+}
+
+// reduce sets x to x mod m in [0, m) for positive m, and returns x
+//
+// quo holds the quotient and is reused between calls, which unlike [big.Int.Mod] allocates nothing once x and quo
+// have grown to size
+func reduce(x, quo, m *big.Int) *big.Int {
+	quo.QuoRem(x, m, x)
+	if x.Sign() < 0 {
+		x.Add(x, m)
+	}
+	return x
+}
+
+// two32 is 2^32, the native modulus of [Uint32Backend]
+var two32 = new(big.Int).Lsh(big.NewInt(1), 32)
+
+// uint32Mod converts mod to a uint64 for use with [Uint32Backend]
+//
+// wrap is true when mod = 2^32, in which case native uint32 arithmetic can be used
+func uint32Mod(mod *big.Int) (m uint64, wrap bool) {
+	if mod.Sign() <= 0 || mod.Cmp(two32) > 0 {
+		panic(fmt.Sprintf("uint32 backend requires modulus in [1, 2^32], got %v", mod))
+	}
+	return mod.Uint64(), mod.Cmp(two32) == 0
+}
+
+// randUint32s fills dst with uniformly random values in [0, max) read from r, where max ≤ 2^32
+func randUint32s(r io.Reader, dst []uint32, max *big.Int) {
+	_, wrap := uint32Mod(max)
+	if wrap {
+		// every 32-bit string is a valid sample, so read them directly,
+		// big-endian to match [uniformFrom] so both backends expand a seed to the same values
+		buf := make([]byte, 4*len(dst))
+		if _, err := io.ReadFull(r, buf); err != nil {
+			panic(err)
+		}
+		for i := range dst {
+			dst[i] = binary.BigEndian.Uint32(buf[4*i:])
+		}
+		return
+	}
+	for i := range dst {
+		dst[i] = uint32(uniformFrom(r, max).Uint64())
+	}
+}
+
+// toUint32 reduces x modulo 2^32, the representation used by [Uint32Backend]
+func toUint32(x *big.Int) uint32 {
+	return uint32(new(big.Int).Mod(x, two32).Uint64())
+}
@@ -0,0 +1,137 @@
+package simplepir
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func TestBackendString(t *testing.T) {
+	tests := []struct {
+		backend  Backend
+		expected string
+	}{
+		{BigBackend, "big"},
+		{Uint32Backend, "uint32"},
+		{Backend(5), "Backend(5)"},
+	}
+	for _, tt := range tests {
+		if got := tt.backend.String(); got != tt.expected {
+			t.Errorf("String() = %v, want %v", got, tt.expected)
+		}
+	}
+}
+
+func TestUint32Mod(t *testing.T) {
+	m, wrap := uint32Mod(big.NewInt(1 << 32))
+	if m != 1<<32 || !wrap {
+		t.Errorf("uint32Mod(2^32) = (%v, %v), want (%v, true)", m, wrap, uint64(1<<32))
+	}
+	m, wrap = uint32Mod(big.NewInt(97))
+	if m != 97 || wrap {
+		t.Errorf("uint32Mod(97) = (%v, %v), want (97, false)", m, wrap)
+	}
+	assertPanic(t, func() { uint32Mod(big.NewInt(1<<32 + 1)) }, "expected panic for modulus above 2^32")
+	assertPanic(t, func() { uint32Mod(big.NewInt(0)) }, "expected panic for zero modulus")
+}
+
+func TestGetSet(t *testing.T) { forEachBackend(t, testGetSet) }
+
+func testGetSet(t *testing.T, backend Backend) {
+	m := NewMatWith(2, 3, backend).Set(1, 2, big.NewInt(42))
+	if got := m.Get(1, 2); got.Int64() != 42 {
+		t.Errorf("Mat.Get(1, 2) = %v, want 42", got)
+	}
+	// Get must return a copy
+	m.Get(1, 2).SetInt64(7)
+	if got := m.Get(1, 2); got.Int64() != 42 {
+		t.Errorf("Mat.Get(1, 2) = %v after modifying returned value, want 42", got)
+	}
+	if m.Rows() != 2 || m.Cols() != 3 || m.Backend() != backend {
+		t.Errorf("Mat has rows=%v cols=%v backend=%v, want 2, 3, %v", m.Rows(), m.Cols(), m.Backend(), backend)
+	}
+
+	v := NewVecWith(3, backend).Set(0, big.NewInt(9))
+	if got := v.Get(0); got.Int64() != 9 {
+		t.Errorf("Vec.Get(0) = %v, want 9", got)
+	}
+	if v.Size() != 3 || v.Backend() != backend {
+		t.Errorf("Vec has size=%v backend=%v, want 3, %v", v.Size(), v.Backend(), backend)
+	}
+}
+
+func TestUint32Wrapping(t *testing.T) {
+	// with q = 2^32 the uint32 backend relies on wrapping arithmetic, which must agree with math/big
+	q := big.NewInt(1 << 32)
+	mBig := NewMat(4, 6).FillRandom(q)
+	m2Big := NewMat(6, 5).FillRandom(q)
+	vBig := NewVec(6).FillRandom(q)
+	wBig := NewVec(6).FillRandom(q)
+	scale := big.NewInt(0xdeadbeef)
+
+	mU := mBig.as(Uint32Backend)
+	m2U := m2Big.as(Uint32Backend)
+	vU := vBig.as(Uint32Backend)
+	wU := wBig.as(Uint32Backend)
+
+	if got, want := mU.MatMul(m2U, q).as(BigBackend), mBig.MatMul(m2Big, q); !reflect.DeepEqual(got, want) {
+		t.Errorf("MatMul() = %v, want %v", got, want)
+	}
+	if got, want := mU.VecMul(vU, q).as(BigBackend), mBig.VecMul(vBig, q); !reflect.DeepEqual(got, want) {
+		t.Errorf("VecMul() = %v, want %v", got, want)
+	}
+	if got, want := vU.Add(wU, q).as(BigBackend), vBig.Add(wBig, q); !reflect.DeepEqual(got, want) {
+		t.Errorf("Add() = %v, want %v", got, want)
+	}
+	if got, want := vU.Sub(wU, q).as(BigBackend), vBig.Sub(wBig, q); !reflect.DeepEqual(got, want) {
+		t.Errorf("Sub() = %v, want %v", got, want)
+	}
+	if got, want := vU.Scale(scale, q).as(BigBackend), vBig.Scale(scale, q); !reflect.DeepEqual(got, want) {
+		t.Errorf("Scale() = %v, want %v", got, want)
+	}
+}
+
+func TestMixedBackends(t *testing.T) {
+	mod := big.NewInt(7)
+	m := NewMat(2, 2).Fill([]int64{1, 2, 3, 4})
+	v := NewVecWith(2, Uint32Backend).Fill([]int64{5, 6})
+
+	// result takes the backend of the receiver
+	result := m.VecMul(v, mod)
+	if result.backend != BigBackend {
+		t.Errorf("VecMul() backend = %v, want %v", result.backend, BigBackend)
+	}
+	if expected := NewVec(2).Fill([]int64{3, 4}); !reflect.DeepEqual(result, expected) {
+		t.Errorf("VecMul() = %v, want %v", result, expected)
+	}
+
+	sum := v.Add(NewVec(2).Fill([]int64{1, 1}), mod)
+	if expected := NewVecWith(2, Uint32Backend).Fill([]int64{6, 0}); !reflect.DeepEqual(sum, expected) {
+		t.Errorf("Add() = %v, want %v", sum, expected)
+	}
+}
+
+func TestUint32NegativeFill(t *testing.T) {
+	// negative values are stored modulo 2^32
+	v := NewVecWith(1, Uint32Backend).Fill([]int64{-1})
+	if got := v.Get(0); got.Uint64() != 1<<32-1 {
+		t.Errorf("Fill(-1) stored %v, want %v", got, uint64(1<<32-1))
+	}
+	v.Set(0, big.NewInt(-2))
+	if got := v.Get(0); got.Uint64() != 1<<32-2 {
+		t.Errorf("Set(-2) stored %v, want %v", got, uint64(1<<32-2))
+	}
+}
+
+func BenchmarkVecMul(b *testing.B) {
+	q := big.NewInt(1 << 32)
+	for _, backend := range []Backend{BigBackend, Uint32Backend} {
+		b.Run(backend.String(), func(b *testing.B) {
+			m := NewMatWith(256, 256, backend).FillRandom(q)
+			v := NewVecWith(256, backend).FillRandom(q)
+			for b.Loop() {
+				m.VecMul(v, q)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package simplepir
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrClosed is returned when a query is sent to a [BatchServer] after [BatchServer.Close]
+var ErrClosed = errors.New("batch server is closed")
+
+// BatchServer answers queries from many goroutines at once, collecting the queries that arrive within a short
+// window and answering them with a single pass over the database
+//
+// Answering a query costs a pass over the whole database whatever else is going on, so under load it is far cheaper
+// to answer a batch of k queries as db·[qu_1 … qu_k] with [Server.AnswerBatch] than to make k passes. Each query
+// waits at most the window for others to join its batch. Every query in a batch is answered from the same
+// version of the database, even if it is updated meanwhile.
+//
+// hidden fields, construct with [NewBatchServer]. Safe for concurrent use.
+type BatchServer struct {
+	server   *Server
+	window   time.Duration
+	maxBatch int
+	requests chan batchRequest
+	done     chan struct{} // closed by [BatchServer.Close]
+	closing  sync.Once
+	stopped  chan struct{} // closed once the batching goroutine has exited
+	start    time.Time
+	queries  atomic.Uint64
+	rejected atomic.Uint64
+	batches  atomic.Uint64
+	busy     atomic.Int64 // nanoseconds spent answering batches
+}
+
+// batchRequest is a query waiting to be answered, the answer is sent on reply
+type batchRequest struct {
+	qu    *Vec
+	reply chan batchReply
+}
+
+type batchReply struct {
+	ans *Vec
+	err error
+}
+
+// BatchStats is a snapshot of the load on a [BatchServer]
+type BatchStats struct {
+	Queries  uint64        // queries answered
+	Rejected uint64        // malformed queries, which get an error rather than an answer
+	Batches  uint64        // passes over the database
+	Busy     time.Duration // time spent answering batches
+	Elapsed  time.Duration // time since the server was created
+}
+
+// Creates a new [*BatchServer] answering queries for server
+//
+// A batch is answered once window has passed since its first query arrived, or once it holds maxBatch queries.
+// Larger windows give larger batches under load at the cost of latency, and a window of 0 still batches the
+// queries already waiting when a batch starts. Call [BatchServer.Close] to stop the batching goroutine.
+func NewBatchServer(server *Server, window time.Duration, maxBatch int) (*BatchServer, error) {
+	if server == nil {
+		return nil, fmt.Errorf("server must not be nil")
+	}
+	if window < 0 {
+		return nil, fmt.Errorf("window must not be negative, got %v", window)
+	}
+	if maxBatch <= 0 {
+		return nil, fmt.Errorf("batches must hold at least one query, got %v", maxBatch)
+	}
+	b := &BatchServer{
+		server:   server,
+		window:   window,
+		maxBatch: maxBatch,
+		requests: make(chan batchRequest, maxBatch),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+		start:    time.Now(),
+	}
+	go b.run()
+	return b, nil
+}
+
+// Server returns the server the queries are answered by, e.g. for its parameters and hint
+func (b *BatchServer) Server() *Server {
+	return b.server
+}
+
+// Answer responds to a query vector qu produced by [Client.Query], as [Server.Answer] does
+//
+// Blocks until the batch holding qu has been answered, returning an error wrapping [ErrClosed] if the server is
+// closed first
+func (b *BatchServer) Answer(qu *Vec) (*Vec, error) {
+	answers, err := b.AnswerEach([]*Vec{qu})
+	if err != nil {
+		return nil, err
+	}
+	return answers[0], nil
+}
+
+// AnswerEach responds to each of qus, e.g. the queries for one record from [Client.QueryRecord]
+//
+// The queries are sent together, so they usually share a batch. Returns the first error, with the index of its query.
+func (b *BatchServer) AnswerEach(qus []*Vec) ([]*Vec, error) {
+	replies := make([]chan batchReply, len(qus))
+	for k, qu := range qus {
+		replies[k] = make(chan batchReply, 1)
+		select {
+		case b.requests <- batchRequest{qu: qu, reply: replies[k]}:
+		case <-b.done:
+			return nil, ErrClosed
+		}
+	}
+	answers := make([]*Vec, len(qus))
+	var firstErr error
+	for k, reply := range replies {
+		select {
+		case r := <-reply:
+			if r.err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("query %v: %w", k, r.err)
+			}
+			answers[k] = r.ans
+		case <-b.stopped:
+			return nil, ErrClosed
+		}
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return answers, nil
+}
+
+// Stats returns the load on the server so far
+func (b *BatchServer) Stats() BatchStats {
+	return BatchStats{
+		Queries:  b.queries.Load(),
+		Rejected: b.rejected.Load(),
+		Batches:  b.batches.Load(),
+		Busy:     time.Duration(b.busy.Load()),
+		Elapsed:  time.Since(b.start),
+	}
+}
+
+// Close stops the server, queries still waiting for a batch get an error wrapping [ErrClosed]
+//
+// Waits for the batch being answered, if any, to finish
+func (b *BatchServer) Close() {
+	b.closing.Do(func() { close(b.done) })
+	<-b.stopped
+}
+
+// run collects queries into batches and answers them until the server is closed
+func (b *BatchServer) run() {
+	defer close(b.stopped)
+	for {
+		var batch []batchRequest
+		select {
+		case req := <-b.requests:
+			batch = append(batch, req)
+		case <-b.done:
+			return
+		}
+		timer := time.NewTimer(b.window)
+	collect:
+		for len(batch) < b.maxBatch {
+			// take the queries already waiting before checking the timer, so a window of 0 still batches them
+			select {
+			case req := <-b.requests:
+				batch = append(batch, req)
+				continue
+			default:
+			}
+			select {
+			case req := <-b.requests:
+				batch = append(batch, req)
+			case <-timer.C:
+				break collect
+			case <-b.done:
+				timer.Stop()
+				return
+			}
+		}
+		timer.Stop()
+		b.answer(batch)
+	}
+}
+
+// answer answers every query in batch, rejecting malformed queries individually so that they cannot spoil the batch
+func (b *BatchServer) answer(batch []batchRequest) {
+	start := time.Now()
+	params := b.server.Params()
+	_, cols := params.Shape()
+	valid := make([]batchRequest, 0, len(batch))
+	for _, req := range batch {
+		if err := req.qu.checkOperand(); err != nil || req.qu.size != cols {
+			b.rejected.Add(1)
+			req.reply <- batchReply{err: fmt.Errorf("query must be a vector of size %v", cols)}
+			continue
+		}
+		valid = append(valid, req)
+	}
+	if len(valid) == 0 {
+		return
+	}
+
+	qus := NewMatWith(cols, len(valid), params.Backend)
+	for k, req := range valid {
+		qus.setCol(k, req.qu)
+	}
+	ans, err := b.server.AnswerBatch(qus)
+	for k, req := range valid {
+		if err != nil {
+			req.reply <- batchReply{err: err}
+		} else {
+			req.reply <- batchReply{ans: ans.col(k)}
+		}
+	}
+	if err == nil {
+		b.queries.Add(uint64(len(valid)))
+		b.batches.Add(1)
+	}
+	b.busy.Add(int64(time.Since(start)))
+}
+
+// QPS returns the mean number of queries answered per second since the server was created
+func (s BatchStats) QPS() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Queries) / s.Elapsed.Seconds()
+}
+
+// QPSSince returns the number of queries answered per second between an earlier snapshot prev and s
+func (s BatchStats) QPSSince(prev BatchStats) float64 {
+	if s.Elapsed <= prev.Elapsed {
+		return 0
+	}
+	return float64(s.Queries-prev.Queries) / (s.Elapsed - prev.Elapsed).Seconds()
+}
+
+// MeanBatch returns the mean number of queries per batch
+func (s BatchStats) MeanBatch() float64 {
+	if s.Batches == 0 {
+		return 0
+	}
+	return float64(s.Queries) / float64(s.Batches)
+}
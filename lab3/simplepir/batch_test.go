@@ -0,0 +1,211 @@
+package simplepir
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newBatchTestServer returns a server for a random 16x16 database, and a client for it
+func newBatchTestServer(t testing.TB, backend Backend) (*Server, *Client, *Mat) {
+	t.Helper()
+	params := Params{N: 32, Q: big.NewInt(1 << 32), P: big.NewInt(991), SqrtN: 16, Backend: backend}
+	db := NewMatWith(16, 16, backend).FillRandom(params.P)
+	server, err := NewServer(params, db)
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	client, err := NewClientFromSeed(params, server.Seed(), server.Hint())
+	if err != nil {
+		t.Fatalf("NewClientFromSeed() error = %v", err)
+	}
+	return server, client, db
+}
+
+func TestBatchServer(t *testing.T) { forEachBackend(t, testBatchServer) }
+
+func testBatchServer(t *testing.T, backend Backend) {
+	server, client, db := newBatchTestServer(t, backend)
+	batch, err := NewBatchServer(server, 2*time.Millisecond, 16)
+	if err != nil {
+		t.Fatalf("NewBatchServer() error = %v", err)
+	}
+	defer batch.Close()
+
+	// many goroutines query at once, run with -race to check the server is safe for concurrent use. They share the
+	// one client, as its methods only read its fields
+	const goroutines, perGoroutine = 32, 4
+	var wg sync.WaitGroup
+	for g := range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := range perGoroutine {
+				row, col := (g+k)%16, (3*g+k)%16
+				st, qu, err := client.Query(row, col)
+				if err != nil {
+					t.Errorf("Query() error = %v", err)
+					return
+				}
+				ans, err := batch.Answer(qu)
+				if err != nil {
+					t.Errorf("Answer() error = %v", err)
+					return
+				}
+				if got, err := client.Recover(st, ans); err != nil || got != db.Get(row, col).Uint64() {
+					t.Errorf("Recover() at (%v, %v) = %v, %v, want %v", row, col, got, err, db.Get(row, col))
+				}
+			}
+		}()
+	}
+	// metrics are read while the queries are being answered
+	stop := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				batch.Stats().QPS()
+			}
+		}
+	}()
+	wg.Wait()
+	close(stop)
+
+	stats := batch.Stats()
+	if stats.Queries != goroutines*perGoroutine || stats.Rejected != 0 {
+		t.Errorf("Stats() = %+v, want %v queries and none rejected", stats, goroutines*perGoroutine)
+	}
+	if stats.Batches == 0 || stats.Batches > stats.Queries {
+		t.Errorf("Stats() = %+v, want between 1 and %v batches", stats, stats.Queries)
+	}
+}
+
+func TestBatchServerBatching(t *testing.T) {
+	server, client, db := newBatchTestServer(t, Uint32Backend)
+	// the window is long enough that queries sent together always share a batch
+	batch, err := NewBatchServer(server, time.Second, 4)
+	if err != nil {
+		t.Fatalf("NewBatchServer() error = %v", err)
+	}
+	defer batch.Close()
+
+	cells := []Cell{{0, 1}, {2, 3}, {4, 5}, {15, 15}}
+	states := make([]*QueryState, len(cells))
+	qus := make([]*Vec, len(cells))
+	for k, c := range cells {
+		states[k], qus[k], _ = client.Query(c.Row, c.Col)
+	}
+	answers, err := batch.AnswerEach(qus)
+	if err != nil {
+		t.Fatalf("AnswerEach() error = %v", err)
+	}
+	for k, c := range cells {
+		if got, err := client.Recover(states[k], answers[k]); err != nil || got != db.Get(c.Row, c.Col).Uint64() {
+			t.Errorf("Recover() at %v = %v, %v, want %v", c, got, err, db.Get(c.Row, c.Col))
+		}
+	}
+	if stats := batch.Stats(); stats.Queries != 4 || stats.Batches != 1 || stats.MeanBatch() != 4 {
+		t.Errorf("Stats() = %+v, want 4 queries in 1 batch", stats)
+	}
+
+	// a malformed query is rejected on its own, and the valid query batched with it is still answered
+	_, valid, _ := client.Query(1, 1)
+	_, err = batch.AnswerEach([]*Vec{valid, NewVecWith(3, Uint32Backend), &Vec{size: 16}, valid})
+	if err == nil {
+		t.Errorf("AnswerEach() with malformed queries succeeded, want error")
+	}
+	if stats := batch.Stats(); stats.Rejected != 2 || stats.Queries != 6 {
+		t.Errorf("Stats() = %+v, want 2 rejected and 6 answered", stats)
+	}
+	if _, err := batch.Answer(nil); err == nil {
+		t.Errorf("Answer(nil) succeeded, want error")
+	}
+
+	batch.Close()
+	batch.Close()
+	if _, err := batch.Answer(valid); !errors.Is(err, ErrClosed) {
+		t.Errorf("Answer() after Close() error = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestNewBatchServer(t *testing.T) {
+	server, _, _ := newBatchTestServer(t, Uint32Backend)
+	tests := []struct {
+		name     string
+		server   *Server
+		window   time.Duration
+		maxBatch int
+		wantErr  bool
+	}{
+		{"valid", server, time.Millisecond, 8, false},
+		{"no window", server, 0, 1, false},
+		{"nil server", nil, time.Millisecond, 8, true},
+		{"negative window", server, -time.Millisecond, 8, true},
+		{"empty batches", server, time.Millisecond, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batch, err := NewBatchServer(tt.server, tt.window, tt.maxBatch)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewBatchServer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if batch != nil {
+				batch.Close()
+			}
+		})
+	}
+}
+
+func TestBatchStats(t *testing.T) {
+	prev := BatchStats{Queries: 100, Batches: 10, Elapsed: 2 * time.Second}
+	stats := BatchStats{Queries: 400, Batches: 20, Elapsed: 5 * time.Second}
+	if got := stats.QPS(); got != 80 {
+		t.Errorf("QPS() = %v, want 80", got)
+	}
+	if got := stats.QPSSince(prev); got != 100 {
+		t.Errorf("QPSSince() = %v, want 100", got)
+	}
+	if got := stats.MeanBatch(); got != 20 {
+		t.Errorf("MeanBatch() = %v, want 20", got)
+	}
+	if got := (BatchStats{}).QPS() + (BatchStats{}).MeanBatch() + prev.QPSSince(stats); got != 0 {
+		t.Errorf("empty stats give %v, want 0", got)
+	}
+}
+
+// BenchmarkBatchServer compares answering concurrent queries one at a time against batching them, on a 2^20 entry
+// database, reporting the throughput
+func BenchmarkBatchServer(b *testing.B) {
+	params := Params{N: 1024, Q: big.NewInt(1 << 32), P: big.NewInt(256), SqrtN: 1024, Backend: Uint32Backend, Workers: 1}
+	server, err := NewServer(params, NewMatWith(1024, 1024, Uint32Backend).FillRandom(params.P))
+	if err != nil {
+		b.Fatalf("NewServer() error = %v", err)
+	}
+	qu := NewVecWith(1024, Uint32Backend).FillRandom(params.Q)
+
+	b.Run("separate", func(b *testing.B) {
+		start := time.Now()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				server.Answer(qu)
+			}
+		})
+		b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "queries/s")
+	})
+	b.Run("batched", func(b *testing.B) {
+		batch, _ := NewBatchServer(server, time.Millisecond, 64)
+		defer batch.Close()
+		b.SetParallelism(16)
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				batch.Answer(qu)
+			}
+		})
+		b.ReportMetric(batch.Stats().QPS(), "queries/s")
+		b.ReportMetric(batch.Stats().MeanBatch(), "queries/batch")
+	})
+}
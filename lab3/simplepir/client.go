@@ -0,0 +1,245 @@
+package simplepir
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// ErrLowMargin is returned when a recovered value came within [Params.SafetyBand] of being rounded the wrong way,
+// so the noise may have already pushed it over and the value cannot be trusted
+var ErrLowMargin = errors.New("noise margin is inside the safety band")
+
+// Client builds queries and recovers answers, using the public matrix A and hintC from a [Server]
+//
+// hidden fields, construct with [NewClient]
+type Client struct {
+	params     Params
+	a          *Mat
+	hint       *Mat
+	version    HintVersion
+	commitment *Commitment // set by [Client.SetCommitment] to verify answers
+}
+
+// Creates a new [*Client] from the public matrix A and the hint hintC downloaded from the server
+func NewClient(params Params, A, hint *Mat) (*Client, error) {
+	if err := params.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %v", err)
+	}
+	rows, cols := params.Shape()
+	if A == nil || A.rows != cols || A.cols != params.N {
+		return nil, fmt.Errorf("public matrix A must be a %vx%v matrix", cols, params.N)
+	}
+	if hint == nil || hint.rows != rows || hint.cols != params.N {
+		return nil, fmt.Errorf("hint must be a %vx%v matrix", rows, params.N)
+	}
+	hint = hint.as(params.Backend)
+	return &Client{params: params, a: A.as(params.Backend), hint: hint, version: hintVersion(hint, params)}, nil
+}
+
+// Creates a new [*Client] from the seed of the public matrix A and the hint hintC downloaded from the server
+//
+// A is regenerated locally with [ExpandA], so only the short seed needs to be downloaded instead of A itself
+func NewClientFromSeed(params Params, seed []byte, hint *Mat) (*Client, error) {
+	A, err := ExpandA(seed, params)
+	if err != nil {
+		return nil, fmt.Errorf("could not expand A: %v", err)
+	}
+	return NewClient(params, A, hint)
+}
+
+// Params returns the parameters the client was set up with
+func (c *Client) Params() Params {
+	return c.params
+}
+
+// Version returns the version of the client's hint, which the server can check with [Server.CheckVersion]
+func (c *Client) Version() HintVersion {
+	return c.version
+}
+
+// Query builds a query for the database entry at (row, col)
+//
+// Returns the state needed by [Client.Recover], which must be kept secret, and the query vector to send to the server
+func (c *Client) Query(row, col int) (*QueryState, *Vec, error) {
+	if err := checkIndex(row, col, c.params); err != nil {
+		return nil, nil, err
+	}
+	st, qu := pirQuery(row, col, c.a, c.params)
+	return &st, qu, nil
+}
+
+// Recover extracts the database entry from the server's answer ans, using the state st returned by [Client.Query]
+//
+// The returned value lies in Z_p
+func (c *Client) Recover(st *QueryState, ans *Vec) (uint64, error) {
+	val, _, err := c.RecoverWithMargin(st, ans)
+	return val, err
+}
+
+// RecoverWithMargin is [Client.Recover], but also returns the noise margin, the distance from the decrypted value
+// to the nearest rounding threshold
+//
+// A margin of 0 means the noise came right up to the threshold, so a wrong value and a right one look alike.
+// Noise-free values have a margin of ⌊(Δ-1)/2⌋. Returns an error wrapping [ErrLowMargin] if the margin is
+// below Params.SafetyBand, and one wrapping [ErrInconsistentAnswer] if the answer fails verification
+// (see [Client.SetCommitment]).
+func (c *Client) RecoverWithMargin(st *QueryState, ans *Vec) (uint64, *big.Int, error) {
+	if st == nil || st.s == nil || st.s.size != c.params.N {
+		return 0, nil, fmt.Errorf("query state does not match client parameters")
+	}
+	if rows, _ := c.params.Shape(); ans == nil || ans.size != rows {
+		return 0, nil, fmt.Errorf("answer must be a vector of size %v", rows)
+	}
+	var val uint64
+	var margin *big.Int
+	if c.commitment == nil {
+		val, margin = pirRecover(ans, *st, c.hint, c.params)
+	} else {
+		column, margins := pirRecoverColumn(ans, *st, c.hint, c.params)
+		if err := c.checkColumn(st.col, column); err != nil {
+			return 0, nil, err
+		}
+		val, margin = column[st.row], margins[st.row]
+	}
+	if err := c.params.checkMargin(margin); err != nil {
+		return 0, nil, err
+	}
+	return val, margin, nil
+}
+
+// RecordState is the client-side state kept between querying for a whole record and recovering it
+//
+// Fields are hidden as they include the secrets used to build each query
+type RecordState struct {
+	layout     Layout
+	cells      []Cell
+	cols       []int
+	states     []QueryState     // one per column, for a [Client]
+	ringStates []RingQueryState // one per column, for a [RingClient]
+}
+
+// newRecordState checks that layout describes a database under params and returns the state for record i,
+// without the per-column query states
+func newRecordState(layout Layout, i int, params Params) (*RecordState, error) {
+	if err := layout.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid layout: %v", err)
+	}
+	if err := layout.checkShape(params); err != nil {
+		return nil, err
+	}
+	if layout.BitsPerCell >= params.P.BitLen() {
+		return nil, fmt.Errorf("layout stores %v bits per cell, which does not fit in Z_p for p = %v", layout.BitsPerCell, params.P)
+	}
+	cells, err := layout.Cells(i)
+	if err != nil {
+		return nil, err
+	}
+	cols, _ := layout.Columns(i)
+	return &RecordState{layout: layout, cells: cells, cols: cols}, nil
+}
+
+// QueryRecord builds the queries for record i of a [Database] with the given layout
+//
+// Returns one query vector per column the record spans, every record spans the same number of columns
+// so the count reveals nothing about i. Send each query to the server, and pass the answers, in the same order,
+// to [Client.RecoverRecord].
+func (c *Client) QueryRecord(layout Layout, i int) (*RecordState, []*Vec, error) {
+	st, err := newRecordState(layout, i, c.params)
+	if err != nil {
+		return nil, nil, err
+	}
+	st.states = make([]QueryState, len(st.cols))
+	queries := make([]*Vec, len(st.cols))
+	for k, col := range st.cols {
+		st.states[k], queries[k] = pirQuery(0, col, c.a, c.params)
+	}
+	return st, queries, nil
+}
+
+// RecoverRecord rebuilds a record from the server's answers to the queries returned by [Client.QueryRecord]
+//
+// Returns an error wrapping [ErrLowMargin] if any of the record's cells has a margin below Params.SafetyBand,
+// and one wrapping [ErrInconsistentAnswer] if any answer fails verification
+func (c *Client) RecoverRecord(st *RecordState, answers []*Vec) ([]byte, error) {
+	if st == nil || len(st.states) != len(st.cols) {
+		return nil, fmt.Errorf("record state was not made by this client's QueryRecord")
+	}
+	if len(answers) != len(st.cols) {
+		return nil, fmt.Errorf("expected %v answers, got %v", len(st.cols), len(answers))
+	}
+
+	columns := make(map[int][]uint64, len(st.cols))
+	margins := make(map[int][]*big.Int, len(st.cols))
+	for k, ans := range answers {
+		if rows, _ := c.params.Shape(); ans == nil || ans.size != rows {
+			return nil, fmt.Errorf("answer %v must be a vector of size %v", k, rows)
+		}
+		columns[st.cols[k]], margins[st.cols[k]] = pirRecoverColumn(ans, st.states[k], c.hint, c.params)
+		if err := c.checkColumn(st.cols[k], columns[st.cols[k]]); err != nil {
+			return nil, err
+		}
+	}
+
+	values := make([]uint64, len(st.cells))
+	for k, cell := range st.cells {
+		// the rest of the column belongs to other records, so only the record's own cells need to be trusted
+		if err := c.params.checkMargin(margins[cell.Col][cell.Row]); err != nil {
+			return nil, fmt.Errorf("cell %v: %w", cell, err)
+		}
+		values[k] = columns[cell.Col][cell.Row]
+	}
+	return st.layout.decode(values), nil
+}
+
+// BatchState is the client-side state kept between issuing a batch of queries and recovering the answers
+//
+// Fields are hidden as they include the secrets used to build each query
+type BatchState struct {
+	states []QueryState
+}
+
+// QueryBatch builds queries for every database entry in indices, to be answered together by [Server.AnswerBatch]
+//
+// Returns the state needed by [Client.RecoverBatch], which must be kept secret, and a matrix with one query per column
+func (c *Client) QueryBatch(indices []Cell) (*BatchState, *Mat, error) {
+	if len(indices) == 0 {
+		return nil, nil, fmt.Errorf("batch must contain at least one index")
+	}
+	for _, idx := range indices {
+		if err := checkIndex(idx.Row, idx.Col, c.params); err != nil {
+			return nil, nil, err
+		}
+	}
+	states, qus := pirQueryBatch(indices, c.a, c.params)
+	return &BatchState{states: states}, qus, nil
+}
+
+// RecoverBatch extracts every database entry in the batch from the server's answer, in the order they were queried
+//
+// Returns an error wrapping [ErrLowMargin] if any entry has a margin below Params.SafetyBand,
+// and one wrapping [ErrInconsistentAnswer] if any answer fails verification
+func (c *Client) RecoverBatch(st *BatchState, ans *Mat) ([]uint64, error) {
+	if st == nil || len(st.states) == 0 {
+		return nil, fmt.Errorf("batch state must not be empty")
+	}
+	if rows, _ := c.params.Shape(); ans == nil || ans.rows != rows || ans.cols != len(st.states) {
+		return nil, fmt.Errorf("answer must be a %vx%v matrix", rows, len(st.states))
+	}
+	if c.commitment != nil {
+		// each query reveals a whole column, but the batched recovery only decodes the queried entries
+		for k, qst := range st.states {
+			column, _ := pirRecoverColumn(ans.col(k), qst, c.hint, c.params)
+			if err := c.checkColumn(qst.col, column); err != nil {
+				return nil, fmt.Errorf("entry %v: %w", k, err)
+			}
+		}
+	}
+	values, margins := pirRecoverBatch(ans, st.states, c.hint, c.params)
+	for k, margin := range margins {
+		if err := c.params.checkMargin(margin); err != nil {
+			return nil, fmt.Errorf("entry %v: %w", k, err)
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,537 @@
+package simplepir
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+func TestClientServerProtocol(t *testing.T) { forEachBackend(t, testClientServerProtocol) }
+
+func testClientServerProtocol(t *testing.T, backend Backend) {
+	testCases := []struct {
+		name  string
+		n     int
+		sqrtN int
+		p     int64
+	}{
+		{"n smaller than sqrtN", 4, 8, 2},
+		{"n equal to sqrtN", 8, 8, 2},
+		{"n larger than sqrtN", 32, 8, 2},
+		{"byte entries", 16, 8, 256},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			params := Params{N: tc.n, Q: big.NewInt(1 << 32), P: big.NewInt(tc.p), SqrtN: tc.sqrtN, Backend: backend}
+			db := NewMatWith(tc.sqrtN, tc.sqrtN, backend).FillRandom(params.P)
+
+			server, err := NewServer(params, db)
+			if err != nil {
+				t.Fatalf("NewServer() error = %v", err)
+			}
+			client, err := NewClientFromSeed(params, server.Seed(), server.Hint())
+			if err != nil {
+				t.Fatalf("NewClientFromSeed() error = %v", err)
+			}
+
+			for row := range tc.sqrtN {
+				for col := range tc.sqrtN {
+					t.Run(fmt.Sprintf("Position_%d_%d", row, col), func(t *testing.T) {
+						st, qu, err := client.Query(row, col)
+						if err != nil {
+							t.Fatalf("Query() error = %v", err)
+						}
+						ans, err := server.Answer(qu)
+						if err != nil {
+							t.Fatalf("Answer() error = %v", err)
+						}
+						result, err := client.Recover(st, ans)
+						if err != nil {
+							t.Fatalf("Recover() error = %v", err)
+						}
+						expected := db.Get(row, col).Uint64()
+						if result != expected {
+							t.Errorf("Recover() at (%d,%d) = %v, want %v", row, col, result, expected)
+						}
+					})
+				}
+			}
+		})
+	}
+}
+
+func TestRectangularProtocol(t *testing.T) { forEachBackend(t, testRectangularProtocol) }
+
+func testRectangularProtocol(t *testing.T, backend Backend) {
+	testCases := []struct {
+		name       string
+		rows, cols int
+	}{
+		{"tall", 16, 4},
+		{"wide", 3, 20},
+		{"single row", 1, 9},
+		{"single column", 9, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			params := Params{N: 16, Q: big.NewInt(1 << 32), P: big.NewInt(256), Rows: tc.rows, Cols: tc.cols, Backend: backend}
+			db := NewMatWith(tc.rows, tc.cols, backend).FillRandom(params.P)
+			server, err := NewServer(params, db)
+			if err != nil {
+				t.Fatalf("NewServer() error = %v", err)
+			}
+			if server.Hint().rows != tc.rows || server.A().rows != tc.cols {
+				t.Errorf("hint has %v rows and A %v, want %v and %v", server.Hint().rows, server.A().rows, tc.rows, tc.cols)
+			}
+			client, err := NewClientFromSeed(params, server.Seed(), server.Hint())
+			if err != nil {
+				t.Fatalf("NewClientFromSeed() error = %v", err)
+			}
+
+			var indices []Cell
+			for row := range tc.rows {
+				for col := range tc.cols {
+					indices = append(indices, Cell{row, col})
+					st, qu, err := client.Query(row, col)
+					if err != nil {
+						t.Fatalf("Query() error = %v", err)
+					}
+					if qu.size != tc.cols {
+						t.Fatalf("query has size %v, want %v", qu.size, tc.cols)
+					}
+					ans, err := server.Answer(qu)
+					if err != nil {
+						t.Fatalf("Answer() error = %v", err)
+					}
+					if got, err := client.Recover(st, ans); err != nil || got != db.Get(row, col).Uint64() {
+						t.Errorf("Recover() at (%d,%d) = %v, %v, want %v", row, col, got, err, db.Get(row, col))
+					}
+				}
+			}
+
+			st, qus, err := client.QueryBatch(indices)
+			if err != nil {
+				t.Fatalf("QueryBatch() error = %v", err)
+			}
+			ans, err := server.AnswerBatch(qus)
+			if err != nil {
+				t.Fatalf("AnswerBatch() error = %v", err)
+			}
+			results, err := client.RecoverBatch(st, ans)
+			if err != nil {
+				t.Fatalf("RecoverBatch() error = %v", err)
+			}
+			for k, idx := range indices {
+				if want := db.Get(idx.Row, idx.Col).Uint64(); results[k] != want {
+					t.Errorf("RecoverBatch() at (%d,%d) = %v, want %v", idx.Row, idx.Col, results[k], want)
+				}
+			}
+
+			if _, _, err := client.Query(tc.rows, 0); err == nil {
+				t.Errorf("Query() with row out of range succeeded, want error")
+			}
+			if _, _, err := client.Query(0, tc.cols); err == nil {
+				t.Errorf("Query() with column out of range succeeded, want error")
+			}
+			if _, err := NewServer(params, NewMatWith(tc.cols, tc.rows, backend)); err == nil {
+				t.Errorf("NewServer() with transposed database succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestRectangularRecords(t *testing.T) { forEachBackend(t, testRectangularRecords) }
+
+func testRectangularRecords(t *testing.T, backend Backend) {
+	records := make([][]byte, 40)
+	for i := range records {
+		records[i] = make([]byte, 6)
+		rand.Read(records[i])
+	}
+	p := big.NewInt(256)
+	for _, fetches := range []int{1, 100} {
+		t.Run(fmt.Sprint(fetches), func(t *testing.T) {
+			layout, err := ChooseLayout(len(records), 6, p, 16, fetches)
+			if err != nil {
+				t.Fatalf("ChooseLayout() error = %v", err)
+			}
+			db, err := NewDatabaseWithLayout(records, layout, p)
+			if err != nil {
+				t.Fatalf("NewDatabaseWithLayout() error = %v", err)
+			}
+			params := Params{N: 16, Q: big.NewInt(1 << 32), P: p, Backend: backend}
+			params.Rows, params.Cols = layout.Shape()
+			server, err := NewServer(params, db.Mat())
+			if err != nil {
+				t.Fatalf("NewServer() error = %v", err)
+			}
+			client, err := NewClientFromSeed(params, server.Seed(), server.Hint())
+			if err != nil {
+				t.Fatalf("NewClientFromSeed() error = %v", err)
+			}
+			for _, i := range []int{0, 17, len(records) - 1} {
+				st, queries, err := client.QueryRecord(layout, i)
+				if err != nil {
+					t.Fatalf("QueryRecord(%d) error = %v", i, err)
+				}
+				answers := make([]*Vec, len(queries))
+				for k, qu := range queries {
+					if answers[k], err = server.Answer(qu); err != nil {
+						t.Fatalf("Answer() error = %v", err)
+					}
+				}
+				if got, err := client.RecoverRecord(st, answers); err != nil || !bytes.Equal(got, records[i]) {
+					t.Errorf("RecoverRecord(%d) = %x, %v, want %x", i, got, err, records[i])
+				}
+			}
+
+			square, _ := newLayout(len(records), 6, p)
+			if _, _, err := client.QueryRecord(square, 0); err == nil && layout.SqrtN == 0 {
+				t.Errorf("QueryRecord() with a layout of another shape succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	params := Params{N: 16, Q: big.NewInt(1 << 16), P: big.NewInt(2), SqrtN: 8}
+	A := NewMat(8, 16)
+	hint := NewMat(8, 16)
+
+	if _, err := NewClient(params, A, hint); err != nil {
+		t.Errorf("NewClient() error = %v", err)
+	}
+	if _, err := NewClient(params, NewMat(16, 8), hint); err == nil {
+		t.Error("expected error for wrong shape of A")
+	}
+	if _, err := NewClient(params, A, NewMat(8, 8)); err == nil {
+		t.Error("expected error for wrong shape of hint")
+	}
+	if _, err := NewClient(params, nil, hint); err == nil {
+		t.Error("expected error for nil A")
+	}
+	bad := params
+	bad.Q = nil
+	if _, err := NewClient(bad, A, hint); err == nil {
+		t.Error("expected error for invalid params")
+	}
+	if _, err := NewClientFromSeed(params, []byte{1, 2, 3}, hint); err == nil {
+		t.Error("expected error for short seed")
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	params := Params{N: 16, Q: big.NewInt(1 << 16), P: big.NewInt(2), SqrtN: 8}
+	client, err := NewClient(params, NewMat(8, 16), NewMat(8, 16))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	for _, idx := range []struct{ row, col int }{{-1, 0}, {0, -1}, {8, 0}, {0, 8}} {
+		if _, _, err := client.Query(idx.row, idx.col); err == nil {
+			t.Errorf("Query(%d, %d) expected out of range error", idx.row, idx.col)
+		}
+	}
+
+	st, _, err := client.Query(0, 0)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if _, err := client.Recover(st, NewVec(4)); err == nil {
+		t.Error("expected error for wrong answer size")
+	}
+	if _, err := client.Recover(nil, NewVec(8)); err == nil {
+		t.Error("expected error for nil state")
+	}
+	if _, err := client.Recover(&QueryState{}, NewVec(8)); err == nil {
+		t.Error("expected error for empty state")
+	}
+}
+
+func TestClientRecordRetrieval(t *testing.T) { forEachBackend(t, testClientRecordRetrieval) }
+
+func testClientRecordRetrieval(t *testing.T, backend Backend) {
+	testCases := []struct {
+		name       string
+		numRecords int
+		recordSize int
+		p          int64
+	}{
+		{"byte cells", 20, 4, 256},
+		{"bit cells", 6, 3, 2},
+		{"records spanning columns", 3, 24, 256},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			records := make([][]byte, tc.numRecords)
+			for i := range records {
+				records[i] = make([]byte, tc.recordSize)
+				rand.Read(records[i])
+			}
+			db, err := NewDatabase(records, big.NewInt(tc.p))
+			if err != nil {
+				t.Fatalf("NewDatabase() error = %v", err)
+			}
+			params := Params{N: 16, Q: big.NewInt(1 << 32), P: big.NewInt(tc.p), SqrtN: db.Layout().SqrtN, Backend: backend}
+
+			server, err := NewServer(params, db.Mat())
+			if err != nil {
+				t.Fatalf("NewServer() error = %v", err)
+			}
+			client, err := NewClient(params, server.A(), server.Hint())
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+
+			for i, record := range records {
+				st, queries, err := client.QueryRecord(db.Layout(), i)
+				if err != nil {
+					t.Fatalf("QueryRecord(%d) error = %v", i, err)
+				}
+				answers := make([]*Vec, len(queries))
+				for k, qu := range queries {
+					if answers[k], err = server.Answer(qu); err != nil {
+						t.Fatalf("Answer() error = %v", err)
+					}
+				}
+				got, err := client.RecoverRecord(st, answers)
+				if err != nil {
+					t.Fatalf("RecoverRecord(%d) error = %v", i, err)
+				}
+				if !bytes.Equal(got, record) {
+					t.Errorf("RecoverRecord(%d) = %x, want %x", i, got, record)
+				}
+			}
+		})
+	}
+}
+
+func TestClientRecordErrors(t *testing.T) {
+	db, err := NewDatabase([][]byte{{1}, {2}, {3}, {4}}, big.NewInt(256))
+	if err != nil {
+		t.Fatalf("NewDatabase() error = %v", err)
+	}
+	layout := db.Layout()
+	params := Params{N: 8, Q: big.NewInt(1 << 32), P: big.NewInt(256), SqrtN: layout.SqrtN}
+	client, err := NewClient(params, NewMat(layout.SqrtN, 8), NewMat(layout.SqrtN, 8))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	if _, _, err := client.QueryRecord(layout, 4); err == nil {
+		t.Error("expected error for out of range record")
+	}
+	wrongSize := layout
+	wrongSize.SqrtN++
+	if _, _, err := client.QueryRecord(wrongSize, 0); err == nil {
+		t.Error("expected error for layout of a different size")
+	}
+	tooManyBits := layout
+	tooManyBits.BitsPerCell = 9
+	if _, _, err := client.QueryRecord(tooManyBits, 0); err == nil {
+		t.Error("expected error for layout with more bits per cell than p allows")
+	}
+
+	st, queries, err := client.QueryRecord(layout, 0)
+	if err != nil {
+		t.Fatalf("QueryRecord() error = %v", err)
+	}
+	if _, err := client.RecoverRecord(st, nil); err == nil {
+		t.Error("expected error for missing answers")
+	}
+	if _, err := client.RecoverRecord(st, []*Vec{NewVec(layout.SqrtN + 1)}); err == nil {
+		t.Error("expected error for wrong answer size")
+	}
+	if _, err := client.RecoverRecord(nil, queries); err == nil {
+		t.Error("expected error for nil state")
+	}
+}
+
+func TestClientBatch(t *testing.T) { forEachBackend(t, testClientBatch) }
+
+func testClientBatch(t *testing.T, backend Backend) {
+	params := Params{N: 16, Q: big.NewInt(1 << 32), P: big.NewInt(256), SqrtN: 8, Backend: backend}
+	db := NewMatWith(8, 8, backend).FillRandom(params.P)
+	server, err := NewServer(params, db)
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	client, err := NewClientFromSeed(params, server.Seed(), server.Hint())
+	if err != nil {
+		t.Fatalf("NewClientFromSeed() error = %v", err)
+	}
+
+	indices := make([]Cell, 0, 64)
+	for row := range 8 {
+		for col := range 8 {
+			indices = append(indices, Cell{row, col})
+		}
+	}
+	st, qus, err := client.QueryBatch(indices)
+	if err != nil {
+		t.Fatalf("QueryBatch() error = %v", err)
+	}
+	ans, err := server.AnswerBatch(qus)
+	if err != nil {
+		t.Fatalf("AnswerBatch() error = %v", err)
+	}
+	results, err := client.RecoverBatch(st, ans)
+	if err != nil {
+		t.Fatalf("RecoverBatch() error = %v", err)
+	}
+	for k, idx := range indices {
+		if expected := db.Get(idx.Row, idx.Col).Uint64(); results[k] != expected {
+			t.Errorf("RecoverBatch() at (%d,%d) = %v, want %v", idx.Row, idx.Col, results[k], expected)
+		}
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		if _, _, err := client.QueryBatch(nil); err == nil {
+			t.Error("expected error for empty batch")
+		}
+		if _, _, err := client.QueryBatch([]Cell{{0, 0}, {8, 0}}); err == nil {
+			t.Error("expected error for out of range index")
+		}
+		if _, err := client.RecoverBatch(st, NewMatWith(8, 3, backend)); err == nil {
+			t.Error("expected error for answer with wrong number of columns")
+		}
+		if _, err := client.RecoverBatch(nil, ans); err == nil {
+			t.Error("expected error for nil state")
+		}
+		if _, err := server.AnswerBatch(NewMatWith(4, 2, backend)); err == nil {
+			t.Error("expected error for batch with wrong number of rows")
+		}
+		if _, err := server.AnswerBatch(nil); err == nil {
+			t.Error("expected error for nil batch")
+		}
+	})
+}
+
+func TestClientSafetyBand(t *testing.T) { forEachBackend(t, testClientSafetyBand) }
+
+func testClientSafetyBand(t *testing.T, backend Backend) {
+	params := Params{N: 16, Q: big.NewInt(1 << 32), P: big.NewInt(256), SqrtN: 8, Backend: backend}
+	records := [][]byte{[]byte("margin"), []byte("safety"), []byte("noise!")}
+	db, err := NewDatabase(records, params.P)
+	if err != nil {
+		t.Fatalf("NewDatabase() error = %v", err)
+	}
+	params.SqrtN = db.Layout().SqrtN
+	server, err := NewServer(params, db.Mat().as(backend))
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	client, err := NewClientFromSeed(params, server.Seed(), server.Hint())
+	if err != nil {
+		t.Fatalf("NewClientFromSeed() error = %v", err)
+	}
+	newBanded := func(band *big.Int) *Client {
+		banded := params
+		banded.SafetyBand = band
+		c, err := NewClientFromSeed(banded, server.Seed(), server.Hint())
+		if err != nil {
+			t.Fatalf("NewClientFromSeed() error = %v", err)
+		}
+		return c
+	}
+
+	st, qu, _ := client.Query(1, 0)
+	ans, _ := server.Answer(qu)
+	got, margin, err := client.RecoverWithMargin(st, ans)
+	if err != nil {
+		t.Fatalf("RecoverWithMargin() error = %v", err)
+	}
+	if want := db.Mat().Get(1, 0).Uint64(); got != want {
+		t.Errorf("RecoverWithMargin() = %v, want %v", got, want)
+	}
+	// the noise is tiny next to Δ = 2^24, so the value sits close to the middle of its band
+	if quarter := new(big.Int).Rsh(params.delta(), 2); margin.Cmp(quarter) < 0 {
+		t.Errorf("RecoverWithMargin() margin = %v, want at least Δ/4 = %v", margin, quarter)
+	}
+
+	// the band is exclusive, a margin exactly on it still passes
+	if _, err := newBanded(margin).Recover(st, ans); err != nil {
+		t.Errorf("Recover() with band equal to the margin error = %v", err)
+	}
+	tooWide := new(big.Int).Add(margin, big.NewInt(1))
+	if _, err := newBanded(tooWide).Recover(st, ans); !errors.Is(err, ErrLowMargin) {
+		t.Errorf("Recover() with band above the margin error = %v, want %v", err, ErrLowMargin)
+	}
+
+	// no value can be further than Δ/2 from a threshold, so this band rejects everything
+	banded := newBanded(new(big.Int).Rsh(params.delta(), 1))
+	rst, qus, err := banded.QueryRecord(db.Layout(), 1)
+	if err != nil {
+		t.Fatalf("QueryRecord() error = %v", err)
+	}
+	answers := make([]*Vec, len(qus))
+	for k, qu := range qus {
+		answers[k], _ = server.Answer(qu)
+	}
+	if _, err := banded.RecoverRecord(rst, answers); !errors.Is(err, ErrLowMargin) {
+		t.Errorf("RecoverRecord() error = %v, want %v", err, ErrLowMargin)
+	}
+	if record, err := client.RecoverRecord(rst, answers); err != nil || !bytes.Equal(record, records[1]) {
+		t.Errorf("RecoverRecord() without band = %q, %v, want %q", record, err, records[1])
+	}
+	bst, bqus, _ := banded.QueryBatch([]Cell{{0, 0}, {1, 1}})
+	bans, _ := server.AnswerBatch(bqus)
+	if _, err := banded.RecoverBatch(bst, bans); !errors.Is(err, ErrLowMargin) {
+		t.Errorf("RecoverBatch() error = %v, want %v", err, ErrLowMargin)
+	}
+}
+
+func TestReproducibleQueries(t *testing.T) { forEachBackend(t, testReproducibleQueries) }
+
+func testReproducibleQueries(t *testing.T, backend Backend) {
+	// everything the client and server draw comes from params.Rand, so a run can be replayed from its seed
+	run := func(seed []byte) (*Server, *QueryState, *Vec) {
+		params := Params{N: 16, Q: big.NewInt(1 << 32), P: big.NewInt(256), SqrtN: 8, Backend: backend, Rand: NewPRG(seed)}
+		db := NewMatWith(8, 8, backend).Fill(make([]int64, 64))
+		server, err := NewServer(params, db)
+		if err != nil {
+			t.Fatalf("NewServer() error = %v", err)
+		}
+		client, err := NewClientFromSeed(params, server.Seed(), server.Hint())
+		if err != nil {
+			t.Fatalf("NewClientFromSeed() error = %v", err)
+		}
+		st, qu, err := client.Query(3, 5)
+		if err != nil {
+			t.Fatalf("Query() error = %v", err)
+		}
+		return server, st, qu
+	}
+
+	seed := NewSeed()
+	s1, st1, qu1 := run(seed)
+	s2, st2, qu2 := run(seed)
+	if !bytes.Equal(s1.Seed(), s2.Seed()) {
+		t.Errorf("servers from the same seed expanded A from different seeds")
+	}
+	for i := range qu1.Size() {
+		if qu1.Get(i).Cmp(qu2.Get(i)) != 0 {
+			t.Fatalf("queries from the same seed differ at %d: %v and %v", i, qu1.Get(i), qu2.Get(i))
+		}
+	}
+	for i := range st1.s.Size() {
+		if st1.s.Get(i).Cmp(st2.s.Get(i)) != 0 {
+			t.Fatalf("secrets from the same seed differ at %d", i)
+		}
+	}
+
+	_, _, qu3 := run(NewSeed())
+	same := true
+	for i := range qu1.Size() {
+		same = same && qu1.Get(i).Cmp(qu3.Get(i)) == 0
+	}
+	if same {
+		t.Errorf("queries from different seeds are identical")
+	}
+}
@@ -0,0 +1,68 @@
+package simplepir
+
+import (
+	"math/big"
+	"math/bits"
+)
+
+// Costs is the communication and computation of one instance of the scheme, to compare [Server] with [RingServer]
+//
+// Sizes are in bytes, with every element of Z_q packed into ⌈log₂ q / 8⌉ bytes, and ignore the seed and framing.
+// Computation counts multiplications mod q, which dominate both modes.
+type Costs struct {
+	HintBytes   int    // hint each client downloads once
+	QueryBytes  int    // sent by the client per query
+	AnswerBytes int    // sent back by the server per query
+	SetupMults  uint64 // server's offline phase, computing the hint
+	AnswerMults uint64 // server's work per query
+	ClientMults uint64 // client's work per query, building the query and recovering the answer
+}
+
+// elemBytes returns the number of bytes needed for an element of Z_q, which are at most q - 1
+func (p Params) elemBytes() int {
+	return (new(big.Int).Sub(p.Q, big.NewInt(1)).BitLen() + 7) / 8
+}
+
+// Costs returns the costs of [Server] and [Client] for the parameters
+//
+// The hint is ℓ × n, a query m entries and an answer ℓ. Computing the hint takes ℓ·m·n multiplications, an answer
+// ℓ·m, and the client m·n to build a query and ℓ·n to recover the entry, as [pirRecover] computes hintC·s in full.
+func (p Params) Costs() (Costs, error) {
+	if err := p.Validate(); err != nil {
+		return Costs{}, err
+	}
+	rows, cols := p.Shape()
+	l, m, n := uint64(rows), uint64(cols), uint64(p.N)
+	return Costs{
+		HintBytes:   rows * p.N * p.elemBytes(),
+		QueryBytes:  cols * p.elemBytes(),
+		AnswerBytes: rows * p.elemBytes(),
+		SetupMults:  l * m * n,
+		AnswerMults: l * m,
+		ClientMults: m*n + l*n,
+	}, nil
+}
+
+// RingCosts returns the costs of [RingServer] and [RingClient] for the parameters, with ring degree d = p.N
+//
+// The database is ℓ × k ring elements for k = ⌈m/d⌉, and a transform costs d/2·log₂ d multiplications. The hint is
+// ℓ × d, a query k·d entries and an answer ℓ·d. Computing the hint takes ℓ·k + ℓ transforms and ℓ·k·d pointwise
+// products, and an answer k transforms and the same products. The client needs k + 1 transforms and k·d
+// products to build a query, and a transform and d multiplications to recover the entry.
+func (p Params) RingCosts() (Costs, error) {
+	if _, err := newRingFor(p); err != nil {
+		return Costs{}, err
+	}
+	rows, _ := p.Shape()
+	k := ringColumns(p)
+	l, kd, d := uint64(rows), uint64(k*p.N), uint64(p.N)
+	ntt := d / 2 * uint64(bits.Len64(d)-1)
+	return Costs{
+		HintBytes:   rows * p.N * p.elemBytes(),
+		QueryBytes:  k * p.N * p.elemBytes(),
+		AnswerBytes: rows * p.N * p.elemBytes(),
+		SetupMults:  (l*uint64(k)+l)*ntt + l*kd,
+		AnswerMults: uint64(k)*ntt + l*kd,
+		ClientMults: (uint64(k)+2)*ntt + kd + d,
+	}, nil
+}
@@ -0,0 +1,405 @@
+package simplepir
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// Cell is the (row, col) position of an entry in the database matrix
+type Cell struct {
+	Row, Col int
+}
+
+// Layout describes how fixed-size records are packed into the database matrix, of shape [Layout.Shape]
+//
+// It holds no data, so a client can use it to find the cells a record occupies and to rebuild the record from them.
+//
+// Each record is split into BitsPerCell-bit chunks, one per cell, and laid out down a column:
+// a query for (row, col) recovers the whole of column col (see [pirRecoverColumn]), so records never straddle
+// a column boundary and every record needs the same number of queries, which is necessary to keep the index private.
+// Unused cells at the end of each column and in trailing columns are padded with zeros.
+type Layout struct {
+	NumRecords  int // number of records stored
+	RecordSize  int // size of each record in bytes
+	BitsPerCell int // number of record bits stored per cell, at most ⌊log2 p⌋
+	SqrtN       int // the database is a SqrtN × SqrtN matrix, unless Rows and Cols are set
+	Rows        int // rows of a rectangular database
+	Cols        int // columns of a rectangular database
+}
+
+// newLayout picks the smallest SqrtN that fits numRecords records of recordSize bytes, for plaintext modulus p
+func newLayout(numRecords, recordSize int, p *big.Int) (Layout, error) {
+	if numRecords <= 0 {
+		return Layout{}, fmt.Errorf("database must hold at least one record")
+	}
+	if recordSize <= 0 {
+		return Layout{}, fmt.Errorf("record size must be positive, got %v", recordSize)
+	}
+	if p == nil || p.Cmp(big.NewInt(2)) < 0 {
+		return Layout{}, fmt.Errorf("plaintext modulus p must be at least 2, got %v", p)
+	}
+	// the largest b with 2^b ≤ p, so every b-bit chunk is in Z_p
+	bits := min(p.BitLen()-1, 32)
+
+	l := Layout{NumRecords: numRecords, RecordSize: recordSize, BitsPerCell: bits}
+	l.SqrtN = int(math.Ceil(math.Sqrt(float64(numRecords * l.cellsPerRecord()))))
+	for l.capacity() < numRecords {
+		l.SqrtN++
+	}
+	return l, nil
+}
+
+// withRows returns the layout with the given number of rows, and the fewest columns that fit every record
+func (l Layout) withRows(rows int) Layout {
+	l.SqrtN, l.Rows = 0, rows
+	if cells := l.cellsPerRecord(); cells <= rows {
+		perCol := rows / cells
+		l.Cols = (l.NumRecords + perCol - 1) / perCol
+	} else {
+		l.Cols = l.NumRecords * l.colsPerRecord()
+	}
+	return l
+}
+
+// ChooseLayout picks the shape of the database holding numRecords records of recordSize bytes which minimises
+// [Layout.Communication], for plaintext modulus p, LWE dimension n and a client making the given number of fetches
+//
+// Tall databases shrink queries at the cost of a larger hint and answers, so the more fetches a client makes per
+// hint the closer the best shape is to square. Each record's cells still run down a single column where they fit.
+func ChooseLayout(numRecords, recordSize int, p *big.Int, n, fetches int) (Layout, error) {
+	base, err := newLayout(numRecords, recordSize, p)
+	if err != nil {
+		return Layout{}, err
+	}
+	if n <= 0 || fetches <= 0 {
+		return Layout{}, fmt.Errorf("n and fetches must be positive, got %v and %v", n, fetches)
+	}
+	return base.minimise(func(l Layout) int64 { return l.Communication(n, fetches) }), nil
+}
+
+// minimise returns the shape of l with the lowest cost, which must not decrease as rows are added to a shape with
+// the same columns
+//
+// Adding rows to a shape only changes its columns when another record fits down each column, or when a record
+// that spans several columns fits in one fewer, so only the fewest rows giving each number of columns are tried.
+// That is O(√records + √cells per record) shapes rather than one per number of rows.
+func (l Layout) minimise(cost func(Layout) int64) Layout {
+	best, bestCost := l, cost(l)
+	try := func(rows int) {
+		if c := cost(l.withRows(rows)); c < bestCost {
+			best, bestCost = l.withRows(rows), c
+		}
+	}
+	cells, records := l.cellsPerRecord(), l.NumRecords
+	// records span ⌈cells/rows⌉ columns
+	for rows := 1; rows < cells; {
+		try(rows)
+		span := (cells + rows - 1) / rows
+		rows = (cells + span - 2) / (span - 1)
+	}
+	// each column holds perCol whole records, so there are ⌈records/perCol⌉ columns
+	for perCol := 1; perCol <= records; {
+		try(perCol * cells)
+		cols := (records + perCol - 1) / perCol
+		if cols == 1 {
+			break
+		}
+		perCol = (records + cols - 2) / (cols - 1)
+	}
+	return best
+}
+
+// Communication returns the number of elements of Z_q a client exchanges with the server to download the hint,
+// for LWE dimension n, and then fetch that many records
+//
+// The hint is ℓ × n, and each fetch sends a query of m entries and receives an answer of ℓ entries for every column
+// a record spans, for an ℓ × m database. The count is an int64, as many fetches overflow a 32-bit int
+func (l Layout) Communication(n, fetches int) int64 {
+	rows, cols := l.Shape()
+	return int64(rows)*int64(n) + int64(fetches)*int64(l.colsPerRecord())*int64(rows+cols)
+}
+
+// Shape returns the dimensions of the database, Rows × Cols if they are set and SqrtN × SqrtN otherwise
+func (l Layout) Shape() (rows, cols int) {
+	return shape(l.SqrtN, l.Rows, l.Cols)
+}
+
+// checkShape checks that the layout is for a database of the shape given by params
+func (l Layout) checkShape(params Params) error {
+	rows, cols := l.Shape()
+	if pRows, pCols := params.Shape(); rows != pRows || cols != pCols {
+		return fmt.Errorf("layout is for a %vx%v database, parameters are for %vx%v", rows, cols, pRows, pCols)
+	}
+	return nil
+}
+
+// Validate checks that the layout is consistent, e.g. after receiving it from a server
+func (l Layout) Validate() error {
+	if l.NumRecords <= 0 || l.RecordSize <= 0 {
+		return fmt.Errorf("layout dimensions must be positive, got %+v", l)
+	}
+	if err := checkShape(l.SqrtN, l.Rows, l.Cols); err != nil {
+		return err
+	}
+	if l.BitsPerCell <= 0 || l.BitsPerCell > 32 {
+		return fmt.Errorf("bits per cell must be in [1, 32], got %v", l.BitsPerCell)
+	}
+	if l.capacity() < l.NumRecords {
+		rows, cols := l.Shape()
+		return fmt.Errorf("%v records do not fit in a %vx%v database", l.NumRecords, rows, cols)
+	}
+	return nil
+}
+
+// cellsPerRecord returns the number of cells needed to hold one record
+func (l Layout) cellsPerRecord() int {
+	return (8*l.RecordSize + l.BitsPerCell - 1) / l.BitsPerCell
+}
+
+// colsPerRecord returns the number of columns a record spans
+func (l Layout) colsPerRecord() int {
+	rows, _ := l.Shape()
+	return (l.cellsPerRecord() + rows - 1) / rows
+}
+
+// capacity returns the number of records that fit in the database
+func (l Layout) capacity() int {
+	rows, cols := l.Shape()
+	if cells := l.cellsPerRecord(); cells <= rows {
+		return cols * (rows / cells)
+	}
+	return cols / l.colsPerRecord()
+}
+
+// Cells returns the cells occupied by record i, in the order the record's bits are stored
+func (l Layout) Cells(i int) ([]Cell, error) {
+	if i < 0 || i >= l.NumRecords {
+		return nil, fmt.Errorf("record index %v out of range for %v records", i, l.NumRecords)
+	}
+	rows, _ := l.Shape()
+	cells := l.cellsPerRecord()
+	var startRow, startCol int
+	if cells <= rows {
+		perCol := rows / cells
+		startRow, startCol = (i%perCol)*cells, i/perCol
+	} else {
+		startCol = i * l.colsPerRecord()
+	}
+	result := make([]Cell, cells)
+	for k := range cells {
+		result[k] = Cell{Row: startRow + k%rows, Col: startCol + k/rows}
+	}
+	return result, nil
+}
+
+// Columns returns the distinct columns occupied by record i, in increasing order
+func (l Layout) Columns(i int) ([]int, error) {
+	cells, err := l.Cells(i)
+	if err != nil {
+		return nil, err
+	}
+	var cols []int
+	for _, c := range cells {
+		if len(cols) == 0 || cols[len(cols)-1] != c.Col {
+			cols = append(cols, c.Col)
+		}
+	}
+	return cols, nil
+}
+
+// Changes returns the changes to the database matrix that store record in place of record i, for
+// [PreparedDB.UpdateBatch]
+func (l Layout) Changes(i int, record []byte) ([]Change, error) {
+	if len(record) != l.RecordSize {
+		return nil, fmt.Errorf("record must be %v bytes, got %v", l.RecordSize, len(record))
+	}
+	cells, err := l.Cells(i)
+	if err != nil {
+		return nil, err
+	}
+	values := l.encode(record)
+	changes := make([]Change, len(cells))
+	for k, c := range cells {
+		changes[k] = Change{Row: c.Row, Col: c.Col, Value: new(big.Int).SetUint64(values[k])}
+	}
+	return changes, nil
+}
+
+// encode splits a record into BitsPerCell-bit chunks, least significant bit first
+func (l Layout) encode(record []byte) []uint64 {
+	values := make([]uint64, l.cellsPerRecord())
+	for bit := range 8 * len(record) {
+		if record[bit/8]>>(bit%8)&1 == 1 {
+			values[bit/l.BitsPerCell] |= 1 << (bit % l.BitsPerCell)
+		}
+	}
+	return values
+}
+
+// encodeCell returns chunk k of [Layout.encode](record), without encoding the rest of the record
+func (l Layout) encodeCell(record []byte, k int) uint32 {
+	var v uint32
+	for b := range l.BitsPerCell {
+		if bit := k*l.BitsPerCell + b; bit < 8*len(record) && record[bit/8]>>(bit%8)&1 == 1 {
+			v |= 1 << b
+		}
+	}
+	return v
+}
+
+// at is the inverse of [Layout.Cells], returning the record i and chunk k stored at (row, col), or false if the
+// cell is padding
+func (l Layout) at(row, col int) (i, k int, ok bool) {
+	rows, _ := l.Shape()
+	cells := l.cellsPerRecord()
+	if cells <= rows {
+		perCol := rows / cells
+		if row >= perCol*cells {
+			return 0, 0, false
+		}
+		i, k = col*perCol+row/cells, row%cells
+	} else {
+		i, k = col/l.colsPerRecord(), col%l.colsPerRecord()*rows+row
+	}
+	return i, k, i < l.NumRecords && k < cells
+}
+
+// SquareLayout returns the layout [NewDatabase] uses for numRecords records of recordSize bytes, the smallest
+// square one for plaintext modulus p
+func SquareLayout(numRecords, recordSize int, p *big.Int) (Layout, error) {
+	return newLayout(numRecords, recordSize, p)
+}
+
+// decode reassembles a record from the chunks produced by [Layout.encode]
+func (l Layout) decode(values []uint64) []byte {
+	record := make([]byte, l.RecordSize)
+	for bit := range 8 * l.RecordSize {
+		if values[bit/l.BitsPerCell]>>(bit%l.BitsPerCell)&1 == 1 {
+			record[bit/8] |= 1 << (bit % 8)
+		}
+	}
+	return record
+}
+
+// Database packs a list of fixed-size records into the matrix served by a [Server]
+//
+// hidden fields, construct with [NewDatabase] or [NewDatabaseFromBlob]
+type Database struct {
+	layout Layout
+	mat    *Mat
+}
+
+// Creates a new [*Database] from records, which must all have the same length
+//
+// p is the plaintext modulus the database will be served with, see [Params]. The database is square, use
+// [NewDatabaseWithLayout] with [ChooseLayout] for a shape balancing the hint against the queries.
+//
+// Usage:
+//
+//	db, err := NewDatabase(records, p)
+//	params.SqrtN = db.Layout().SqrtN
+//	server, err := NewServer(params, db.Mat())
+//	// the client fetches record i with Client.QueryRecord(db.Layout(), i) and Client.RecoverRecord
+func NewDatabase(records [][]byte, p *big.Int) (*Database, error) {
+	if len(records) == 0 {
+		return nil, fmt.Errorf("database must hold at least one record")
+	}
+	layout, err := newLayout(len(records), len(records[0]), p)
+	if err != nil {
+		return nil, err
+	}
+	return NewDatabaseWithLayout(records, layout, p)
+}
+
+// Creates a new [*Database] from records, packed as described by layout
+//
+// Usage:
+//
+//	layout, err := ChooseLayout(len(records), recordSize, p, params.N, fetches)
+//	db, err := NewDatabaseWithLayout(records, layout, p)
+//	params.Rows, params.Cols = layout.Shape()
+func NewDatabaseWithLayout(records [][]byte, layout Layout, p *big.Int) (*Database, error) {
+	if err := layout.checkRecords(records, p); err != nil {
+		return nil, err
+	}
+
+	backend := BigBackend
+	if p.Cmp(two32) <= 0 {
+		backend = Uint32Backend
+	}
+	rows, cols := layout.Shape()
+	mat := NewMatWith(rows, cols, backend)
+	for i, record := range records {
+		cells, _ := layout.Cells(i)
+		for k, v := range layout.encode(record) {
+			mat.Set(cells[k].Row, cells[k].Col, new(big.Int).SetUint64(v))
+		}
+	}
+	return &Database{layout: layout, mat: mat}, nil
+}
+
+// checkRecords checks that records fill the layout, and that it fits in Z_p
+func (l Layout) checkRecords(records [][]byte, p *big.Int) error {
+	if err := l.checkCount(len(records), p); err != nil {
+		return err
+	}
+	for i, record := range records {
+		if len(record) != l.RecordSize {
+			return fmt.Errorf("record %v has size %v, expected %v", i, len(record), l.RecordSize)
+		}
+	}
+	return nil
+}
+
+// checkCount checks that numRecords records fill the layout, and that it fits in Z_p
+func (l Layout) checkCount(numRecords int, p *big.Int) error {
+	if err := l.Validate(); err != nil {
+		return fmt.Errorf("invalid layout: %v", err)
+	}
+	if numRecords != l.NumRecords {
+		return fmt.Errorf("layout holds %v records, got %v", l.NumRecords, numRecords)
+	}
+	if p == nil || l.BitsPerCell >= p.BitLen() {
+		return fmt.Errorf("layout stores %v bits per cell, which does not fit in Z_p for p = %v", l.BitsPerCell, p)
+	}
+	return nil
+}
+
+// Creates a new [*Database] by splitting blob into records of recordSize bytes
+//
+// the final record is padded with zeros if len(blob) is not a multiple of recordSize
+func NewDatabaseFromBlob(blob []byte, recordSize int, p *big.Int) (*Database, error) {
+	records, err := SplitRecords(blob, recordSize)
+	if err != nil {
+		return nil, err
+	}
+	return NewDatabase(records, p)
+}
+
+// SplitRecords splits blob into records of recordSize bytes, padding the final record with zeros
+func SplitRecords(blob []byte, recordSize int) ([][]byte, error) {
+	if recordSize <= 0 {
+		return nil, fmt.Errorf("record size must be positive, got %v", recordSize)
+	}
+	records := make([][]byte, 0, (len(blob)+recordSize-1)/recordSize)
+	for start := 0; start < len(blob); start += recordSize {
+		record := make([]byte, recordSize)
+		copy(record, blob[start:min(start+recordSize, len(blob))])
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// Layout returns the layout of the records in the database, which clients need to retrieve them
+func (d *Database) Layout() Layout {
+	return d.layout
+}
+
+// Mat returns the database matrix, to be passed to [NewServer]
+//
+// The returned matrix is shared with the database and must not be modified. Updates to a server built from it
+// leave it unchanged, see [PreparedDB.UpdateBatch].
+func (d *Database) Mat() *Mat {
+	return d.mat
+}
@@ -0,0 +1,328 @@
+package simplepir
+
+import (
+	"bytes"
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+func TestNewLayout(t *testing.T) {
+	tests := []struct {
+		name          string
+		numRecords    int
+		recordSize    int
+		p             int64
+		expectedBits  int
+		expectedSqrtN int
+	}{
+		{"byte cells, one cell per record", 100, 1, 256, 8, 10},
+		{"byte cells, several records per column", 10, 4, 256, 8, 8},
+		{"bit cells", 4, 2, 2, 1, 8},
+		{"non power of two p", 9, 1, 100, 6, 5},
+		{"record spans several columns", 3, 16, 256, 8, 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := newLayout(tt.numRecords, tt.recordSize, big.NewInt(tt.p))
+			if err != nil {
+				t.Fatalf("newLayout() error = %v", err)
+			}
+			if l.BitsPerCell != tt.expectedBits {
+				t.Errorf("BitsPerCell = %v, want %v", l.BitsPerCell, tt.expectedBits)
+			}
+			if l.SqrtN != tt.expectedSqrtN {
+				t.Errorf("SqrtN = %v, want %v", l.SqrtN, tt.expectedSqrtN)
+			}
+			if err := l.Validate(); err != nil {
+				t.Errorf("Validate() error = %v", err)
+			}
+		})
+	}
+
+	t.Run("invalid inputs", func(t *testing.T) {
+		if _, err := newLayout(0, 1, big.NewInt(256)); err == nil {
+			t.Error("expected error for no records")
+		}
+		if _, err := newLayout(1, 0, big.NewInt(256)); err == nil {
+			t.Error("expected error for empty records")
+		}
+		if _, err := newLayout(1, 1, big.NewInt(1)); err == nil {
+			t.Error("expected error for p < 2")
+		}
+	})
+}
+
+func TestChooseLayout(t *testing.T) {
+	tests := []struct {
+		name       string
+		numRecords int
+		recordSize int
+		n          int
+		fetches    int
+	}{
+		{"one fetch", 10000, 4, 1024, 1},
+		{"many fetches", 10000, 4, 1024, 1 << 20},
+		{"large records", 50, 100, 512, 10},
+		{"single record", 1, 1, 64, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := ChooseLayout(tt.numRecords, tt.recordSize, big.NewInt(256), tt.n, tt.fetches)
+			if err != nil {
+				t.Fatalf("ChooseLayout() error = %v", err)
+			}
+			if err := l.Validate(); err != nil {
+				t.Errorf("Validate() error = %v", err)
+			}
+			square, _ := newLayout(tt.numRecords, tt.recordSize, big.NewInt(256))
+			if got, sq := l.Communication(tt.n, tt.fetches), square.Communication(tt.n, tt.fetches); got > sq {
+				t.Errorf("Communication() = %v, more than %v for the square layout", got, sq)
+			}
+			// no shape with a neighbouring number of rows does better
+			rows, cols := l.Shape()
+			for _, r := range []int{rows - 1, rows + 1} {
+				if r > 0 && l.withRows(r).Communication(tt.n, tt.fetches) < l.Communication(tt.n, tt.fetches) {
+					t.Errorf("%v rows beats the chosen %vx%v", r, rows, cols)
+				}
+			}
+		})
+	}
+
+	// a hint downloaded for a single fetch should be small, so the database is wide
+	l, _ := ChooseLayout(10000, 4, big.NewInt(256), 1024, 1)
+	if rows, cols := l.Shape(); rows >= cols {
+		t.Errorf("ChooseLayout() for one fetch = %vx%v, want fewer rows than columns", rows, cols)
+	}
+
+	t.Run("invalid inputs", func(t *testing.T) {
+		if _, err := ChooseLayout(0, 1, big.NewInt(256), 1024, 1); err == nil {
+			t.Error("expected error for no records")
+		}
+		if _, err := ChooseLayout(1, 1, big.NewInt(256), 0, 1); err == nil {
+			t.Error("expected error for n = 0")
+		}
+		if _, err := ChooseLayout(1, 1, big.NewInt(256), 1024, 0); err == nil {
+			t.Error("expected error for no fetches")
+		}
+	})
+}
+
+func TestMinimise(t *testing.T) {
+	costs := map[string]func(Layout) int64{
+		"lwe one fetch":   func(l Layout) int64 { return l.Communication(1024, 1) },
+		"lwe many":        func(l Layout) int64 { return l.Communication(512, 1000) },
+		"ring few":        func(l Layout) int64 { return l.RingCommunication(64, 3) },
+		"rows and cols":   func(l Layout) int64 { r, c := l.Shape(); return int64(7*r + 3*c) },
+		"penalise narrow": func(l Layout) int64 { r, c := l.Shape(); return int64(r + 50*c*l.colsPerRecord()) },
+	}
+	for _, size := range [][2]int{{1, 1}, {1, 100}, {37, 5}, {1000, 3}, {500, 64}} {
+		base, _ := newLayout(size[0], size[1], big.NewInt(256))
+		for name, cost := range costs {
+			// the shape found must be as good as the best of trying every number of rows
+			want := cost(base)
+			for rows := 1; rows <= base.NumRecords*base.cellsPerRecord(); rows++ {
+				want = min(want, cost(base.withRows(rows)))
+			}
+			if got := cost(base.minimise(cost)); got != want {
+				t.Errorf("%v records of %v bytes, %v: minimise() cost %v, want %v", size[0], size[1], name, got, want)
+			}
+		}
+	}
+}
+
+func BenchmarkChooseLayout(b *testing.B) {
+	// a million 32-byte records, as in the cost comparison with the ring mode
+	for b.Loop() {
+		if _, err := ChooseLayout(1<<20, 32, big.NewInt(991), 1024, 10); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestLayoutWithRows(t *testing.T) {
+	base, _ := newLayout(30, 5, big.NewInt(256)) // 5 cells per record
+	for rows := 1; rows <= 160; rows++ {
+		l := base.withRows(rows)
+		if err := l.Validate(); err != nil {
+			t.Fatalf("withRows(%v) = %+v, Validate() error = %v", rows, l, err)
+		}
+		fewer := l
+		fewer.Cols--
+		if fewer.Cols > 0 && fewer.capacity() >= l.NumRecords {
+			t.Errorf("withRows(%v) uses %v columns, but %v suffice", rows, l.Cols, fewer.Cols)
+		}
+		// every cell is used at most once
+		seen := make(map[Cell]bool)
+		for i := range l.NumRecords {
+			cells, _ := l.Cells(i)
+			for _, c := range cells {
+				if seen[c] || c.Row >= l.Rows || c.Col >= l.Cols {
+					t.Fatalf("withRows(%v): record %v uses cell %v twice or out of range", rows, i, c)
+				}
+				seen[c] = true
+			}
+		}
+	}
+}
+
+func TestLayoutCells(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		numRecords int
+		recordSize int
+	}{
+		{"records within a column", 50, 3},
+		{"records spanning columns", 5, 40},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := newLayout(tt.numRecords, tt.recordSize, big.NewInt(256))
+			if err != nil {
+				t.Fatalf("newLayout() error = %v", err)
+			}
+
+			// every record occupies distinct in-range cells, and spans the same number of columns
+			seen := make(map[Cell]int)
+			for i := range tt.numRecords {
+				cells, err := l.Cells(i)
+				if err != nil {
+					t.Fatalf("Cells(%d) error = %v", i, err)
+				}
+				for _, c := range cells {
+					if c.Row < 0 || c.Row >= l.SqrtN || c.Col < 0 || c.Col >= l.SqrtN {
+						t.Errorf("record %d uses out of range cell %v", i, c)
+					}
+					if prev, ok := seen[c]; ok {
+						t.Errorf("cell %v used by records %d and %d", c, prev, i)
+					}
+					seen[c] = i
+				}
+				cols, _ := l.Columns(i)
+				if len(cols) != l.colsPerRecord() {
+					t.Errorf("record %d spans %d columns, want %d", i, len(cols), l.colsPerRecord())
+				}
+			}
+		})
+	}
+
+	l, _ := newLayout(4, 1, big.NewInt(256))
+	if _, err := l.Cells(4); err == nil {
+		t.Error("expected error for out of range record")
+	}
+	if _, err := l.Cells(-1); err == nil {
+		t.Error("expected error for negative record")
+	}
+}
+
+func TestLayoutEncodeDecode(t *testing.T) {
+	for _, p := range []int64{2, 3, 16, 100, 256, 1 << 20} {
+		l, err := newLayout(1, 13, big.NewInt(p))
+		if err != nil {
+			t.Fatalf("newLayout() error = %v", err)
+		}
+		record := make([]byte, 13)
+		rand.Read(record)
+
+		values := l.encode(record)
+		for k, v := range values {
+			if v >= uint64(p) {
+				t.Errorf("p=%d: cell %d = %v, not in Z_p", p, k, v)
+			}
+		}
+		if got := l.decode(values); !bytes.Equal(got, record) {
+			t.Errorf("p=%d: decode(encode(%x)) = %x", p, record, got)
+		}
+	}
+}
+
+func TestNewDatabase(t *testing.T) {
+	p := big.NewInt(256)
+	records := [][]byte{{1, 2}, {3, 4}, {5, 6}}
+	db, err := NewDatabase(records, p)
+	if err != nil {
+		t.Fatalf("NewDatabase() error = %v", err)
+	}
+	l := db.Layout()
+	if db.Mat().Rows() != l.SqrtN || db.Mat().Cols() != l.SqrtN {
+		t.Errorf("matrix is %vx%v, want %vx%v", db.Mat().Rows(), db.Mat().Cols(), l.SqrtN, l.SqrtN)
+	}
+	for i, record := range records {
+		cells, _ := l.Cells(i)
+		for k, c := range cells {
+			if got := db.Mat().Get(c.Row, c.Col); got.Int64() != int64(record[k]) {
+				t.Errorf("record %d byte %d stored as %v, want %v", i, k, got, record[k])
+			}
+		}
+	}
+
+	if _, err := NewDatabase(nil, p); err == nil {
+		t.Error("expected error for no records")
+	}
+	if _, err := NewDatabase([][]byte{{1, 2}, {3}}, p); err == nil {
+		t.Error("expected error for mismatched record sizes")
+	}
+}
+
+func TestNewDatabaseWithLayout(t *testing.T) {
+	records := [][]byte{{1, 2}, {3, 4}, {5, 6}}
+	p := big.NewInt(256)
+	base, _ := newLayout(len(records), 2, p)
+	layout := base.withRows(2)
+	db, err := NewDatabaseWithLayout(records, layout, p)
+	if err != nil {
+		t.Fatalf("NewDatabaseWithLayout() error = %v", err)
+	}
+	if db.Mat().Rows() != 2 || db.Mat().Cols() != 3 {
+		t.Errorf("database is %vx%v, want 2x3", db.Mat().Rows(), db.Mat().Cols())
+	}
+	if got := db.Mat().Get(1, 2).Int64(); got != 6 {
+		t.Errorf("entry (1, 2) = %v, want 6", got)
+	}
+
+	tests := []struct {
+		name    string
+		records [][]byte
+		layout  Layout
+		p       *big.Int
+	}{
+		{"invalid layout", records, Layout{}, p},
+		{"wrong number of records", records[:2], layout, p},
+		{"wrong record size", [][]byte{{1}, {2}, {3}}, layout, p},
+		{"cells too wide for p", records, layout, big.NewInt(2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewDatabaseWithLayout(tt.records, tt.layout, tt.p); err == nil {
+				t.Errorf("NewDatabaseWithLayout() succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestNewDatabaseFromBlob(t *testing.T) {
+	blob := []byte{1, 2, 3, 4, 5, 6, 7}
+	db, err := NewDatabaseFromBlob(blob, 3, big.NewInt(256))
+	if err != nil {
+		t.Fatalf("NewDatabaseFromBlob() error = %v", err)
+	}
+	if db.Layout().NumRecords != 3 {
+		t.Errorf("NumRecords = %v, want 3", db.Layout().NumRecords)
+	}
+	// last record is padded with zeros
+	cells, _ := db.Layout().Cells(2)
+	expected := []int64{7, 0, 0}
+	for k, c := range cells {
+		if got := db.Mat().Get(c.Row, c.Col); got.Int64() != expected[k] {
+			t.Errorf("last record byte %d = %v, want %v", k, got, expected[k])
+		}
+	}
+
+	if _, err := NewDatabaseFromBlob(blob, 0, big.NewInt(256)); err == nil {
+		t.Error("expected error for zero record size")
+	}
+	if _, err := NewDatabaseFromBlob(nil, 4, big.NewInt(256)); err == nil {
+		t.Error("expected error for empty blob")
+	}
+}
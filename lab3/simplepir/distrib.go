@@ -2,50 +2,79 @@ package simplepir
 
 import (
 	"crypto/rand"
+	"encoding/binary"
 	"fmt"
+	"io"
 	"math"
 	"math/big"
+	"math/bits"
+	"sync"
 )
 
 // return cryptographically secure random integer in range [min, max]
 func randInt(min, max int) int {
-	// crypo/[rand.Int] returns a value in the range [0,max)
+	return randIntFrom(rand.Reader, min, max)
+}
+
+// return a random integer in range [min, max] read from r, deterministic for a deterministic r
+func randIntFrom(r io.Reader, min, max int) int {
+	// [uniformFrom] returns a value in the range [0,max)
 	// so need to subtract min to get a valid max, and add 1 since we want the range to be inclusive
 	if min >= max {
 		panic(fmt.Errorf("min %v should be strictly < max %v", min, max))
 	}
-	nBig, err := rand.Int(rand.Reader, big.NewInt(int64(max+1-min)))
-	if err != nil {
-		panic(err)
-	}
-	n := nBig.Int64()
+	n := uniformFrom(r, big.NewInt(int64(max+1-min))).Int64()
 	return int(n) + min
 }
 
-// Generate a secure random float in the range [0,1) using [rand.Int]
+// Generate a secure random float in the range [0,1) using [rand.Reader]
 func randFloat() float64 {
+	return randFloatFrom(rand.Reader)
+}
+
+// Generate a random float in the range [0,1) read from r, deterministic for a deterministic r
+func randFloatFrom(r io.Reader) float64 {
 	// create a random int made up of 53 bits, as the mantissa of float64 is 53 bits.
 	max := big.NewInt(1<<53 - 1)
-	f, err := rand.Int(rand.Reader, max)
-	if err != nil {
-		panic(err)
-	}
+	f := uniformFrom(r, max)
 	// Float64 returns the float64 value nearest x
 	randFloat, _ := f.Float64()
 
 	return randFloat / (1 << 53)
 }
 
+// Sampler draws integers from an error distribution, such as [GaussSampler]
 type Sampler interface {
 	Sample() int
 }
 
+// ReaderSampler is a [Sampler] that can also take its randomness from a given reader, so that runs can be replayed
+//
+// [GaussSampler] and [CDTSampler] both implement it, see Params.Rand
+type ReaderSampler interface {
+	Sampler
+	SampleFrom(r io.Reader) int
+}
+
+// sampleFrom draws from s using randomness from r if s supports it, and s's own randomness otherwise
+func sampleFrom(s Sampler, r io.Reader) int {
+	if rs, ok := s.(ReaderSampler); ok && r != nil {
+		return rs.SampleFrom(r)
+	}
+	return s.Sample()
+}
+
 type GaussSampler struct {
 	t     float64 // Center t: the mean of the Gaussian
 	sigma float64 // Stdev σ: the standard deviation of the Gaussian
 	tau   float64 // Tailcut τ: a sample σ is at most τσ from the center with overwhelming probability.
 }
 
+// Creates a new [GaussSampler] with center t, standard deviation sigma and tailcut tau
+func NewGaussSampler(t, sigma, tau float64) GaussSampler {
+	return GaussSampler{t: t, sigma: sigma, tau: tau}
+}
+
 // Sample from discrete Gaussian distribution
 //
 // Implicit input (from struct): A center t : float, and a parameter σ : float, and a tailcut parameter τ : float
@@ -56,16 +85,109 @@ type GaussSampler struct {
 //
 // source: https://link.springer.com/chapter/10.1007/978-3-642-34961-4_26
 func (g GaussSampler) Sample() int {
+	return g.SampleFrom(rand.Reader)
+}
+
+// SampleFrom is [GaussSampler.Sample] with randomness read from r, deterministic for a deterministic r
+func (g GaussSampler) SampleFrom(r io.Reader) int {
 	h := -math.Pi / (g.sigma * g.sigma)
 	xMax := math.Ceil(g.t + g.tau*g.sigma)
 	xMin := math.Floor(g.t - g.tau*g.sigma)
 
 	for {
-		x := randInt(int(xMin), int(xMax))
+		x := randIntFrom(r, int(xMin), int(xMax))
 		p := math.Exp(h * math.Pow(float64(x)-g.t, 2))
-		r := randFloat()
-		if r < p {
+		u := randFloatFrom(r)
+		if u < p {
 			return x
 		}
 	}
 }
+
+// customisation string for cSHAKE128, separating the stream of a [CDTSampler] from any other use of its seed
+var cdtDomain = []byte("simplepir cdt sampler")
+
+// CDTSampler samples the same distribution as [GaussSampler] in constant time, using a cumulative distribution table
+//
+// The table is computed once for the given t, σ and τ. Each sample reads 64 uniform bits r from a seeded CSPRNG
+// (cSHAKE128) and returns min + #{k : r ≥ table[k]}, scanning the whole table with branch-free comparisons,
+// so the running time does not depend on the value returned. Probabilities are computed in float64, so the
+// distribution matches the ideal one to within roughly 2^-53 per value.
+//
+// hidden fields, construct with [NewCDTSampler]. Safe for concurrent use.
+type CDTSampler struct {
+	gauss GaussSampler // the distribution being sampled
+	min   int          // smallest value in the support
+	table []uint64     // table[k] = ⌊2^64 · Pr[X ≤ min + k]⌋, the final entry (always 2^64) is omitted
+	mu    sync.Mutex   // guards prg
+	prg   io.Reader
+}
+
+// Creates a new [*CDTSampler] for the discrete Gaussian with center t, width sigma and tailcut tau,
+// reading randomness from a CSPRNG expanded from seed
+//
+// seed must be [SeedSize] bytes, or nil to use a fresh random seed from [NewSeed]
+func NewCDTSampler(t, sigma, tau float64, seed []byte) (*CDTSampler, error) {
+	if sigma <= 0 || tau <= 0 {
+		return nil, fmt.Errorf("sigma and tau must be positive, got %v and %v", sigma, tau)
+	}
+	if seed == nil {
+		seed = NewSeed()
+	}
+	if len(seed) != SeedSize {
+		return nil, fmt.Errorf("seed must be %v bytes, got %v", SeedSize, len(seed))
+	}
+
+	// the same support and weights exp(-π(x-t)²/σ²) as GaussSampler.Sample
+	xMin := int(math.Floor(t - tau*sigma))
+	xMax := int(math.Ceil(t + tau*sigma))
+	weights := make([]float64, xMax-xMin+1)
+	total := 0.0
+	for k := range weights {
+		x := float64(xMin+k) - t
+		weights[k] = math.Exp(-math.Pi * x * x / (sigma * sigma))
+		total += weights[k]
+	}
+	table := make([]uint64, len(weights)-1)
+	cumulative := 0.0
+	for k := range table {
+		cumulative += weights[k] / total
+		// 2^64 · cumulative, saturating as rounding can push cumulative to 1 in the upper tail
+		if scaled := math.Ldexp(cumulative, 64); scaled < math.Ldexp(1, 64) {
+			table[k] = uint64(scaled)
+		} else {
+			table[k] = math.MaxUint64
+		}
+	}
+
+	return &CDTSampler{
+		gauss: NewGaussSampler(t, sigma, tau),
+		min:   xMin,
+		table: table,
+		prg:   newPRG(seed, cdtDomain),
+	}, nil
+}
+
+// Sample draws a value from the discrete Gaussian in constant time, using the sampler's seeded CSPRNG
+func (c *CDTSampler) Sample() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.SampleFrom(c.prg)
+}
+
+// SampleFrom is [CDTSampler.Sample] with the 64 random bits read from r instead
+func (c *CDTSampler) SampleFrom(r io.Reader) int {
+	var buf [8]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		panic(err)
+	}
+	x := binary.LittleEndian.Uint64(buf[:])
+
+	// count the entries x is at least, as 1 - borrow of x - table[k], without branching on r
+	var count uint64
+	for _, v := range c.table {
+		_, borrow := bits.Sub64(x, v, 0)
+		count += 1 - borrow
+	}
+	return c.min + int(count)
+}
@@ -2,6 +2,8 @@ package simplepir
 
 import (
 	"math"
+	"math/big"
+	"slices"
 	"testing"
 )
 
@@ -136,3 +138,198 @@ func TestGaussSampler(t *testing.T) {
 
 	})
 }
+
+func TestSampleFrom(t *testing.T) {
+	cdt, _ := NewCDTSampler(0, 6.4, 6.46695107473, nil)
+	for _, s := range []struct {
+		name    string
+		sampler ReaderSampler
+	}{{"gauss", NewGaussSampler(0, 6.4, 6.46695107473)}, {"cdt", cdt}} {
+		t.Run(s.name, func(t *testing.T) {
+			seed := NewSeed()
+			r1, r2 := NewPRG(seed), NewPRG(seed)
+			for i := range 100 {
+				if x1, x2 := s.sampler.SampleFrom(r1), s.sampler.SampleFrom(r2); x1 != x2 {
+					t.Fatalf("sample %d from the same seed = %v and %v, want equal", i, x1, x2)
+				}
+			}
+		})
+	}
+
+	t.Run("falls back to the sampler's own randomness", func(t *testing.T) {
+		if x := sampleFrom(constSampler{}, NewPRG(NewSeed())); x != 0 {
+			t.Errorf("sampleFrom() = %v, want 0", x)
+		}
+		if x := sampleFrom(NewGaussSampler(0, 1, 3), nil); x < -3 || x > 3 {
+			t.Errorf("sampleFrom() = %v, want value in [-3,3]", x)
+		}
+	})
+
+	t.Run("randIntFrom", func(t *testing.T) {
+		seed := NewSeed()
+		r1, r2 := NewPRG(seed), NewPRG(seed)
+		for range 100 {
+			x1, x2 := randIntFrom(r1, -5, 5), randIntFrom(r2, -5, 5)
+			if x1 != x2 || x1 < -5 || x1 > 5 {
+				t.Fatalf("randIntFrom() = %v and %v, want equal values in [-5,5]", x1, x2)
+			}
+		}
+	})
+}
+
+// chiSquaredBound is a critical value for a chi-squared statistic with df degrees of freedom,
+// exceeded by chance with probability well below 10^-6
+func chiSquaredBound(df int) float64 {
+	return float64(df) + 6*math.Sqrt(2*float64(df))
+}
+
+// gaussProbabilities returns the exact probability of each value in [min, max] under [GaussSampler]
+func gaussProbabilities(g GaussSampler) (int, []float64) {
+	min := int(math.Floor(g.t - g.tau*g.sigma))
+	max := int(math.Ceil(g.t + g.tau*g.sigma))
+	probs := make([]float64, max-min+1)
+	total := 0.0
+	for k := range probs {
+		x := float64(min+k) - g.t
+		probs[k] = math.Exp(-math.Pi * x * x / (g.sigma * g.sigma))
+		total += probs[k]
+	}
+	for k := range probs {
+		probs[k] /= total
+	}
+	return min, probs
+}
+
+func TestCDTSampler(t *testing.T) {
+	const cdt_test_samples = 200_000
+	sigma, tau := 6.4, 6.46695107473
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		if _, err := NewCDTSampler(0, 0, tau, nil); err == nil {
+			t.Errorf("NewCDTSampler() with sigma = 0 succeeded, want error")
+		}
+		if _, err := NewCDTSampler(0, sigma, -1, nil); err == nil {
+			t.Errorf("NewCDTSampler() with tau = -1 succeeded, want error")
+		}
+		if _, err := NewCDTSampler(0, sigma, tau, []byte{1, 2, 3}); err == nil {
+			t.Errorf("NewCDTSampler() with short seed succeeded, want error")
+		}
+	})
+
+	t.Run("deterministic for a fixed seed", func(t *testing.T) {
+		seed := NewSeed()
+		c1, _ := NewCDTSampler(0, sigma, tau, seed)
+		c2, _ := NewCDTSampler(0, sigma, tau, seed)
+		c3, _ := NewCDTSampler(0, sigma, tau, nil)
+		var s1, s2, s3 []int
+		for range 100 {
+			s1, s2, s3 = append(s1, c1.Sample()), append(s2, c2.Sample()), append(s3, c3.Sample())
+		}
+		if !slices.Equal(s1, s2) {
+			t.Errorf("samplers with the same seed gave different samples")
+		}
+		if slices.Equal(s1, s3) {
+			t.Errorf("samplers with different seeds gave the same samples")
+		}
+	})
+
+	t.Run("table is monotone", func(t *testing.T) {
+		c, _ := NewCDTSampler(0.5, sigma, tau, nil)
+		for k := 1; k < len(c.table); k++ {
+			if c.table[k] < c.table[k-1] {
+				t.Errorf("table[%d] = %v is below table[%d] = %v", k, c.table[k], k-1, c.table[k-1])
+			}
+		}
+	})
+
+	t.Run("matches the exact distribution", func(t *testing.T) {
+		g := NewGaussSampler(0, sigma, tau)
+		c, _ := NewCDTSampler(0, sigma, tau, nil)
+		min, probs := gaussProbabilities(g)
+		counts := make([]int, len(probs))
+		for range cdt_test_samples {
+			x := c.Sample()
+			if x < min || x >= min+len(probs) {
+				t.Fatalf("sample %v outside the support [%v, %v]", x, min, min+len(probs)-1)
+			}
+			counts[x-min]++
+		}
+
+		// chi-squared goodness of fit, over values expected at least 5 times
+		stat, df := 0.0, -1
+		for k, p := range probs {
+			expected := p * cdt_test_samples
+			if expected < 5 {
+				continue
+			}
+			d := float64(counts[k]) - expected
+			stat += d * d / expected
+			df++
+		}
+		if stat > chiSquaredBound(df) {
+			t.Errorf("chi-squared statistic = %v with %v degrees of freedom, want at most %v", stat, df, chiSquaredBound(df))
+		}
+	})
+
+	t.Run("histogram matches GaussSampler", func(t *testing.T) {
+		const compare_samples = 20_000
+		g := NewGaussSampler(0, sigma, tau)
+		c, _ := NewCDTSampler(0, sigma, tau, nil)
+		gauss, cdt := make(map[int]int), make(map[int]int)
+		for range compare_samples {
+			gauss[g.Sample()]++
+			cdt[c.Sample()]++
+		}
+
+		// two-sample chi-squared test with equal sample sizes, over values seen at least 10 times in total
+		min, probs := gaussProbabilities(g)
+		stat, df := 0.0, -1
+		for k := range probs {
+			a, b := float64(gauss[min+k]), float64(cdt[min+k])
+			if a+b < 10 {
+				continue
+			}
+			stat += (a - b) * (a - b) / (a + b)
+			df++
+		}
+		if stat > chiSquaredBound(df) {
+			t.Errorf("chi-squared statistic = %v with %v degrees of freedom, want at most %v", stat, df, chiSquaredBound(df))
+		}
+	})
+
+	t.Run("usable as the PIR error distribution", func(t *testing.T) {
+		c, _ := NewCDTSampler(0, sigma, tau, nil)
+		params := Params{N: 1024, Q: two32, P: big.NewInt(991), SqrtN: 1024}
+		params.Sampler = NewGaussSampler(0, sigma, tau)
+		gaussBits, _ := params.SecurityBits()
+		params.Sampler = c
+		if bits, err := params.SecurityBits(); err != nil || bits != gaussBits {
+			t.Errorf("SecurityBits() = %v, %v, want %v as for the equivalent GaussSampler", bits, err, gaussBits)
+		}
+
+		params = Params{N: 16, Q: two32, P: big.NewInt(256), SqrtN: 8, Sampler: c, Backend: Uint32Backend}
+		db := NewMatWith(8, 8, Uint32Backend).FillRandom(params.P)
+		A := NewMatWith(8, 16, Uint32Backend).FillRandom(params.Q)
+		hintC := pirSetup(db, A, params)
+		st, qu := pirQuery(3, 5, A, params)
+		ans, _ := pirAnswer(db, qu, params)
+		got, _ := pirRecover(ans, st, hintC, params)
+		if expected := db.Get(3, 5).Uint64(); got != expected {
+			t.Errorf("pirRecover() = %v, want %v", got, expected)
+		}
+	})
+}
+
+func BenchmarkSample(b *testing.B) {
+	c, _ := NewCDTSampler(t, sigma, tau, nil)
+	for _, s := range []struct {
+		name    string
+		sampler Sampler
+	}{{"gauss", chi}, {"cdt", c}} {
+		b.Run(s.name, func(b *testing.B) {
+			for b.Loop() {
+				s.sampler.Sample()
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+package simplepir
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+)
+
+// customisation string for cSHAKE128, separating the expansion of A2 from that of A
+var a2Domain = []byte("doublepir public matrix A2")
+
+// digits returns κ = ⌈log_p q⌉, the number of base-p digits needed to write a value in Z_q
+func (p Params) digits() int {
+	kappa := 1
+	for power := new(big.Int).Set(p.P); power.Cmp(p.Q) < 0; power.Mul(power, p.P) {
+		kappa++
+	}
+	return kappa
+}
+
+// decomposeT writes every entry of m in base p, and returns the transpose of the digit matrix
+//
+// m is rows × cols over Z_q, the result is (cols·κ) × rows over Z_p, where row c·κ + k holds digit k
+// (least significant first) of column c of m
+func decomposeT(m *Mat, params Params) *Mat {
+	kappa := params.digits()
+	result := NewMatWith(m.cols*kappa, m.rows, params.Backend)
+	d := new(big.Int)
+	for i := range m.rows {
+		for j := range m.cols {
+			x := m.Get(i, j)
+			for k := range kappa {
+				x.DivMod(x, params.P, d)
+				result.Set(j*kappa+k, i, d)
+			}
+		}
+	}
+	return result
+}
+
+// recompose inverts the base p decomposition of [decomposeT], returning Σ_k digits[k]·p^k mod q
+func recompose(digits []uint64, params Params) *big.Int {
+	result := new(big.Int)
+	for _, d := range slices.Backward(digits) {
+		result.Mul(result, params.P)
+		result.Add(result, new(big.Int).SetUint64(d))
+	}
+	return result.Mod(result, params.Q)
+}
+
+// doublePirSetup computes the server's and the client's hints for DoublePIR
+//
+// DoublePIR (section 5 of Henzinger et al.) runs SimplePIR twice: the first level is [pirSetup] with A1, giving
+// hintC = db·A1 which is ℓ × n for an ℓ × m database. Rather than sending hintC, the server writes it in base p as
+// hintD = Decomp(hintC)ᵀ, of size nκ × ℓ over Z_p, and treats hintD as a second database queried with A2 (ℓ × n).
+//
+// hintD is kept by the server, and the client downloads hint2 = hintD·A2, which is nκ × n and so
+// independent of the size of the database
+func doublePirSetup(db, A1, A2 *Mat, params Params) (hintD, hint2 *Mat) {
+	hintD = decomposeT(pirSetup(db, A1, params), params)
+	return hintD, hintD.MatMulParallel(A2, params.Q, params.Workers)
+}
+
+// DoubleQueryState is the client-side state kept between issuing a DoublePIR query and recovering the answer
+//
+// Fields are hidden as they include the secrets s1 and s2, which must never leave the client
+type DoubleQueryState struct {
+	row int  // row of the database entry being retrieved
+	s1  *Vec // LWE secret of the column query
+	s2  *Vec // LWE secret of the row query
+}
+
+// doublePirQuery generates the two query vectors for the database entry at (i, j)
+//
+// c1 is a SimplePIR query under A1 selecting column j, and c2 a SimplePIR query under A2 selecting row i
+func doublePirQuery(i, j int, A1, A2 *Mat, params Params) (DoubleQueryState, *Vec, *Vec) {
+	st1, c1 := pirQuery(i, j, A1, params)
+	st2, c2 := pirQuery(0, i, A2, params)
+	return DoubleQueryState{row: i, s1: st1.s, s2: st2.s}, c1, c2
+}
+
+// doublePirAnswer answers a DoublePIR query
+//
+// the first level answer ans1 = db·c1 holds column j, encrypted under s1. The server decomposes it into
+// ansD = Decomp(ans1)ᵀ (κ × ℓ) and answers c2 over the stacked database [hintD; ansD], which selects row i of
+// both hintC and ans1. Since ansD depends on the query, the client cannot precompute ansD·A2, so it is sent too.
+//
+// returns ans = [hintD; ansD]·c2 of size (n+1)κ, and ansHint = ansD·A2 of size κ × n, or an error for malformed queries
+func doublePirAnswer(db, hintD, A2 *Mat, c1, c2 *Vec, params Params) (*Vec, *Mat, error) {
+	ans1, err := pirAnswer(db, c1, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	column := NewMatWith(ans1.size, 1, params.Backend)
+	column.setCol(0, ans1)
+	ansD := decomposeT(column, params)
+
+	h, err := hintD.VecMulParallelChecked(c2, params.Q, params.Workers)
+	if err != nil {
+		return nil, nil, err
+	}
+	a := ansD.VecMul(c2, params.Q)
+	ans := NewVecWith(h.size+a.size, params.Backend)
+	for k := range h.size {
+		ans.Set(k, h.Get(k))
+	}
+	for k := range a.size {
+		ans.Set(h.size+k, a.Get(k))
+	}
+	return ans, ansD.MatMul(A2, params.Q), nil
+}
+
+// doublePirRecover extracts the database value from a DoublePIR answer
+//
+// the second level is decrypted with s2 against [hint2; ansHint], giving the base p digits of row i of hintC
+// and of ans1[i]. Recomposing them leaves a first level SimplePIR answer, which is decrypted with s1 as in [pirRecover].
+// A wrong digit corrupts the result as surely as a wrong final value, so the margin returned is the smallest of all the roundings.
+func doublePirRecover(ans *Vec, ansHint *Mat, st DoubleQueryState, hint2 *Mat, params Params) (uint64, *big.Int) {
+	q, kappa, n := params.Q, params.digits(), hint2.cols
+	masks := hint2.VecMul(st.s2, q)
+	ansMask := ansHint.VecMul(st.s2, q)
+
+	// values[:n] is row i of hintC, values[n] is ans1[i]
+	values := make([]*big.Int, n+1)
+	digits := make([]uint64, kappa)
+	x := new(big.Int)
+	var margin *big.Int
+	for c := range n + 1 {
+		for k := range kappa {
+			idx := c*kappa + k
+			if c < n {
+				x.Sub(ans.Get(idx), masks.Get(idx))
+			} else {
+				x.Sub(ans.Get(idx), ansMask.Get(k))
+			}
+			var m *big.Int
+			digits[k], m = params.decode(x.Mod(x, q))
+			if margin == nil || m.Cmp(margin) < 0 {
+				margin = m
+			}
+		}
+		values[c] = recompose(digits, params)
+	}
+
+	// ans1[i] - ⟨hintC[i], s1⟩ = Δ·db[i][j] + noise
+	x.Set(values[n])
+	for k := range n {
+		x.Sub(x, new(big.Int).Mul(values[k], st.s1.Get(k)))
+	}
+	val, m := params.decode(x.Mod(x, q))
+	if m.Cmp(margin) < 0 {
+		margin = m
+	}
+	return val, margin
+}
+
+// DoubleServer holds the database and hints for the DoublePIR mode, a drop-in alternative to [Server]
+// whose client hint does not grow with the database
+//
+// hidden fields, construct with [NewDoubleServer]
+type DoubleServer struct {
+	params Params
+	db     *Mat
+	seed   []byte
+	a2     *Mat
+	hintD  *Mat
+	hint2  *Mat
+}
+
+// Creates a new [*DoubleServer] for db under the given parameters.
+//
+// Expands the public matrices A1 and A2 from a fresh seed and computes the hints with [doublePirSetup].
+// The client's hint is nκ × n for κ = ⌈log_p q⌉, compared to ℓ × n for [Server].
+//
+// Usage:
+//
+//	server, err := NewDoubleServer(params, db)
+//	client, err := NewDoubleClient(params, server.Seed(), server.Hint())
+//	st, c1, c2, err := client.Query(row, col) // send c1 and c2 to the server
+//	ans, err := server.Answer(c1, c2)        // send ans back to the client
+//	value, err := client.Recover(st, ans)
+func NewDoubleServer(params Params, db *Mat) (*DoubleServer, error) {
+	if err := checkDatabase(params, db); err != nil {
+		return nil, err
+	}
+	db = db.as(params.Backend)
+	seed := newSeedFrom(params.random())
+	A1, err := ExpandA(seed, params)
+	if err != nil {
+		return nil, fmt.Errorf("could not expand A1: %v", err)
+	}
+	rows, _ := params.Shape()
+	A2, err := expandMat(seed, a2Domain, rows, params)
+	if err != nil {
+		return nil, fmt.Errorf("could not expand A2: %v", err)
+	}
+	hintD, hint2 := doublePirSetup(db, A1, A2, params)
+	return &DoubleServer{params: params, db: db, seed: seed, a2: A2, hintD: hintD, hint2: hint2}, nil
+}
+
+// Params returns the parameters the server was set up with
+func (s *DoubleServer) Params() Params {
+	return s.params
+}
+
+// Seed returns the seed A1 and A2 were expanded from
+func (s *DoubleServer) Seed() []byte {
+	return slices.Clone(s.seed) // defensive clone
+}
+
+// Hint returns hint2 = Decomp(db·A1)ᵀ·A2, which clients need to recover answers
+//
+// The returned matrix is shared with the server and must not be modified. The server never modifies it either,
+// as DoublePIR has no updates.
+func (s *DoubleServer) Hint() *Mat {
+	return s.hint2
+}
+
+// DoubleAnswer is the server's response to a DoublePIR query
+//
+// Ans holds (n+1)κ entries and Hint is κ × n, both independent of the size of the database
+type DoubleAnswer struct {
+	Ans  *Vec
+	Hint *Mat
+}
+
+// Answer responds to the query vectors c1 and c2 produced by [DoubleClient.Query]
+func (s *DoubleServer) Answer(c1, c2 *Vec) (*DoubleAnswer, error) {
+	rows, cols := s.params.Shape()
+	if c1 == nil || c1.size != cols || c2 == nil || c2.size != rows {
+		return nil, fmt.Errorf("queries must be vectors of sizes %v and %v", cols, rows)
+	}
+	ans, ansHint, err := doublePirAnswer(s.db, s.hintD, s.a2, c1, c2, s.params)
+	if err != nil {
+		return nil, err
+	}
+	return &DoubleAnswer{Ans: ans, Hint: ansHint}, nil
+}
+
+// DoubleClient builds DoublePIR queries and recovers answers, using the hint from a [DoubleServer]
+//
+// hidden fields, construct with [NewDoubleClient]
+type DoubleClient struct {
+	params Params
+	a1, a2 *Mat
+	hint2  *Mat
+}
+
+// Creates a new [*DoubleClient] from the seed of the public matrices and the hint downloaded from the server
+func NewDoubleClient(params Params, seed []byte, hint *Mat) (*DoubleClient, error) {
+	A1, err := ExpandA(seed, params)
+	if err != nil {
+		return nil, fmt.Errorf("could not expand A1: %v", err)
+	}
+	dbRows, _ := params.Shape()
+	A2, err := expandMat(seed, a2Domain, dbRows, params)
+	if err != nil {
+		return nil, fmt.Errorf("could not expand A2: %v", err)
+	}
+	if rows := params.N * params.digits(); hint == nil || hint.rows != rows || hint.cols != params.N {
+		return nil, fmt.Errorf("hint must be a %vx%v matrix", rows, params.N)
+	}
+	return &DoubleClient{params: params, a1: A1, a2: A2, hint2: hint.as(params.Backend)}, nil
+}
+
+// Params returns the parameters the client was set up with
+func (c *DoubleClient) Params() Params {
+	return c.params
+}
+
+// Query builds the two query vectors for the database entry at (row, col)
+//
+// Returns the state needed by [DoubleClient.Recover], which must be kept secret, and the queries to send to the server
+func (c *DoubleClient) Query(row, col int) (*DoubleQueryState, *Vec, *Vec, error) {
+	if err := checkIndex(row, col, c.params); err != nil {
+		return nil, nil, nil, err
+	}
+	st, c1, c2 := doublePirQuery(row, col, c.a1, c.a2, c.params)
+	return &st, c1, c2, nil
+}
+
+// Recover extracts the database entry from the server's answer, using the state st returned by [DoubleClient.Query]
+//
+// The returned value lies in Z_p
+func (c *DoubleClient) Recover(st *DoubleQueryState, ans *DoubleAnswer) (uint64, error) {
+	val, _, err := c.RecoverWithMargin(st, ans)
+	return val, err
+}
+
+// RecoverWithMargin is [DoubleClient.Recover], but also returns the noise margin as in [Client.RecoverWithMargin]
+//
+// The margin is the smallest over every rounding in the recovery, the digits of the second level as well as the final value
+func (c *DoubleClient) RecoverWithMargin(st *DoubleQueryState, ans *DoubleAnswer) (uint64, *big.Int, error) {
+	if st == nil || st.s1 == nil || st.s2 == nil || st.s1.size != c.params.N || st.s2.size != c.params.N {
+		return 0, nil, fmt.Errorf("query state does not match client parameters")
+	}
+	kappa := c.params.digits()
+	if ans == nil || ans.Ans == nil || ans.Ans.size != (c.params.N+1)*kappa {
+		return 0, nil, fmt.Errorf("answer must be a vector of size %v", (c.params.N+1)*kappa)
+	}
+	if ans.Hint == nil || ans.Hint.rows != kappa || ans.Hint.cols != c.params.N {
+		return 0, nil, fmt.Errorf("answer hint must be a %vx%v matrix", kappa, c.params.N)
+	}
+	val, margin := doublePirRecover(ans.Ans.as(c.params.Backend), ans.Hint.as(c.params.Backend), *st, c.hint2, c.params)
+	if err := c.params.checkMargin(margin); err != nil {
+		return 0, nil, err
+	}
+	return val, margin, nil
+}
@@ -0,0 +1,210 @@
+package simplepir
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestDigits(t *testing.T) {
+	tests := []struct {
+		q, p     int64
+		expected int
+	}{
+		{1 << 32, 2, 32},
+		{1 << 32, 256, 4},
+		{1 << 32, 991, 4},
+		{1 << 32, 1 << 16, 2},
+		{1<<32 + 1, 1 << 16, 3},
+		{97, 96, 2},
+		{97, 97, 1},
+	}
+	for _, tt := range tests {
+		params := Params{Q: big.NewInt(tt.q), P: big.NewInt(tt.p)}
+		if got := params.digits(); got != tt.expected {
+			t.Errorf("digits() for q = %v, p = %v is %v, want %v", tt.q, tt.p, got, tt.expected)
+		}
+	}
+}
+
+func TestDecompose(t *testing.T) { forEachBackend(t, testDecompose) }
+
+func testDecompose(t *testing.T, backend Backend) {
+	params := Params{Q: big.NewInt(1 << 32), P: big.NewInt(991), Backend: backend}
+	kappa := params.digits()
+	m := NewMatWith(3, 5, backend).FillRandom(params.Q)
+	d := decomposeT(m, params)
+	if d.rows != 5*kappa || d.cols != 3 {
+		t.Fatalf("decomposeT() has dimensions (%d,%d), want (%d,%d)", d.rows, d.cols, 5*kappa, 3)
+	}
+	for i := range m.rows {
+		for j := range m.cols {
+			digits := make([]uint64, kappa)
+			for k := range kappa {
+				digit := d.Get(j*kappa+k, i)
+				if digit.Cmp(params.P) >= 0 {
+					t.Errorf("digit %d of (%d,%d) = %v is not in Z_p", k, i, j, digit)
+				}
+				digits[k] = digit.Uint64()
+			}
+			if got := recompose(digits, params); got.Cmp(m.Get(i, j)) != 0 {
+				t.Errorf("recompose(decomposeT()) at (%d,%d) = %v, want %v", i, j, got, m.Get(i, j))
+			}
+		}
+	}
+}
+
+func TestDoublePirProtocol(t *testing.T) { forEachBackend(t, testDoublePirProtocol) }
+
+func testDoublePirProtocol(t *testing.T, backend Backend) {
+	testCases := []struct {
+		name  string
+		n     int
+		sqrtN int
+		p     int64
+	}{
+		{"single bit entries", 8, 8, 2},
+		{"byte entries", 16, 8, 256},
+		{"n smaller than sqrtN", 4, 16, 16},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			params := Params{N: tc.n, Q: big.NewInt(1 << 32), P: big.NewInt(tc.p), SqrtN: tc.sqrtN, Backend: backend}
+			db := NewMatWith(tc.sqrtN, tc.sqrtN, backend).FillRandom(params.P)
+			A1 := NewMatWith(tc.sqrtN, tc.n, backend).FillRandom(params.Q)
+			A2 := NewMatWith(tc.sqrtN, tc.n, backend).FillRandom(params.Q)
+			hintD, hint2 := doublePirSetup(db, A1, A2, params)
+
+			for i := range tc.sqrtN {
+				for j := range tc.sqrtN {
+					st, c1, c2 := doublePirQuery(i, j, A1, A2, params)
+					ans, ansHint, _ := doublePirAnswer(db, hintD, A2, c1, c2, params)
+					got, _ := doublePirRecover(ans, ansHint, st, hint2, params)
+					if expected := db.Get(i, j).Uint64(); got != expected {
+						t.Errorf("doublePirRecover() at (%d,%d) = %v, want %v", i, j, got, expected)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDoubleClientServer(t *testing.T) { forEachBackend(t, testDoubleClientServer) }
+
+func testDoubleClientServer(t *testing.T, backend Backend) {
+	params := Params{N: 16, Q: big.NewInt(1 << 32), P: big.NewInt(256), SqrtN: 12, Backend: backend}
+	db := NewMatWith(params.SqrtN, params.SqrtN, backend).FillRandom(params.P)
+
+	server, err := NewDoubleServer(params, db)
+	if err != nil {
+		t.Fatalf("NewDoubleServer() error = %v", err)
+	}
+	client, err := NewDoubleClient(params, server.Seed(), server.Hint())
+	if err != nil {
+		t.Fatalf("NewDoubleClient() error = %v", err)
+	}
+
+	for _, c := range []Cell{{0, 0}, {11, 11}, {3, 7}, {7, 3}} {
+		st, c1, c2, err := client.Query(c.Row, c.Col)
+		if err != nil {
+			t.Fatalf("Query() error = %v", err)
+		}
+		ans, err := server.Answer(c1, c2)
+		if err != nil {
+			t.Fatalf("Answer() error = %v", err)
+		}
+		got, err := client.Recover(st, ans)
+		if err != nil {
+			t.Fatalf("Recover() error = %v", err)
+		}
+		if expected := db.Get(c.Row, c.Col).Uint64(); got != expected {
+			t.Errorf("Recover() at %v = %v, want %v", c, got, expected)
+		}
+	}
+
+	// the margin is the smallest over all the roundings, so a band at Δ/2 rejects every answer
+	st, c1, c2, _ := client.Query(2, 9)
+	ans, _ := server.Answer(c1, c2)
+	if got, margin, err := client.RecoverWithMargin(st, ans); err != nil || got != db.Get(2, 9).Uint64() || margin.Sign() <= 0 {
+		t.Errorf("RecoverWithMargin() = %v, %v, %v, want %v with a positive margin", got, margin, err, db.Get(2, 9))
+	}
+	banded := params
+	banded.SafetyBand = new(big.Int).Rsh(params.delta(), 1)
+	bandedClient, _ := NewDoubleClient(banded, server.Seed(), server.Hint())
+	if _, err := bandedClient.Recover(st, ans); !errors.Is(err, ErrLowMargin) {
+		t.Errorf("Recover() with band Δ/2 error = %v, want %v", err, ErrLowMargin)
+	}
+
+	// errors
+	if _, _, _, err := client.Query(params.SqrtN, 0); err == nil {
+		t.Errorf("Query() out of range succeeded, want error")
+	}
+	st, c1, c2, _ = client.Query(0, 0)
+	if _, err := server.Answer(c1, NewVecWith(params.SqrtN+1, backend)); err == nil {
+		t.Errorf("Answer() with wrong size succeeded, want error")
+	}
+	if _, err := client.Recover(st, &DoubleAnswer{Ans: c1, Hint: server.Hint()}); err == nil {
+		t.Errorf("Recover() with malformed answer succeeded, want error")
+	}
+	ans, _ = server.Answer(c1, c2)
+	if _, err := client.Recover(&DoubleQueryState{}, ans); err == nil {
+		t.Errorf("Recover() with empty state succeeded, want error")
+	}
+	if _, err := NewDoubleClient(params, server.Seed(), db); err == nil {
+		t.Errorf("NewDoubleClient() with wrong hint succeeded, want error")
+	}
+	if _, err := NewDoubleServer(params, NewMatWith(2, 2, backend)); err == nil {
+		t.Errorf("NewDoubleServer() with wrong database size succeeded, want error")
+	}
+}
+
+func TestDoubleRectangular(t *testing.T) { forEachBackend(t, testDoubleRectangular) }
+
+func testDoubleRectangular(t *testing.T, backend Backend) {
+	for _, shape := range []Cell{{Row: 5, Col: 13}, {Row: 13, Col: 5}} {
+		params := Params{N: 16, Q: big.NewInt(1 << 32), P: big.NewInt(256), Rows: shape.Row, Cols: shape.Col, Backend: backend}
+		db := NewMatWith(shape.Row, shape.Col, backend).FillRandom(params.P)
+		server, err := NewDoubleServer(params, db)
+		if err != nil {
+			t.Fatalf("NewDoubleServer() error = %v", err)
+		}
+		client, err := NewDoubleClient(params, server.Seed(), server.Hint())
+		if err != nil {
+			t.Fatalf("NewDoubleClient() error = %v", err)
+		}
+		for _, c := range []Cell{{0, 0}, {shape.Row - 1, shape.Col - 1}, {shape.Row / 2, shape.Col - 1}} {
+			st, c1, c2, err := client.Query(c.Row, c.Col)
+			if err != nil {
+				t.Fatalf("Query() error = %v", err)
+			}
+			if c1.size != shape.Col || c2.size != shape.Row {
+				t.Errorf("queries have sizes %v and %v, want %v and %v", c1.size, c2.size, shape.Col, shape.Row)
+			}
+			ans, err := server.Answer(c1, c2)
+			if err != nil {
+				t.Fatalf("Answer() error = %v", err)
+			}
+			if got, err := client.Recover(st, ans); err != nil || got != db.Get(c.Row, c.Col).Uint64() {
+				t.Errorf("Recover() at %v of %vx%v = %v, %v, want %v", c, shape.Row, shape.Col, got, err, db.Get(c.Row, c.Col))
+			}
+		}
+		if _, err := server.Answer(NewVecWith(shape.Row, backend), NewVecWith(shape.Col, backend)); err == nil {
+			t.Errorf("Answer() with swapped query sizes succeeded, want error")
+		}
+	}
+}
+
+func TestDoublePirHintSize(t *testing.T) {
+	// the client's hint depends only on n and κ, where SimplePIR's grows with sqrtN
+	for _, sqrtN := range []int{8, 32} {
+		params := Params{N: 8, Q: big.NewInt(1 << 32), P: big.NewInt(256), SqrtN: sqrtN, Backend: Uint32Backend}
+		server, err := NewDoubleServer(params, NewMatWith(sqrtN, sqrtN, Uint32Backend))
+		if err != nil {
+			t.Fatalf("NewDoubleServer() error = %v", err)
+		}
+		if rows, cols := server.Hint().Rows(), server.Hint().Cols(); rows != 8*4 || cols != 8 {
+			t.Errorf("hint for sqrtN = %d is %dx%d, want %dx%d", sqrtN, rows, cols, 8*4, 8)
+		}
+	}
+}
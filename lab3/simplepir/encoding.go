@@ -0,0 +1,546 @@
+package simplepir
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+)
+
+// wire format constants, every encoding starts with the magic string, the version and the kind of value
+const (
+	wireMagic   = "SPIR"
+	wireVersion = 2
+	headerSize  = len(wireMagic) + 2
+)
+
+// wireKind identifies the type of value in an encoding, so that e.g. a hint cannot be decoded as a query
+type wireKind byte
+
+const (
+	kindVec wireKind = iota + 1
+	kindMat
+	kindParams
+	kindLayout
+	kindHintDiff
+	kindKeywordLayout
+	kindCommitment
+	kindMatFile
+)
+
+// sampler encodings for [Params]
+const (
+	samplerDefault byte = iota // nil sampler, i.e. the default Gaussian
+	samplerGauss               // [GaussSampler] followed by t, σ and τ as float64s
+)
+
+// appendHeader appends the magic string, version and kind to b
+func appendHeader(b []byte, kind wireKind) []byte {
+	b = append(b, wireMagic...)
+	return append(b, wireVersion, byte(kind))
+}
+
+// readHeader checks the header of data is for the given kind and returns the rest of the encoding
+func readHeader(data []byte, kind wireKind) ([]byte, error) {
+	if len(data) < headerSize {
+		return nil, fmt.Errorf("encoding too short, got %v bytes", len(data))
+	}
+	if string(data[:len(wireMagic)]) != wireMagic {
+		return nil, fmt.Errorf("bad magic %q", data[:len(wireMagic)])
+	}
+	if v := data[len(wireMagic)]; v != wireVersion {
+		return nil, fmt.Errorf("unsupported version %v, expected %v", v, wireVersion)
+	}
+	if k := wireKind(data[len(wireMagic)+1]); k != kind {
+		return nil, fmt.Errorf("encoding holds kind %v, expected %v", k, kind)
+	}
+	return data[headerSize:], nil
+}
+
+// fitsUint32 reports whether x can be encoded as a uint32, written so that it also builds where int is 32 bits
+func fitsUint32(x int) bool {
+	return x >= 0 && uint64(x) <= math.MaxUint32
+}
+
+// entryWidth returns the number of bytes needed to encode every entry in xs, at least 1
+func entryWidth(backend Backend, get func(i int) *big.Int, n int) (int, error) {
+	if backend == Uint32Backend {
+		return 4, nil
+	}
+	width := 1
+	for i := range n {
+		x := get(i)
+		if x.Sign() < 0 {
+			return 0, fmt.Errorf("cannot encode negative entry %v", x)
+		}
+		width = max(width, (x.BitLen()+7)/8)
+	}
+	if width > math.MaxUint8 {
+		return 0, fmt.Errorf("entries too large to encode, need %v bytes", width)
+	}
+	return width, nil
+}
+
+// appendEntry appends x as a width-byte little-endian integer
+func appendEntry(b []byte, x *big.Int, width int) []byte {
+	buf := x.FillBytes(make([]byte, width)) // big-endian
+	slices.Reverse(buf)
+	return append(b, buf...)
+}
+
+// readEntry reads a width-byte little-endian integer
+func readEntry(data []byte) *big.Int {
+	buf := slices.Clone(data)
+	slices.Reverse(buf)
+	return new(big.Int).SetBytes(buf)
+}
+
+// readEntries checks that data holds exactly n entries of the given width for backend, and decodes them with set
+func readEntries(data []byte, backend Backend, width int, n uint64, set func(i int, x *big.Int)) error {
+	if backend != BigBackend && backend != Uint32Backend {
+		return fmt.Errorf("unknown backend %v", backend)
+	}
+	if width == 0 || (backend == Uint32Backend && width > 4) {
+		return fmt.Errorf("invalid entry width %v for %v backend", width, backend)
+	}
+	if n > uint64(len(data)) || n*uint64(width) != uint64(len(data)) {
+		return fmt.Errorf("expected %v bytes of entries, got %v", n*uint64(width), len(data))
+	}
+	for i := range int(n) {
+		set(i, readEntry(data[i*width:(i+1)*width]))
+	}
+	return nil
+}
+
+// MarshalBinary encodes the vector as
+//
+//	header | backend (1 byte) | entry width w (1 byte) | size (uint32) | size × w-byte entries
+//
+// with all integers little-endian, e.g. to send a query or answer over the network
+func (v *Vec) MarshalBinary() ([]byte, error) {
+	if v.size == 0 {
+		return nil, fmt.Errorf("cannot encode empty vector")
+	}
+	width, err := entryWidth(v.backend, v.Get, v.size)
+	if err != nil {
+		return nil, err
+	}
+	b := appendHeader(make([]byte, 0, headerSize+6+v.size*width), kindVec)
+	b = append(b, byte(v.backend), byte(width))
+	b = binary.LittleEndian.AppendUint32(b, uint32(v.size))
+	for i := range v.size {
+		b = appendEntry(b, v.Get(i), width)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes a vector encoded by [Vec.MarshalBinary], overwriting v
+//
+// The length of data must match the header exactly
+func (v *Vec) UnmarshalBinary(data []byte) error {
+	rest, err := readHeader(data, kindVec)
+	if err != nil {
+		return fmt.Errorf("could not decode vector: %v", err)
+	}
+	if len(rest) < 6 {
+		return fmt.Errorf("could not decode vector: truncated header")
+	}
+	backend, width := Backend(rest[0]), int(rest[1])
+	size := binary.LittleEndian.Uint32(rest[2:6])
+	if size == 0 {
+		return fmt.Errorf("could not decode vector: size must be positive")
+	}
+	var result *Vec
+	err = readEntries(rest[6:], backend, width, uint64(size), func(i int, x *big.Int) {
+		if result == nil {
+			result = NewVecWith(int(size), backend)
+		}
+		result.Set(i, x)
+	})
+	if err != nil {
+		return fmt.Errorf("could not decode vector: %v", err)
+	}
+	*v = *result
+	return nil
+}
+
+// MarshalBinary encodes the matrix as
+//
+//	header | backend (1 byte) | entry width w (1 byte) | rows (uint32) | cols (uint32) | rows × cols w-byte entries
+//
+// with all integers little-endian and entries in row-major order, e.g. to send the hint over the network
+func (m *Mat) MarshalBinary() ([]byte, error) {
+	if m.rows == 0 || m.cols == 0 {
+		return nil, fmt.Errorf("cannot encode empty matrix")
+	}
+	get := func(i int) *big.Int { return m.Get(i/m.cols, i%m.cols) }
+	width, err := entryWidth(m.backend, get, m.rows*m.cols)
+	if err != nil {
+		return nil, err
+	}
+	b := appendHeader(make([]byte, 0, headerSize+10+m.rows*m.cols*width), kindMat)
+	b = append(b, byte(m.backend), byte(width))
+	b = binary.LittleEndian.AppendUint32(b, uint32(m.rows))
+	b = binary.LittleEndian.AppendUint32(b, uint32(m.cols))
+	for i := range m.rows * m.cols {
+		b = appendEntry(b, get(i), width)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes a matrix encoded by [Mat.MarshalBinary], overwriting m
+//
+// The length of data must match the header exactly
+func (m *Mat) UnmarshalBinary(data []byte) error {
+	rest, err := readHeader(data, kindMat)
+	if err != nil {
+		return fmt.Errorf("could not decode matrix: %v", err)
+	}
+	if len(rest) < 10 {
+		return fmt.Errorf("could not decode matrix: truncated header")
+	}
+	backend, width := Backend(rest[0]), int(rest[1])
+	rows := binary.LittleEndian.Uint32(rest[2:6])
+	cols := binary.LittleEndian.Uint32(rest[6:10])
+	if rows == 0 || cols == 0 {
+		return fmt.Errorf("could not decode matrix: dimensions must be positive")
+	}
+	var result *Mat
+	err = readEntries(rest[10:], backend, width, uint64(rows)*uint64(cols), func(i int, x *big.Int) {
+		if result == nil {
+			result = NewMatWith(int(rows), int(cols), backend)
+		}
+		result.Set(i/int(cols), i%int(cols), x)
+	})
+	if err != nil {
+		return fmt.Errorf("could not decode matrix: %v", err)
+	}
+	*m = *result
+	return nil
+}
+
+// fromShape returns the SqrtN, Rows and Cols fields describing a rows × cols database, using SqrtN if it is square
+func fromShape(rows, cols int) (sqrtN, r, c int) {
+	if rows == cols {
+		return rows, 0, 0
+	}
+	return 0, rows, cols
+}
+
+// appendBigInt appends x as a uint16 little-endian length followed by its little-endian bytes
+func appendBigInt(b []byte, x *big.Int) []byte {
+	width := (x.BitLen() + 7) / 8
+	b = binary.LittleEndian.AppendUint16(b, uint16(width))
+	return appendEntry(b, x, width)
+}
+
+// readBigInt reads an integer written by [appendBigInt], returning the rest of data
+func readBigInt(data []byte) (*big.Int, []byte, error) {
+	if len(data) < 2 {
+		return nil, nil, fmt.Errorf("truncated integer")
+	}
+	width := int(binary.LittleEndian.Uint16(data))
+	if len(data) < 2+width {
+		return nil, nil, fmt.Errorf("truncated integer")
+	}
+	return readEntry(data[2 : 2+width]), data[2+width:], nil
+}
+
+// MarshalBinary encodes the public parameters as
+//
+//	header | n (uint32) | rows (uint32) | cols (uint32) | backend (1 byte) | q | p | sampler
+//
+// where q and p are a uint16 length followed by the little-endian value, and the sampler is a tag byte,
+// followed by t, σ and τ as float64s for a [GaussSampler]. Params.Workers is local to the server and not encoded.
+// A square shape is decoded as SqrtN, and a rectangular one as Rows and Cols.
+func (p Params) MarshalBinary() ([]byte, error) {
+	if err := p.Validate(); err != nil {
+		return nil, fmt.Errorf("cannot encode invalid parameters: %v", err)
+	}
+	rows, cols := p.Shape()
+	if p.Q.BitLen() > 8*math.MaxUint16 || !fitsUint32(p.N) || !fitsUint32(rows) || !fitsUint32(cols) {
+		return nil, fmt.Errorf("parameters too large to encode")
+	}
+	b := appendHeader(nil, kindParams)
+	b = binary.LittleEndian.AppendUint32(b, uint32(p.N))
+	b = binary.LittleEndian.AppendUint32(b, uint32(rows))
+	b = binary.LittleEndian.AppendUint32(b, uint32(cols))
+	b = append(b, byte(p.Backend))
+	b = appendBigInt(b, p.Q)
+	b = appendBigInt(b, p.P)
+	switch s := p.Sampler.(type) {
+	case nil:
+		b = append(b, samplerDefault)
+	case GaussSampler:
+		b = appendGauss(b, s)
+	case *CDTSampler:
+		// a CDTSampler draws from the same distribution, which is all the other party needs to know
+		b = appendGauss(b, s.gauss)
+	default:
+		return nil, fmt.Errorf("cannot encode sampler of type %T", s)
+	}
+	return b, nil
+}
+
+// appendGauss appends the sampler tag and t, σ and τ of g
+func appendGauss(b []byte, g GaussSampler) []byte {
+	b = append(b, samplerGauss)
+	for _, f := range []float64{g.t, g.sigma, g.tau} {
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(f))
+	}
+	return b
+}
+
+// UnmarshalBinary decodes parameters encoded by [Params.MarshalBinary], overwriting p
+//
+// The decoded parameters are validated, and the length of data must match exactly
+func (p *Params) UnmarshalBinary(data []byte) error {
+	rest, err := readHeader(data, kindParams)
+	if err != nil {
+		return fmt.Errorf("could not decode params: %v", err)
+	}
+	if len(rest) < 13 {
+		return fmt.Errorf("could not decode params: truncated header")
+	}
+	result := Params{
+		N:       int(binary.LittleEndian.Uint32(rest[0:4])),
+		Backend: Backend(rest[12]),
+	}
+	result.SqrtN, result.Rows, result.Cols = fromShape(int(binary.LittleEndian.Uint32(rest[4:8])), int(binary.LittleEndian.Uint32(rest[8:12])))
+	if result.Q, rest, err = readBigInt(rest[13:]); err != nil {
+		return fmt.Errorf("could not decode params: q: %v", err)
+	}
+	if result.P, rest, err = readBigInt(rest); err != nil {
+		return fmt.Errorf("could not decode params: p: %v", err)
+	}
+	if len(rest) < 1 {
+		return fmt.Errorf("could not decode params: missing sampler")
+	}
+	switch rest[0] {
+	case samplerDefault:
+		rest = rest[1:]
+	case samplerGauss:
+		if len(rest) < 25 {
+			return fmt.Errorf("could not decode params: truncated sampler")
+		}
+		f := func(i int) float64 { return math.Float64frombits(binary.LittleEndian.Uint64(rest[1+8*i:])) }
+		result.Sampler = NewGaussSampler(f(0), f(1), f(2))
+		rest = rest[25:]
+	default:
+		return fmt.Errorf("could not decode params: unknown sampler %v", rest[0])
+	}
+	if len(rest) != 0 {
+		return fmt.Errorf("could not decode params: %v trailing bytes", len(rest))
+	}
+	if err := result.Validate(); err != nil {
+		return fmt.Errorf("could not decode params: %v", err)
+	}
+	*p = result
+	return nil
+}
+
+// MarshalBinary encodes the layout as
+//
+//	header | records (uint32) | record size (uint32) | bits per cell (1 byte) | rows (uint32) | cols (uint32)
+//
+// with all integers little-endian, so that a server can publish it alongside the parameters.
+// As for [Params.MarshalBinary], a square shape is decoded as SqrtN.
+func (l Layout) MarshalBinary() ([]byte, error) {
+	if err := l.Validate(); err != nil {
+		return nil, fmt.Errorf("cannot encode invalid layout: %v", err)
+	}
+	rows, cols := l.Shape()
+	if !fitsUint32(l.NumRecords) || !fitsUint32(l.RecordSize) || !fitsUint32(rows) || !fitsUint32(cols) {
+		return nil, fmt.Errorf("layout too large to encode")
+	}
+	b := appendHeader(make([]byte, 0, headerSize+17), kindLayout)
+	b = binary.LittleEndian.AppendUint32(b, uint32(l.NumRecords))
+	b = binary.LittleEndian.AppendUint32(b, uint32(l.RecordSize))
+	b = append(b, byte(l.BitsPerCell))
+	b = binary.LittleEndian.AppendUint32(b, uint32(rows))
+	return binary.LittleEndian.AppendUint32(b, uint32(cols)), nil
+}
+
+// UnmarshalBinary decodes a layout encoded by [Layout.MarshalBinary], overwriting l
+//
+// The decoded layout is validated, and the length of data must match exactly
+func (l *Layout) UnmarshalBinary(data []byte) error {
+	rest, err := readHeader(data, kindLayout)
+	if err != nil {
+		return fmt.Errorf("could not decode layout: %v", err)
+	}
+	if len(rest) != 17 {
+		return fmt.Errorf("could not decode layout: expected 17 bytes after the header, got %v", len(rest))
+	}
+	result := Layout{
+		NumRecords:  int(binary.LittleEndian.Uint32(rest[0:4])),
+		RecordSize:  int(binary.LittleEndian.Uint32(rest[4:8])),
+		BitsPerCell: int(rest[8]),
+	}
+	result.SqrtN, result.Rows, result.Cols = fromShape(int(binary.LittleEndian.Uint32(rest[9:13])), int(binary.LittleEndian.Uint32(rest[13:17])))
+	if err := result.Validate(); err != nil {
+		return fmt.Errorf("could not decode layout: %v", err)
+	}
+	*l = result
+	return nil
+}
+
+// MarshalBinary encodes the diff as
+//
+//	header | from (32 bytes) | to (32 bytes) | rows r (uint32) | r uint32 row indices | values
+//
+// with all integers little-endian, and values the [Mat.MarshalBinary] encoding of Values, omitted when r = 0
+func (d *HintDiff) MarshalBinary() ([]byte, error) {
+	if (len(d.Rows) == 0) != (d.Values == nil) || d.Values != nil && d.Values.rows != len(d.Rows) {
+		return nil, fmt.Errorf("cannot encode diff with %v rows and mismatched values", len(d.Rows))
+	}
+	b := appendHeader(nil, kindHintDiff)
+	b = append(b, d.From[:]...)
+	b = append(b, d.To[:]...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(d.Rows)))
+	for _, row := range d.Rows {
+		if !fitsUint32(row) {
+			return nil, fmt.Errorf("cannot encode diff row %v", row)
+		}
+		b = binary.LittleEndian.AppendUint32(b, uint32(row))
+	}
+	if d.Values == nil {
+		return b, nil
+	}
+	values, err := d.Values.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("could not encode diff values: %v", err)
+	}
+	return append(b, values...), nil
+}
+
+// UnmarshalBinary decodes a diff encoded by [HintDiff.MarshalBinary], overwriting d
+//
+// The length of data must match exactly
+func (d *HintDiff) UnmarshalBinary(data []byte) error {
+	rest, err := readHeader(data, kindHintDiff)
+	if err != nil {
+		return fmt.Errorf("could not decode diff: %v", err)
+	}
+	var result HintDiff
+	if len(rest) < 2*len(result.From)+4 {
+		return fmt.Errorf("could not decode diff: truncated header")
+	}
+	rest = rest[copy(result.From[:], rest):]
+	rest = rest[copy(result.To[:], rest):]
+	count := uint64(binary.LittleEndian.Uint32(rest))
+	rest = rest[4:]
+	if count > uint64(len(rest))/4 {
+		return fmt.Errorf("could not decode diff: truncated rows")
+	}
+	if count == 0 {
+		if len(rest) != 0 {
+			return fmt.Errorf("could not decode diff: %v trailing bytes", len(rest))
+		}
+		*d = result
+		return nil
+	}
+	result.Rows = make([]int, count)
+	for k := range result.Rows {
+		result.Rows[k] = int(binary.LittleEndian.Uint32(rest[4*k:]))
+	}
+	result.Values = new(Mat)
+	if err := result.Values.UnmarshalBinary(rest[4*count:]); err != nil {
+		return fmt.Errorf("could not decode diff: %v", err)
+	}
+	if result.Values.rows != int(count) {
+		return fmt.Errorf("could not decode diff: %v rows but %v rows of values", count, result.Values.rows)
+	}
+	*d = result
+	return nil
+}
+
+// MarshalBinary encodes the keyword layout as
+//
+//	header | hash seed (32 bytes) | value size (uint32) | records
+//
+// with all integers little-endian, and records the [Layout.MarshalBinary] encoding of Records
+func (l KeywordLayout) MarshalBinary() ([]byte, error) {
+	if err := l.Validate(); err != nil {
+		return nil, fmt.Errorf("cannot encode invalid keyword layout: %v", err)
+	}
+	records, err := l.Records.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	b := appendHeader(nil, kindKeywordLayout)
+	b = append(b, l.HashSeed...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(l.ValueSize))
+	return append(b, records...), nil
+}
+
+// UnmarshalBinary decodes a keyword layout encoded by [KeywordLayout.MarshalBinary], overwriting l
+//
+// The decoded layout is validated, and the length of data must match exactly
+func (l *KeywordLayout) UnmarshalBinary(data []byte) error {
+	rest, err := readHeader(data, kindKeywordLayout)
+	if err != nil {
+		return fmt.Errorf("could not decode keyword layout: %v", err)
+	}
+	if len(rest) < SeedSize+4 {
+		return fmt.Errorf("could not decode keyword layout: truncated header")
+	}
+	result := KeywordLayout{
+		HashSeed:  slices.Clone(rest[:SeedSize]),
+		ValueSize: int(binary.LittleEndian.Uint32(rest[SeedSize:])),
+	}
+	if err := result.Records.UnmarshalBinary(rest[SeedSize+4:]); err != nil {
+		return fmt.Errorf("could not decode keyword layout: %v", err)
+	}
+	if err := result.Validate(); err != nil {
+		return fmt.Errorf("could not decode keyword layout: %v", err)
+	}
+	*l = result
+	return nil
+}
+
+// MarshalBinary encodes the commitment as
+//
+//	header | version (32 bytes) | columns m (uint32) | m × 32-byte column hashes
+//
+// with all integers little-endian
+func (c *Commitment) MarshalBinary() ([]byte, error) {
+	if !fitsUint32(len(c.Columns)) {
+		return nil, fmt.Errorf("cannot encode commitment to %v columns", len(c.Columns))
+	}
+	b := appendHeader(make([]byte, 0, headerSize+len(c.Version)+4+len(c.Columns)*sha256.Size), kindCommitment)
+	b = append(b, c.Version[:]...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(c.Columns)))
+	for _, h := range c.Columns {
+		b = append(b, h[:]...)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes a commitment encoded by [Commitment.MarshalBinary], overwriting c
+//
+// The length of data must match exactly
+func (c *Commitment) UnmarshalBinary(data []byte) error {
+	rest, err := readHeader(data, kindCommitment)
+	if err != nil {
+		return fmt.Errorf("could not decode commitment: %v", err)
+	}
+	var result Commitment
+	if len(rest) < len(result.Version)+4 {
+		return fmt.Errorf("could not decode commitment: truncated header")
+	}
+	rest = rest[copy(result.Version[:], rest):]
+	count := uint64(binary.LittleEndian.Uint32(rest))
+	rest = rest[4:]
+	if count*sha256.Size != uint64(len(rest)) {
+		return fmt.Errorf("could not decode commitment: expected %v bytes of column hashes, got %v", count*sha256.Size, len(rest))
+	}
+	result.Columns = make([]ColumnHash, count)
+	for k := range result.Columns {
+		rest = rest[copy(result.Columns[k][:], rest):]
+	}
+	*c = result
+	return nil
+}
@@ -0,0 +1,344 @@
+package simplepir
+
+import (
+	"bytes"
+	"math/big"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestVecEncoding(t *testing.T) { forEachBackend(t, testVecEncoding) }
+
+func testVecEncoding(t *testing.T, backend Backend) {
+	for _, q := range []*big.Int{big.NewInt(97), two32} {
+		v := NewVecWith(37, backend).FillRandom(q)
+		data, err := v.MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary() error = %v", err)
+		}
+		got := new(Vec)
+		if err := got.UnmarshalBinary(data); err != nil {
+			t.Fatalf("UnmarshalBinary() error = %v", err)
+		}
+		if got.Size() != v.Size() || got.Backend() != backend {
+			t.Fatalf("decoded vector of size %v with %v backend, want %v with %v", got.Size(), got.Backend(), v.Size(), backend)
+		}
+		for i := range v.Size() {
+			if got.Get(i).Cmp(v.Get(i)) != 0 {
+				t.Errorf("entry %v = %v, want %v", i, got.Get(i), v.Get(i))
+			}
+		}
+	}
+}
+
+func TestMatEncoding(t *testing.T) { forEachBackend(t, testMatEncoding) }
+
+func testMatEncoding(t *testing.T, backend Backend) {
+	m := NewMatWith(5, 9, backend).FillRandom(two32)
+	data, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() error = %v", err)
+	}
+	got := new(Mat)
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary() error = %v", err)
+	}
+	if got.Rows() != 5 || got.Cols() != 9 || got.Backend() != backend {
+		t.Fatalf("decoded %vx%v matrix with %v backend, want 5x9 with %v", got.Rows(), got.Cols(), got.Backend(), backend)
+	}
+	for i := range m.Rows() {
+		for j := range m.Cols() {
+			if got.Get(i, j).Cmp(m.Get(i, j)) != 0 {
+				t.Errorf("entry (%v, %v) = %v, want %v", i, j, got.Get(i, j), m.Get(i, j))
+			}
+		}
+	}
+}
+
+func TestEncodingLayout(t *testing.T) {
+	// entries are little-endian after the header, backend, width and size
+	v := NewVecWith(2, Uint32Backend).Fill([]int64{1, 0x01020304})
+	data, err := v.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() error = %v", err)
+	}
+	expected := []byte{'S', 'P', 'I', 'R', wireVersion, byte(kindVec), byte(Uint32Backend), 4, 2, 0, 0, 0, 1, 0, 0, 0, 4, 3, 2, 1}
+	if !bytes.Equal(data, expected) {
+		t.Errorf("MarshalBinary() = %v, want %v", data, expected)
+	}
+
+	// big entries use the fewest bytes that fit every entry
+	v = NewVec(2).Fill([]int64{1, 0x0102})
+	data, _ = v.MarshalBinary()
+	if width := data[headerSize+1]; width != 2 {
+		t.Errorf("entry width = %v, want 2", width)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	vec, _ := NewVecWith(4, Uint32Backend).Fill([]int64{1, 2, 3, 4}).MarshalBinary()
+	mat, _ := NewMatWith(2, 2, Uint32Backend).Fill([]int64{1, 2, 3, 4}).MarshalBinary()
+	modify := func(data []byte, f func(b []byte) []byte) []byte {
+		return f(bytes.Clone(data))
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		mat  bool
+	}{
+		{"empty", nil, false},
+		{"bad magic", modify(vec, func(b []byte) []byte { b[0] = 'X'; return b }), false},
+		{"bad version", modify(vec, func(b []byte) []byte { b[4] = wireVersion + 1; return b }), false},
+		{"matrix as vector", mat, false},
+		{"vector as matrix", vec, true},
+		{"truncated vector header", vec[:headerSize+3], false},
+		{"truncated vector", vec[:len(vec)-1], false},
+		{"trailing bytes", append(bytes.Clone(vec), 0), false},
+		{"zero size", modify(vec, func(b []byte) []byte { b[headerSize+2] = 0; return b[:headerSize+6] }), false},
+		{"size too large", modify(vec, func(b []byte) []byte { b[headerSize+5] = 0xff; return b }), false},
+		{"unknown backend", modify(vec, func(b []byte) []byte { b[headerSize] = 7; return b }), false},
+		{"zero width", modify(vec, func(b []byte) []byte { b[headerSize+1] = 0; return b }), false},
+		{"uint32 width too large", modify(vec, func(b []byte) []byte { b[headerSize+1] = 8; return append(b[:headerSize+6], make([]byte, 4*8)...) }), false},
+		{"truncated matrix", mat[:len(mat)-1], true},
+		{"zero rows", modify(mat, func(b []byte) []byte { b[headerSize+2] = 0; return b }), true},
+		{"huge matrix", modify(mat, func(b []byte) []byte {
+			copy(b[headerSize+2:], []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+			return b
+		}), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			if tt.mat {
+				err = new(Mat).UnmarshalBinary(tt.data)
+			} else {
+				err = new(Vec).UnmarshalBinary(tt.data)
+			}
+			if err == nil {
+				t.Errorf("UnmarshalBinary() succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestParamsEncoding(t *testing.T) {
+	q := new(big.Int).Lsh(big.NewInt(1), 80)
+	tests := []struct {
+		name   string
+		params Params
+	}{
+		{"default sampler", Params{N: 1024, Q: two32, P: big.NewInt(991), SqrtN: 32, Backend: Uint32Backend}},
+		{"gauss sampler", Params{N: 16, Q: big.NewInt(1 << 16), P: big.NewInt(2), SqrtN: 4, Sampler: NewGaussSampler(0, 3.2, 10)}},
+		{"big q", Params{N: 16, Q: q, P: new(big.Int).Lsh(big.NewInt(1), 40), SqrtN: 4}},
+		{"rectangular", Params{N: 16, Q: big.NewInt(1 << 16), P: big.NewInt(2), Rows: 3, Cols: 40}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Workers is local to the server and dropped
+			params := tt.params
+			params.Workers = 4
+			data, err := params.MarshalBinary()
+			if err != nil {
+				t.Fatalf("MarshalBinary() error = %v", err)
+			}
+			var got Params
+			if err := got.UnmarshalBinary(data); err != nil {
+				t.Fatalf("UnmarshalBinary() error = %v", err)
+			}
+			want := tt.params
+			if got.N != want.N || got.SqrtN != want.SqrtN || got.Rows != want.Rows || got.Cols != want.Cols ||
+				got.Backend != want.Backend || got.Workers != 0 ||
+				got.Q.Cmp(want.Q) != 0 || got.P.Cmp(want.P) != 0 || got.Sampler != want.Sampler {
+				t.Errorf("UnmarshalBinary() = %+v, want %+v", got, want)
+			}
+
+			// every strict prefix and any trailing data is rejected
+			for i := range len(data) {
+				if err := new(Params).UnmarshalBinary(data[:i]); err == nil {
+					t.Errorf("UnmarshalBinary() of %v/%v bytes succeeded, want error", i, len(data))
+				}
+			}
+			if err := new(Params).UnmarshalBinary(append(data, 0)); err == nil {
+				t.Errorf("UnmarshalBinary() with trailing byte succeeded, want error")
+			}
+		})
+	}
+}
+
+type constSampler struct{}
+
+func (constSampler) Sample() int { return 0 }
+
+func TestParamsEncodingErrors(t *testing.T) {
+	valid := Params{N: 16, Q: big.NewInt(1 << 16), P: big.NewInt(2), SqrtN: 4}
+	if _, err := (Params{}).MarshalBinary(); err == nil {
+		t.Errorf("MarshalBinary() of invalid params succeeded, want error")
+	}
+	custom := valid
+	custom.Sampler = constSampler{}
+	if _, err := custom.MarshalBinary(); err == nil {
+		t.Errorf("MarshalBinary() with custom sampler succeeded, want error")
+	}
+
+	// decoded parameters are validated, here p's single byte before the sampler tag is set so that p = 0
+	data, _ := valid.MarshalBinary()
+	data[len(data)-2] = 0
+	if err := new(Params).UnmarshalBinary(data); err == nil {
+		t.Errorf("UnmarshalBinary() of invalid params succeeded, want error")
+	}
+}
+
+func TestLayoutEncoding(t *testing.T) {
+	layout, err := newLayout(100, 33, big.NewInt(991))
+	if err != nil {
+		t.Fatalf("newLayout() error = %v", err)
+	}
+	data, err := layout.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() error = %v", err)
+	}
+	var got Layout
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary() error = %v", err)
+	}
+	if got != layout {
+		t.Errorf("UnmarshalBinary() = %+v, want %+v", got, layout)
+	}
+
+	if _, err := (Layout{}).MarshalBinary(); err == nil {
+		t.Errorf("MarshalBinary() of invalid layout succeeded, want error")
+	}
+	if err := got.UnmarshalBinary(data[:len(data)-1]); err == nil {
+		t.Errorf("UnmarshalBinary() of truncated layout succeeded, want error")
+	}
+	// a database too small for its records is rejected
+	data[len(data)-4] = 1
+	if err := got.UnmarshalBinary(data); err == nil {
+		t.Errorf("UnmarshalBinary() of inconsistent layout succeeded, want error")
+	}
+}
+
+func TestRectangularLayoutEncoding(t *testing.T) {
+	base, _ := newLayout(100, 33, big.NewInt(991))
+	layout := base.withRows(70)
+	data, err := layout.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() error = %v", err)
+	}
+	var got Layout
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary() error = %v", err)
+	}
+	if got != layout {
+		t.Errorf("UnmarshalBinary() = %+v, want %+v", got, layout)
+	}
+
+	// a rectangular layout that happens to be square decodes to the SqrtN shorthand
+	square := base
+	square.SqrtN, square.Rows, square.Cols = 0, base.SqrtN, base.SqrtN
+	data, _ = square.MarshalBinary()
+	if err := got.UnmarshalBinary(data); err != nil || got != base {
+		t.Errorf("UnmarshalBinary() = %+v, %v, want %+v", got, err, base)
+	}
+}
+
+func TestHintDiffEncoding(t *testing.T) {
+	params := Params{N: 16, Q: big.NewInt(1 << 16), P: big.NewInt(2), SqrtN: 8}
+	server, err := NewServer(params, NewMat(8, 8))
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	diff, err := server.UpdateBatch([]Change{{Row: 1, Col: 2, Value: big.NewInt(1)}, {Row: 5, Col: 0, Value: big.NewInt(1)}})
+	if err != nil {
+		t.Fatalf("UpdateBatch() error = %v", err)
+	}
+	empty, err := server.UpdateBatch(nil)
+	if err != nil {
+		t.Fatalf("UpdateBatch() error = %v", err)
+	}
+
+	for _, want := range []*HintDiff{diff, empty} {
+		data, err := want.MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary() error = %v", err)
+		}
+		got := new(HintDiff)
+		if err := got.UnmarshalBinary(data); err != nil {
+			t.Fatalf("UnmarshalBinary() error = %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("UnmarshalBinary() = %+v, want %+v", got, want)
+		}
+		if err := got.UnmarshalBinary(data[:len(data)-1]); err == nil {
+			t.Errorf("UnmarshalBinary() of truncated diff succeeded, want error")
+		}
+		if err := got.UnmarshalBinary(append(data, 0)); err == nil {
+			t.Errorf("UnmarshalBinary() with trailing byte succeeded, want error")
+		}
+	}
+
+	if _, err := (&HintDiff{Rows: []int{1}}).MarshalBinary(); err == nil {
+		t.Errorf("MarshalBinary() of diff without values succeeded, want error")
+	}
+}
+
+func TestKeywordLayoutEncoding(t *testing.T) {
+	kdb, err := NewKeywordDatabase(keywordEntries(20), 8, big.NewInt(256))
+	if err != nil {
+		t.Fatalf("NewKeywordDatabase() error = %v", err)
+	}
+	layout := kdb.Layout()
+	data, err := layout.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() error = %v", err)
+	}
+	var got KeywordLayout
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, layout) {
+		t.Errorf("UnmarshalBinary() = %+v, want %+v", got, layout)
+	}
+
+	if _, err := (KeywordLayout{Records: layout.Records}).MarshalBinary(); err == nil {
+		t.Errorf("MarshalBinary() of layout without seed succeeded, want error")
+	}
+	if err := got.UnmarshalBinary(data[:len(data)-1]); err == nil {
+		t.Errorf("UnmarshalBinary() of truncated layout succeeded, want error")
+	}
+	// a value size that does not fit the records is rejected
+	data[headerSize+SeedSize]++
+	if err := got.UnmarshalBinary(data); err == nil {
+		t.Errorf("UnmarshalBinary() of inconsistent layout succeeded, want error")
+	}
+}
+
+func TestCommitmentEncoding(t *testing.T) {
+	db := NewMatWith(3, 5, Uint32Backend).FillRandom(big.NewInt(991))
+	want := commit(db, HintVersion{1, 2, 3})
+	data, err := want.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() error = %v", err)
+	}
+	var got Commitment
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary() error = %v", err)
+	}
+	if !reflect.DeepEqual(&got, want) {
+		t.Errorf("UnmarshalBinary() = %+v, want %+v", got, want)
+	}
+
+	for _, bad := range [][]byte{data[:len(data)-1], append(slices.Clone(data), 0), data[:headerSize+10]} {
+		if err := new(Commitment).UnmarshalBinary(bad); err == nil {
+			t.Errorf("UnmarshalBinary() of %v bytes succeeded, want error", len(bad))
+		}
+	}
+	mat, _ := db.MarshalBinary()
+	if err := new(Commitment).UnmarshalBinary(mat); err == nil {
+		t.Errorf("UnmarshalBinary() of a matrix succeeded, want error")
+	}
+}
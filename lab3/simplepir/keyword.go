@@ -0,0 +1,241 @@
+package simplepir
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+)
+
+// KeywordHashes is the number of hash functions of the cuckoo table, and so the number of records a keyword
+// lookup retrieves, whether or not the key is present
+const KeywordHashes = 3
+
+// keywordTagSize is the size in bytes of the key tag stored in front of each value
+const keywordTagSize = 16
+
+// cuckoo table construction limits, see [NewKeywordDatabase]
+const (
+	cuckooMaxKicks    = 1000 // evictions before giving up on a hash seed
+	cuckooMaxAttempts = 32   // hash seeds tried before giving up
+)
+
+// customisation string for cSHAKE128, separating the eviction choices of the cuckoo table from other uses of its seed
+var cuckooDomain = []byte("simplepir cuckoo evictions")
+
+// KeywordLayout describes how key-value pairs are placed in a [Database], which clients need to look keys up
+//
+// Pairs are stored with cuckoo hashing: the pair for key lives in one of the [KeywordHashes] slots given by hashing
+// key with HashSeed, where each slot is a record of Records holding a tag of the key followed by the value.
+// A lookup retrieves all the slots, so the server learns neither the key nor whether it is present.
+type KeywordLayout struct {
+	Records   Layout // layout of the slots, each holding a record of keywordTagSize + ValueSize bytes
+	HashSeed  []byte // seed of the hash functions, [SeedSize] bytes
+	ValueSize int    // size of each value in bytes
+}
+
+// Validate checks that the layout is consistent, e.g. after receiving it from a server
+func (l KeywordLayout) Validate() error {
+	if err := l.Records.Validate(); err != nil {
+		return err
+	}
+	if len(l.HashSeed) != SeedSize {
+		return fmt.Errorf("hash seed must be %v bytes, got %v", SeedSize, len(l.HashSeed))
+	}
+	if l.ValueSize <= 0 || l.Records.RecordSize != keywordTagSize+l.ValueSize {
+		return fmt.Errorf("records of %v bytes cannot hold %v byte values", l.Records.RecordSize, l.ValueSize)
+	}
+	return nil
+}
+
+// keywordHash returns SHA-256(seed | label | key), where label separates the uses of the hash
+func keywordHash(seed []byte, label byte, key string) [sha256.Size]byte {
+	h := sha256.New()
+	h.Write(seed)
+	h.Write([]byte{label})
+	h.Write([]byte(key))
+	return [sha256.Size]byte(h.Sum(nil))
+}
+
+// slots returns the candidate slots of key, which may repeat for small tables
+func (l KeywordLayout) slots(key string) [KeywordHashes]int {
+	var result [KeywordHashes]int
+	for k := range result {
+		h := keywordHash(l.HashSeed, byte(k), key)
+		// the bias of reducing 64 bits mod the number of slots is negligible
+		result[k] = int(binary.LittleEndian.Uint64(h[:]) % uint64(l.Records.NumRecords))
+	}
+	return result
+}
+
+// tag returns the tag stored alongside the value of key, identifying it without storing the key itself
+func (l KeywordLayout) tag(key string) []byte {
+	h := keywordHash(l.HashSeed, KeywordHashes, key)
+	return h[:keywordTagSize]
+}
+
+// KeywordDatabase stores key-value pairs in a cuckoo table, so they can be looked up by key with a fixed
+// number of PIR queries
+//
+// hidden fields, construct with [NewKeywordDatabase]
+type KeywordDatabase struct {
+	db     *Database
+	layout KeywordLayout
+}
+
+// Creates a new [*KeywordDatabase] from entries, whose values must all have length valueSize
+//
+// The table has about 4/3 as many slots as entries, and each slot costs a tag of 16 bytes on top of the value.
+// Insertion is retried with a fresh hash seed on the rare occasions it fails.
+//
+// Usage:
+//
+//	kdb, err := NewKeywordDatabase(entries, valueSize, p)
+//	params.Rows, params.Cols = kdb.Layout().Records.Shape()
+//	server, err := NewServer(params, kdb.Mat())
+//	// the client looks up key with Client.QueryKeyword(kdb.Layout(), key) and Client.RecoverKeyword
+func NewKeywordDatabase(entries map[string][]byte, valueSize int, p *big.Int) (*KeywordDatabase, error) {
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("database must hold at least one entry")
+	}
+	if valueSize <= 0 {
+		return nil, fmt.Errorf("value size must be positive, got %v", valueSize)
+	}
+	keys := make([]string, 0, len(entries))
+	for key, value := range entries {
+		if len(value) != valueSize {
+			return nil, fmt.Errorf("value of key %q has size %v, expected %v", key, len(value), valueSize)
+		}
+		keys = append(keys, key)
+	}
+
+	numSlots := len(keys) + len(keys)/3 + KeywordHashes
+	for range cuckooMaxAttempts {
+		layout := KeywordLayout{HashSeed: NewSeed(), ValueSize: valueSize}
+		layout.Records.NumRecords = numSlots
+		table, ok := cuckooTable(layout, keys)
+		if !ok {
+			continue
+		}
+
+		records := make([][]byte, numSlots)
+		for slot, k := range table {
+			records[slot] = make([]byte, keywordTagSize+valueSize)
+			if k >= 0 {
+				copy(records[slot], layout.tag(keys[k]))
+				copy(records[slot][keywordTagSize:], entries[keys[k]])
+			}
+		}
+		db, err := NewDatabase(records, p)
+		if err != nil {
+			return nil, err
+		}
+		layout.Records = db.Layout()
+		return &KeywordDatabase{db: db, layout: layout}, nil
+	}
+	return nil, fmt.Errorf("could not build cuckoo table for %v entries", len(keys))
+}
+
+// cuckooTable places each key in one of its slots, returning for each slot the index of the key it holds or -1
+//
+// a key whose slots are all full evicts the occupant of a random one, which is then placed in turn.
+// Returns false if some key cannot be placed within [cuckooMaxKicks] evictions.
+func cuckooTable(layout KeywordLayout, keys []string) ([]int, bool) {
+	table := make([]int, layout.Records.NumRecords)
+	for i := range table {
+		table[i] = -1
+	}
+	r := newPRG(layout.HashSeed, cuckooDomain)
+	for k := range keys {
+		item, prev := k, -1
+		placed := false
+		for range cuckooMaxKicks {
+			slots := layout.slots(keys[item])
+			for _, slot := range slots {
+				if table[slot] < 0 {
+					table[slot], placed = item, true
+					break
+				}
+			}
+			if placed {
+				break
+			}
+			// evict from a random slot, avoiding sending the evicted key straight back to where it came from
+			victim := randIntFrom(r, 0, KeywordHashes-1)
+			if slots[victim] == prev {
+				victim = (victim + 1) % KeywordHashes
+			}
+			slot := slots[victim]
+			item, table[slot], prev = table[slot], item, slot
+		}
+		if !placed {
+			return nil, false
+		}
+	}
+	return table, true
+}
+
+// Layout returns the layout of the key-value pairs, which clients need to look keys up
+func (d *KeywordDatabase) Layout() KeywordLayout {
+	return d.layout
+}
+
+// Mat returns the database matrix, to be passed to [NewServer]
+//
+// The returned matrix is shared with the database and must not be modified. Updates to a server built from it
+// leave it unchanged, see [PreparedDB.UpdateBatch].
+func (d *KeywordDatabase) Mat() *Mat {
+	return d.db.Mat()
+}
+
+// KeywordState is the client-side state kept between querying for a key and recovering its value
+//
+// Fields are hidden as they include the secrets used to build each query
+type KeywordState struct {
+	tag     []byte
+	records []*RecordState
+	queries int // queries per record
+}
+
+// QueryKeyword builds the queries to look up key in a [KeywordDatabase] with the given layout
+//
+// Returns the queries for all [KeywordHashes] slots key could be in, the same number for every key, present or not.
+// Send them to the server and pass the answers, in the same order, to [Client.RecoverKeyword].
+func (c *Client) QueryKeyword(layout KeywordLayout, key string) (*KeywordState, []*Vec, error) {
+	if err := layout.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid keyword layout: %v", err)
+	}
+	st := &KeywordState{tag: layout.tag(key)}
+	var queries []*Vec
+	for _, slot := range layout.slots(key) {
+		rst, qus, err := c.QueryRecord(layout.Records, slot)
+		if err != nil {
+			return nil, nil, err
+		}
+		st.records = append(st.records, rst)
+		st.queries = len(qus)
+		queries = append(queries, qus...)
+	}
+	return st, queries, nil
+}
+
+// RecoverKeyword returns the value of the key queried by [Client.QueryKeyword], and whether the key is present
+func (c *Client) RecoverKeyword(st *KeywordState, answers []*Vec) ([]byte, bool, error) {
+	if st == nil {
+		return nil, false, fmt.Errorf("keyword state must not be nil")
+	}
+	if len(answers) != len(st.records)*st.queries {
+		return nil, false, fmt.Errorf("expected %v answers, got %v", len(st.records)*st.queries, len(answers))
+	}
+	for k, rst := range st.records {
+		record, err := c.RecoverRecord(rst, answers[k*st.queries:(k+1)*st.queries])
+		if err != nil {
+			return nil, false, err
+		}
+		if bytes.Equal(record[:keywordTagSize], st.tag) {
+			return record[keywordTagSize:], true, nil
+		}
+	}
+	return nil, false, nil
+}
@@ -0,0 +1,167 @@
+package simplepir
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"slices"
+	"testing"
+)
+
+// keywordEntries returns n entries mapping "user-i" to an 8-byte value
+func keywordEntries(n int) map[string][]byte {
+	entries := make(map[string][]byte, n)
+	for i := range n {
+		entries[fmt.Sprintf("user-%v", i)] = fmt.Appendf(nil, "%08d", i*7919)
+	}
+	return entries
+}
+
+func TestKeywordLookup(t *testing.T) { forEachBackend(t, testKeywordLookup) }
+
+func testKeywordLookup(t *testing.T, backend Backend) {
+	entries := keywordEntries(100)
+	kdb, err := NewKeywordDatabase(entries, 8, big.NewInt(991))
+	if err != nil {
+		t.Fatalf("NewKeywordDatabase() error = %v", err)
+	}
+	layout := kdb.Layout()
+	params := Params{N: 32, Q: new(big.Int).Lsh(big.NewInt(1), 32), P: big.NewInt(991), SqrtN: layout.Records.SqrtN, Backend: backend}
+	server, err := NewServer(params, kdb.Mat())
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	client, err := NewClientFromSeed(params, server.Seed(), server.Hint())
+	if err != nil {
+		t.Fatalf("NewClientFromSeed() error = %v", err)
+	}
+
+	lookup := func(key string) ([]byte, bool, int) {
+		st, queries, err := client.QueryKeyword(layout, key)
+		if err != nil {
+			t.Fatalf("QueryKeyword(%q) error = %v", key, err)
+		}
+		answers := make([]*Vec, len(queries))
+		for k, qu := range queries {
+			if answers[k], err = server.Answer(qu); err != nil {
+				t.Fatalf("Answer() error = %v", err)
+			}
+		}
+		value, found, err := client.RecoverKeyword(st, answers)
+		if err != nil {
+			t.Fatalf("RecoverKeyword(%q) error = %v", key, err)
+		}
+		return value, found, len(queries)
+	}
+
+	cols, _ := layout.Records.Columns(0)
+	wantQueries := KeywordHashes * len(cols)
+	for _, key := range []string{"user-0", "user-42", "user-99"} {
+		value, found, queries := lookup(key)
+		if !found || !bytes.Equal(value, entries[key]) {
+			t.Errorf("lookup(%q) = %q, %v, want %q, true", key, value, found, entries[key])
+		}
+		if queries != wantQueries {
+			t.Errorf("lookup(%q) sent %v queries, want %v", key, queries, wantQueries)
+		}
+	}
+	for _, key := range []string{"user-100", "", "admin"} {
+		value, found, queries := lookup(key)
+		if found {
+			t.Errorf("lookup(%q) = %q, true, want not found", key, value)
+		}
+		if queries != wantQueries {
+			t.Errorf("lookup(%q) of absent key sent %v queries, want %v", key, queries, wantQueries)
+		}
+	}
+
+	if _, _, err := client.RecoverKeyword(nil, nil); err == nil {
+		t.Errorf("RecoverKeyword() with nil state succeeded, want error")
+	}
+	st, queries, _ := client.QueryKeyword(layout, "user-0")
+	if _, _, err := client.RecoverKeyword(st, make([]*Vec, len(queries)-1)); err == nil {
+		t.Errorf("RecoverKeyword() with missing answers succeeded, want error")
+	}
+	bad := layout
+	bad.HashSeed = bad.HashSeed[1:]
+	if _, _, err := client.QueryKeyword(bad, "user-0"); err == nil {
+		t.Errorf("QueryKeyword() with invalid layout succeeded, want error")
+	}
+}
+
+func TestCuckooTable(t *testing.T) {
+	for _, n := range []int{1, 2, 10, 1000, 5000} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			entries := keywordEntries(n)
+			kdb, err := NewKeywordDatabase(entries, 8, big.NewInt(256))
+			if err != nil {
+				t.Fatalf("NewKeywordDatabase() error = %v", err)
+			}
+			layout := kdb.Layout()
+			if err := layout.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+
+			// every key's tag and value is in exactly one of its slots
+			records := make(map[int][]byte)
+			for key, value := range entries {
+				found := 0
+				for _, slot := range uniqueSlots(layout.slots(key)) {
+					record, ok := records[slot]
+					if !ok {
+						record = readRecord(kdb.db, slot)
+						records[slot] = record
+					}
+					if bytes.Equal(record[:keywordTagSize], layout.tag(key)) && bytes.Equal(record[keywordTagSize:], value) {
+						found++
+					}
+				}
+				if found != 1 {
+					t.Fatalf("key %q found in %v of its slots, want 1", key, found)
+				}
+			}
+		})
+	}
+}
+
+// uniqueSlots removes repeated slots
+func uniqueSlots(slots [KeywordHashes]int) []int {
+	var result []int
+	for _, s := range slots {
+		if !slices.Contains(result, s) {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// readRecord reads record i directly from the database matrix
+func readRecord(db *Database, i int) []byte {
+	cells, _ := db.layout.Cells(i)
+	values := make([]uint64, len(cells))
+	for k, c := range cells {
+		values[k] = db.mat.Get(c.Row, c.Col).Uint64()
+	}
+	return db.layout.decode(values)
+}
+
+func TestNewKeywordDatabaseErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		entries   map[string][]byte
+		valueSize int
+		p         *big.Int
+	}{
+		{"no entries", nil, 8, big.NewInt(256)},
+		{"zero value size", keywordEntries(3), 0, big.NewInt(256)},
+		{"wrong value size", keywordEntries(3), 7, big.NewInt(256)},
+		{"invalid p", keywordEntries(3), 8, big.NewInt(1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewKeywordDatabase(tt.entries, tt.valueSize, tt.p); err == nil {
+				t.Errorf("NewKeywordDatabase() succeeded, want error")
+			}
+		})
+	}
+}
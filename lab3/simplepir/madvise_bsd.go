@@ -0,0 +1,10 @@
+//go:build darwin || freebsd || netbsd || openbsd || dragonfly
+
+package simplepir
+
+// adviseSequential does nothing, as the syscall package only exposes madvise on Linux
+func adviseSequential(data []byte) {}
+
+// releasePages does nothing, as the syscall package only exposes madvise on Linux, the operating system still evicts
+// pages of the mapped file under memory pressure
+func releasePages(data []byte, lo, hi int) {}
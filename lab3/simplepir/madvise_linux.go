@@ -0,0 +1,27 @@
+package simplepir
+
+import (
+	"os"
+	"syscall"
+)
+
+// adviseSequential tells the operating system that data returned by [mapFile] is read in order, so it reads ahead
+// aggressively, as queries and the hint build both scan the file
+func adviseSequential(data []byte) {
+	_ = syscall.Madvise(data, syscall.MADV_SEQUENTIAL)
+}
+
+// madvCold is MADV_COLD from Linux 5.4, which the syscall package predates
+const madvCold = 20
+
+// releasePages tells the operating system that bytes [lo, hi) of data returned by [mapFile] are not needed for now
+//
+// only the whole pages within the range are released. MADV_COLD makes them the first to be evicted, unlike
+// MADV_DONTNEED it keeps entries written to the private mapping. Older kernels reject it, which is harmless.
+func releasePages(data []byte, lo, hi int) {
+	page := os.Getpagesize()
+	lo, hi = (lo+page-1)/page*page, hi/page*page
+	if lo < hi {
+		_ = syscall.Madvise(data[lo:hi], madvCold)
+	}
+}
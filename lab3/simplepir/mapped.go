@@ -0,0 +1,329 @@
+package simplepir
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"os"
+	"unsafe"
+)
+
+// matFileHeaderSize is the size of the header of a matrix file, a multiple of 4 so that the entries are aligned
+//
+//	header | 2 zero bytes | rows (uint32) | cols (uint32)
+const matFileHeaderSize = headerSize + 2 + 4 + 4
+
+// streamBytes is the number of bytes of entries in a block of rows processed at once when streaming a matrix,
+// a variable so that tests can stream through several blocks
+var streamBytes = 64 << 20
+
+// MatFileWriter writes a [Uint32Backend] matrix to a file row by row, in the format read by [OpenMatFile]
+//
+// The file is
+//
+//	header | 2 zero bytes | rows (uint32) | cols (uint32) | rows × cols uint32 entries
+//
+// with all integers little-endian, so a database larger than memory can be written without building it as a [*Mat].
+//
+// hidden fields, construct with [CreateMatFile]
+type MatFileWriter struct {
+	f          *os.File
+	w          *bufio.Writer
+	rows, cols int
+	written    int // number of rows written so far
+}
+
+// Creates a new [*MatFileWriter] for a rows × cols matrix, truncating the file at path if it exists
+func CreateMatFile(path string, rows, cols int) (*MatFileWriter, error) {
+	if rows <= 0 || cols <= 0 || uint64(rows) > 1<<32-1 || uint64(cols) > 1<<32-1 {
+		return nil, fmt.Errorf("invalid matrix dimensions %vx%v", rows, cols)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not create matrix file: %v", err)
+	}
+	w := bufio.NewWriter(f)
+	header := append(appendHeader(nil, kindMatFile), 0, 0)
+	header = binary.LittleEndian.AppendUint32(header, uint32(rows))
+	header = binary.LittleEndian.AppendUint32(header, uint32(cols))
+	if _, err := w.Write(header); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("could not write matrix file header: %v", err)
+	}
+	return &MatFileWriter{f: f, w: w, rows: rows, cols: cols}, nil
+}
+
+// WriteRow appends the next row of the matrix, which must hold one entry per column
+func (mw *MatFileWriter) WriteRow(row []uint32) error {
+	if len(row) != mw.cols {
+		return fmt.Errorf("%w: row has %v entries, matrix has %v columns", ErrDimensionMismatch, len(row), mw.cols)
+	}
+	if mw.written == mw.rows {
+		return fmt.Errorf("all %v rows have already been written", mw.rows)
+	}
+	var buf [4]byte
+	for _, x := range row {
+		binary.LittleEndian.PutUint32(buf[:], x)
+		if _, err := mw.w.Write(buf[:]); err != nil {
+			return fmt.Errorf("could not write row %v: %v", mw.written, err)
+		}
+	}
+	mw.written++
+	return nil
+}
+
+// Close flushes and closes the file, returning an error if fewer rows were written than the matrix has
+func (mw *MatFileWriter) Close() error {
+	err := mw.w.Flush()
+	if closeErr := mw.f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("could not write matrix file: %v", err)
+	}
+	if mw.written != mw.rows {
+		return fmt.Errorf("matrix file is incomplete, wrote %v of %v rows", mw.written, mw.rows)
+	}
+	return nil
+}
+
+// WriteMatFile writes m to a file at path, in the format read by [OpenMatFile]
+//
+// Every entry of m must be less than 2^32
+func WriteMatFile(path string, m *Mat) error {
+	if err := m.checkOperand(); err != nil {
+		return err
+	}
+	mw, err := CreateMatFile(path, m.rows, m.cols)
+	if err != nil {
+		return err
+	}
+	row := make([]uint32, m.cols)
+	for i := range m.rows {
+		for j := range m.cols {
+			x := m.Get(i, j)
+			if x.Sign() < 0 || x.Cmp(two32) >= 0 {
+				mw.Close()
+				return fmt.Errorf("entry (%v, %v) = %v does not fit in 32 bits", i, j, x)
+			}
+			row[j] = uint32(x.Uint64())
+		}
+		if err := mw.WriteRow(row); err != nil {
+			mw.Close()
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+// WriteDatabaseFile packs records as described by layout straight into a matrix file at path, in the format read
+// by [OpenMatFile], so that a database can be served mapped without ever building its matrix in memory
+//
+// The file holds the same matrix as [NewDatabaseWithLayout], and p is the plaintext modulus it will be served with
+func WriteDatabaseFile(path string, records [][]byte, layout Layout, p *big.Int) error {
+	if err := layout.checkRecords(records, p); err != nil {
+		return err
+	}
+	return writeDatabaseFile(path, layout, func(i int) []byte { return records[i] })
+}
+
+// WriteDatabaseFileFrom is [WriteDatabaseFile] for the records in the file at dbPath, split as by [SplitRecords]
+//
+// The records file is mapped rather than read, so that neither the records nor the matrix are ever held in memory.
+// On platforms without mmap the records file is read into memory instead.
+func WriteDatabaseFileFrom(path, dbPath string, recordSize int, layout Layout, p *big.Int) error {
+	if recordSize != layout.RecordSize {
+		return fmt.Errorf("layout holds records of %v bytes, got %v", layout.RecordSize, recordSize)
+	}
+	f, err := os.Open(dbPath)
+	if err != nil {
+		return fmt.Errorf("could not open records file: %v", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("could not open records file: %v", err)
+	}
+	size := int(info.Size())
+	if int64(size) != info.Size() {
+		return fmt.Errorf("records file of %v bytes is too large to map", info.Size())
+	}
+	if err := layout.checkCount((size+recordSize-1)/recordSize, p); err != nil {
+		return err
+	}
+	blob, err := mapFile(f, size)
+	if err != nil {
+		return fmt.Errorf("could not map records file: %v", err)
+	}
+	defer unmapFile(blob)
+	last := make([]byte, recordSize) // the final record, padded with zeros
+	copy(last, blob[(layout.NumRecords-1)*recordSize:])
+	return writeDatabaseFile(path, layout, func(i int) []byte {
+		if i == layout.NumRecords-1 {
+			return last
+		}
+		return blob[i*recordSize : (i+1)*recordSize]
+	})
+}
+
+// writeDatabaseFile writes the matrix file for a layout whose records have been checked, record(i) returning
+// record i
+func writeDatabaseFile(path string, layout Layout, record func(i int) []byte) error {
+	rows, cols := layout.Shape()
+	mw, err := CreateMatFile(path, rows, cols)
+	if err != nil {
+		return err
+	}
+	row := make([]uint32, cols)
+	for r := range rows {
+		for c := range cols {
+			row[c] = 0
+			if i, k, ok := layout.at(r, c); ok {
+				row[c] = layout.encodeCell(record(i), k)
+			}
+		}
+		if err := mw.WriteRow(row); err != nil {
+			mw.Close()
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+// MappedMat is a [Uint32Backend] matrix whose entries are read straight from a file mapped into memory
+//
+// The operating system pages the file in as it is read and can evict the pages again, so a database larger than
+// memory can be answered from with a sequential pass per query. Pass [MappedMat.Mat] to [Prepare], which streams
+// through the file to build the hint. The parameters must use [Uint32Backend], or the database is copied into memory.
+//
+// Updates never write to the mapping: the first [PreparedDB.UpdateBatch] copies the database into memory, so
+// a database that is updated must fit in memory.
+//
+// hidden fields, construct with [OpenMatFile]
+type MappedMat struct {
+	mat  *Mat
+	data []byte
+}
+
+// Opens the matrix file at path, written by [MatFileWriter], and maps it into memory
+//
+// On platforms without mmap the file is read into memory instead
+func OpenMatFile(path string) (*MappedMat, error) {
+	if binary.NativeEndian.Uint32([]byte{1, 0, 0, 0}) != 1 {
+		return nil, fmt.Errorf("matrix files can only be mapped on little-endian platforms")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open matrix file: %v", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("could not open matrix file: %v", err)
+	}
+	if info.Size() < int64(matFileHeaderSize) {
+		return nil, fmt.Errorf("matrix file too short, got %v bytes", info.Size())
+	}
+	header := make([]byte, matFileHeaderSize)
+	if _, err := f.ReadAt(header, 0); err != nil {
+		return nil, fmt.Errorf("could not read matrix file header: %v", err)
+	}
+	rest, err := readHeader(header, kindMatFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read matrix file header: %v", err)
+	}
+	rows, cols := binary.LittleEndian.Uint32(rest[2:]), binary.LittleEndian.Uint32(rest[6:])
+	if rows == 0 || cols == 0 {
+		return nil, fmt.Errorf("%w: matrix file holds a %vx%v matrix", ErrEmpty, rows, cols)
+	}
+	if want := int64(matFileHeaderSize) + 4*int64(rows)*int64(cols); info.Size() != want {
+		return nil, fmt.Errorf("matrix file for %vx%v matrix must be %v bytes, got %v", rows, cols, want, info.Size())
+	}
+
+	data, err := mapFile(f, int(info.Size()))
+	if err != nil {
+		return nil, fmt.Errorf("could not map matrix file: %v", err)
+	}
+	n := int(rows) * int(cols)
+	mat := &Mat{
+		packed:  unsafe.Slice((*uint32)(unsafe.Pointer(&data[matFileHeaderSize])), n),
+		rows:    int(rows),
+		cols:    int(cols),
+		backend: Uint32Backend,
+	}
+	mat.release = func(lo, hi int) {
+		releasePages(data, matFileHeaderSize+4*lo*mat.cols, matFileHeaderSize+4*hi*mat.cols)
+	}
+	return &MappedMat{mat: mat, data: data}, nil
+}
+
+// Mat returns the mapped matrix, which must not be used after [MappedMat.Close]
+func (mm *MappedMat) Mat() *Mat {
+	return mm.mat
+}
+
+// Close unmaps the file
+//
+// Any server or prepared database still using the matrix must be done with it first, as reading an unmapped
+// matrix crashes the program
+func (mm *MappedMat) Close() error {
+	if mm.data == nil {
+		return nil
+	}
+	data := mm.data
+	mm.data, mm.mat.packed = nil, nil
+	return unmapFile(data)
+}
+
+// rowBlock returns rows [lo, hi) of m as a matrix sharing its storage with m
+func (m *Mat) rowBlock(lo, hi int) *Mat {
+	if m.backend == Uint32Backend {
+		return &Mat{packed: m.packed[lo*m.cols : hi*m.cols], rows: hi - lo, cols: m.cols, backend: m.backend}
+	}
+	return &Mat{data: m.data[lo:hi], rows: hi - lo, cols: m.cols, backend: m.backend}
+}
+
+// streamRows calls f on consecutive blocks of rows [lo, hi) of m holding about [streamBytes] of entries
+//
+// for a mapped matrix, each block is released once f returns so that at most one block of the file need be resident
+func (m *Mat) streamRows(f func(lo, hi int)) {
+	step := max(1, streamBytes/(4*m.cols))
+	for lo := 0; lo < m.rows; lo += step {
+		hi := min(lo+step, m.rows)
+		f(lo, hi)
+		if m.release != nil {
+			m.release(lo, hi)
+		}
+	}
+}
+
+// checkBelow returns an error if any entry of m is not in [0, p)
+//
+// streams through the rows of m, reading [Uint32Backend] entries directly rather than through [Mat.Get]
+func (m *Mat) checkBelow(p *big.Int) error {
+	if m.backend != Uint32Backend {
+		for i := range m.rows {
+			for j := range m.cols {
+				if x := m.data[i][j]; x.Sign() < 0 || x.Cmp(p) >= 0 {
+					return fmt.Errorf("entry (%v, %v) = %v is not in [0, %v)", i, j, x, p)
+				}
+			}
+		}
+		return nil
+	}
+	if p.Cmp(two32) >= 0 {
+		return nil
+	}
+	bound := uint32(p.Uint64())
+	var err error
+	m.streamRows(func(lo, hi int) {
+		for k, x := range m.packed[lo*m.cols : hi*m.cols] {
+			if x >= bound && err == nil {
+				i, j := lo+k/m.cols, k%m.cols
+				err = fmt.Errorf("entry (%v, %v) = %v is not in [0, %v)", i, j, x, p)
+			}
+		}
+	})
+	return err
+}
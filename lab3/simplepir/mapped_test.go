@@ -0,0 +1,261 @@
+package simplepir
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMatFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.mat")
+	m := NewMatWith(5, 7, Uint32Backend).FillRandom(big.NewInt(1 << 32))
+	if err := WriteMatFile(path, m); err != nil {
+		t.Fatalf("WriteMatFile() error = %v", err)
+	}
+	mm, err := OpenMatFile(path)
+	if err != nil {
+		t.Fatalf("OpenMatFile() error = %v", err)
+	}
+	got := mm.Mat()
+	if got.Rows() != 5 || got.Cols() != 7 || got.Backend() != Uint32Backend || !reflect.DeepEqual(got.packed, m.packed) {
+		t.Errorf("OpenMatFile() = %v, want %v", got.packed, m.packed)
+	}
+
+	// writes stay in memory rather than reaching the file
+	got.Set(0, 0, big.NewInt(int64(m.packed[0])+1))
+	if err := mm.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := mm.Close(); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+	mm, err = OpenMatFile(path)
+	if err != nil {
+		t.Fatalf("OpenMatFile() error = %v", err)
+	}
+	defer mm.Close()
+	if x := mm.Mat().packed[0]; x != m.packed[0] {
+		t.Errorf("entry (0, 0) in file = %v after writing to the mapping, want %v", x, m.packed[0])
+	}
+}
+
+func TestMatFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.mat")
+	if err := WriteMatFile(valid, NewMatWith(2, 3, Uint32Backend).Fill([]int64{1, 2, 3, 4, 5, 6})); err != nil {
+		t.Fatalf("WriteMatFile() error = %v", err)
+	}
+	data, err := os.ReadFile(valid)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	corrupt := func(name string, f func(b []byte) []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, f(append([]byte(nil), data...)), 0o644); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"missing", filepath.Join(dir, "missing.mat")},
+		{"too short", corrupt("short.mat", func(b []byte) []byte { return b[:matFileHeaderSize-1] })},
+		{"truncated entries", corrupt("truncated.mat", func(b []byte) []byte { return b[:len(b)-1] })},
+		{"extra entries", corrupt("extra.mat", func(b []byte) []byte { return append(b, 0, 0, 0, 0) })},
+		{"bad magic", corrupt("magic.mat", func(b []byte) []byte { b[0] = 'X'; return b })},
+		{"wrong kind", corrupt("kind.mat", func(b []byte) []byte { b[headerSize-1] = byte(kindMat); return b })},
+		{"no rows", corrupt("empty.mat", func(b []byte) []byte { clear(b[headerSize+2 : headerSize+6]); return b[:matFileHeaderSize] })},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if mm, err := OpenMatFile(tt.path); err == nil {
+				mm.Close()
+				t.Errorf("OpenMatFile() succeeded, want error")
+			}
+		})
+	}
+
+	t.Run("writer", func(t *testing.T) {
+		mw, err := CreateMatFile(filepath.Join(dir, "partial.mat"), 2, 3)
+		if err != nil {
+			t.Fatalf("CreateMatFile() error = %v", err)
+		}
+		if err := mw.WriteRow([]uint32{1, 2}); !errors.Is(err, ErrDimensionMismatch) {
+			t.Errorf("WriteRow() of short row error = %v, want %v", err, ErrDimensionMismatch)
+		}
+		if err := mw.WriteRow([]uint32{1, 2, 3}); err != nil {
+			t.Errorf("WriteRow() error = %v", err)
+		}
+		if err := mw.Close(); err == nil {
+			t.Errorf("Close() with missing rows succeeded, want error")
+		}
+		if _, err := CreateMatFile(filepath.Join(dir, "zero.mat"), 0, 3); err == nil {
+			t.Errorf("CreateMatFile() with no rows succeeded, want error")
+		}
+		wide := NewMatWith(1, 1, BigBackend).Fill([]int64{1 << 40})
+		if err := WriteMatFile(filepath.Join(dir, "wide.mat"), wide); err == nil {
+			t.Errorf("WriteMatFile() with an entry above 2^32 succeeded, want error")
+		}
+	})
+}
+
+func TestWriteDatabaseFile(t *testing.T) {
+	p := big.NewInt(991)
+	records := make([][]byte, 37)
+	for i := range records {
+		records[i] = make([]byte, 5)
+		rand.Read(records[i])
+	}
+	square, _ := SquareLayout(len(records), 5, p)
+	tests := []struct {
+		name   string
+		layout Layout
+	}{
+		{"square", square},
+		{"tall", square.withRows(50)},
+		{"records spanning columns", square.withRows(2)},
+		{"one row", square.withRows(1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "db.mat")
+			if err := WriteDatabaseFile(path, records, tt.layout, p); err != nil {
+				t.Fatalf("WriteDatabaseFile() error = %v", err)
+			}
+			mm, err := OpenMatFile(path)
+			if err != nil {
+				t.Fatalf("OpenMatFile() error = %v", err)
+			}
+			defer mm.Close()
+			want, _ := NewDatabaseWithLayout(records, tt.layout, p)
+			if got := mm.Mat(); !reflect.DeepEqual(got.packed, want.Mat().packed) {
+				t.Errorf("matrix file does not hold the database matrix")
+			}
+		})
+	}
+
+	if err := WriteDatabaseFile(filepath.Join(t.TempDir(), "db.mat"), records[1:], square, p); err == nil {
+		t.Errorf("WriteDatabaseFile() with too few records succeeded, want error")
+	}
+}
+
+func TestWriteDatabaseFileFrom(t *testing.T) {
+	p := big.NewInt(991)
+	dir := t.TempDir()
+	blob := make([]byte, 37*5-2) // the final record is padded
+	rand.Read(blob)
+	dbPath := filepath.Join(dir, "records.bin")
+	if err := os.WriteFile(dbPath, blob, 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	records, _ := SplitRecords(blob, 5)
+	square, _ := SquareLayout(len(records), 5, p)
+
+	for _, layout := range []Layout{square, square.withRows(2)} {
+		path := filepath.Join(dir, "db.mat")
+		if err := WriteDatabaseFileFrom(path, dbPath, 5, layout, p); err != nil {
+			t.Fatalf("WriteDatabaseFileFrom() error = %v", err)
+		}
+		mm, err := OpenMatFile(path)
+		if err != nil {
+			t.Fatalf("OpenMatFile() error = %v", err)
+		}
+		want, _ := NewDatabaseWithLayout(records, layout, p)
+		if got := mm.Mat(); !reflect.DeepEqual(got.packed, want.Mat().packed) {
+			rows, cols := layout.Shape()
+			t.Errorf("matrix file for the %vx%v layout does not hold the database matrix", rows, cols)
+		}
+		mm.Close()
+	}
+
+	other, _ := SquareLayout(len(records)+1, 5, p)
+	tests := []struct {
+		name       string
+		dbPath     string
+		recordSize int
+		layout     Layout
+	}{
+		{"missing file", filepath.Join(dir, "missing.bin"), 5, square},
+		{"wrong record size", dbPath, 4, square},
+		{"wrong number of records", dbPath, 5, other},
+	}
+	for _, tt := range tests {
+		if err := WriteDatabaseFileFrom(filepath.Join(dir, "db.mat"), tt.dbPath, tt.recordSize, tt.layout, p); err == nil {
+			t.Errorf("WriteDatabaseFileFrom() with %v succeeded, want error", tt.name)
+		}
+	}
+}
+
+func TestMappedServer(t *testing.T) {
+	// stream through the database a couple of rows at a time
+	defer func(n int) { streamBytes = n }(streamBytes)
+	streamBytes = 2 * 4 * 12
+
+	params := Params{N: 64, Q: big.NewInt(1 << 32), P: big.NewInt(991), Rows: 9, Cols: 12, Backend: Uint32Backend}
+	db := NewMatWith(9, 12, Uint32Backend).FillRandom(params.P)
+	path := filepath.Join(t.TempDir(), "db.mat")
+	if err := WriteMatFile(path, db); err != nil {
+		t.Fatalf("WriteMatFile() error = %v", err)
+	}
+	mm, err := OpenMatFile(path)
+	if err != nil {
+		t.Fatalf("OpenMatFile() error = %v", err)
+	}
+	defer mm.Close()
+
+	server, err := NewServer(params, mm.Mat())
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	A, _ := ExpandA(server.Seed(), params)
+	if want := pirSetup(db, A, params); !reflect.DeepEqual(server.Hint(), want) {
+		t.Errorf("streamed hint does not match the in-memory hint")
+	}
+
+	client, err := NewClientFromSeed(params, server.Seed(), server.Hint())
+	if err != nil {
+		t.Fatalf("NewClientFromSeed() error = %v", err)
+	}
+	for _, c := range []Cell{{0, 0}, {4, 7}, {8, 11}} {
+		st, qu, _ := client.Query(c.Row, c.Col)
+		ans, err := server.Answer(qu)
+		if err != nil {
+			t.Fatalf("Answer() error = %v", err)
+		}
+		if got, err := client.Recover(st, ans); err != nil || got != db.Get(c.Row, c.Col).Uint64() {
+			t.Errorf("Recover() at %v = %v, %v, want %v", c, got, err, db.Get(c.Row, c.Col))
+		}
+	}
+
+	// updates copy the database into memory, leaving the mapping untouched
+	if _, err := server.Update(4, 7, big.NewInt(3)); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if got := mm.Mat().Get(4, 7); got.Cmp(db.Get(4, 7)) != 0 {
+		t.Errorf("mapped entry after Update() = %v, want %v", got, db.Get(4, 7))
+	}
+
+	t.Run("entry out of range", func(t *testing.T) {
+		bad := db.clone()
+		bad.Set(8, 3, params.P)
+		path := filepath.Join(t.TempDir(), "bad.mat")
+		if err := WriteMatFile(path, bad); err != nil {
+			t.Fatalf("WriteMatFile() error = %v", err)
+		}
+		mm, err := OpenMatFile(path)
+		if err != nil {
+			t.Fatalf("OpenMatFile() error = %v", err)
+		}
+		defer mm.Close()
+		if _, err := NewServer(params, mm.Mat()); err == nil {
+			t.Errorf("NewServer() with entry not in Z_p succeeded, want error")
+		}
+	})
+}
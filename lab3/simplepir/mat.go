@@ -2,18 +2,48 @@ package simplepir
 
 import (
 	"crypto/rand"
+	"errors"
 	"fmt"
+	"io"
 	"math/big"
 )
 
+// ErrEmpty is returned when a matrix or vector has, or would have, no entries
+var ErrEmpty = errors.New("matrix or vector has no entries")
+
+// ErrDimensionMismatch is returned when the dimensions of the operands of an operation do not fit together
+var ErrDimensionMismatch = errors.New("dimension mismatch")
+
 type Mat struct {
-	data       [][]*big.Int
+	data       [][]*big.Int // entries for [BigBackend]
+	packed     []uint32     // row-major entries for [Uint32Backend]
 	rows, cols int
+	backend    Backend
+	release    func(lo, hi int) // releases the pages of rows [lo, hi), only set for a matrix mapped by [OpenMatFile]
 }
 
 func NewMat(rows, cols int) *Mat {
+	return NewMatWith(rows, cols, BigBackend)
+}
+
+// Creates a new zero matrix of the given dimensions, storing its entries using the given [Backend]
+//
+// Panics on non-positive dimensions or an unknown backend, see [NewMatChecked]
+func NewMatWith(rows, cols int, backend Backend) *Mat {
+	return must(NewMatChecked(rows, cols, backend))
+}
+
+// NewMatChecked is [NewMatWith], but returns an error rather than panicking, wrapping [ErrEmpty] if a dimension
+// is not positive
+func NewMatChecked(rows, cols int, backend Backend) (*Mat, error) {
 	if rows <= 0 || cols <= 0 {
-		panic("cannot initialise matrix with non-positive dimension")
+		return nil, fmt.Errorf("%w: cannot initialise %vx%v matrix", ErrEmpty, rows, cols)
+	}
+	if err := checkBackend(backend); err != nil {
+		return nil, err
+	}
+	if backend == Uint32Backend {
+		return &Mat{packed: make([]uint32, rows*cols), rows: rows, cols: cols, backend: backend}, nil
 	}
 	result := make([][]*big.Int, rows)
 	for row := range rows {
@@ -22,104 +52,240 @@ func NewMat(rows, cols int) *Mat {
 			result[row][col] = big.NewInt(0)
 		}
 	}
-	return &Mat{data: result, rows: rows, cols: cols}
+	return &Mat{data: result, rows: rows, cols: cols, backend: backend}, nil
 }
 
-func (m1 *Mat) MatMul(m2 *Mat, mod *big.Int) *Mat {
-	// check dimensions, if improper, panic
-	if m1.rows == 0 || m1.cols == 0 || m2.cols == 0 || m1.cols != m2.rows {
-		panic(fmt.Sprintf("incompatible dimensions (%v, %v) and (%v, %v)", m1.rows, m1.cols, m2.rows, m2.cols))
-	}
-
-	rows := m1.rows
-	cols := m2.cols
-	inner := m1.cols
-
-	result := NewMat(rows, cols)
-
-	temp := new(big.Int)
-	for i := range rows {
-		for j := range cols {
-			sum := big.NewInt(0)
-			for k := range inner {
-				// multiply elements, store in temp
-				temp.Mul(m1.data[i][k], m2.data[k][j])
-				// take modulus, store in temp
-				temp.Mod(temp, mod)
-				// add temp to sum
-				sum.Add(sum, temp)
-				// take modulus of sum
-				sum.Mod(sum, mod)
-			}
-			// put sum in array
-			result.data[i][j].Set(sum)
+// must returns x, panicking if err is not nil, for the panicking counterparts of the checked API
+func must[T any](x T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return x
+}
+
+// checkOperand returns an error wrapping [ErrEmpty] if m is nil or has no entries, or if its backend is unknown
+//
+// only the zero value and the constructors are expected, so the storage is checked against the dimensions but
+// not every row of a [BigBackend] matrix
+func (m *Mat) checkOperand() error {
+	if m == nil || m.rows <= 0 || m.cols <= 0 {
+		return fmt.Errorf("%w: matrix operand", ErrEmpty)
+	}
+	if err := checkBackend(m.backend); err != nil {
+		return err
+	}
+	if (m.backend == Uint32Backend && len(m.packed) != m.rows*m.cols) || (m.backend == BigBackend && len(m.data) != m.rows) {
+		return fmt.Errorf("%w: %vx%v matrix has no storage for its entries", ErrEmpty, m.rows, m.cols)
+	}
+	return nil
+}
+
+// Rows returns the number of rows of the matrix
+func (m *Mat) Rows() int {
+	return m.rows
+}
+
+// Cols returns the number of columns of the matrix
+func (m *Mat) Cols() int {
+	return m.cols
+}
+
+// Backend returns the [Backend] used to store the matrix's entries
+func (m *Mat) Backend() Backend {
+	return m.backend
+}
+
+// Get returns a copy of the entry at (i, j)
+func (m *Mat) Get(i, j int) *big.Int {
+	if m.backend == Uint32Backend {
+		return new(big.Int).SetUint64(uint64(m.packed[i*m.cols+j]))
+	}
+	return new(big.Int).Set(m.data[i][j])
+}
+
+// Set sets the entry at (i, j) to x
+//
+// For [Uint32Backend], x is stored modulo 2^32
+//
+// returns m
+func (m *Mat) Set(i, j int, x *big.Int) *Mat {
+	if m.backend == Uint32Backend {
+		m.packed[i*m.cols+j] = toUint32(x)
+	} else {
+		m.data[i][j].Set(x)
+	}
+	return m
+}
+
+// as returns m stored using backend b, converting (and copying) only if necessary
+func (m *Mat) as(b Backend) *Mat {
+	if m.backend == b {
+		return m
+	}
+	result := NewMatWith(m.rows, m.cols, b)
+	for i := range m.rows {
+		for j := range m.cols {
+			result.Set(i, j, m.Get(i, j))
 		}
 	}
 	return result
 }
 
+// clone returns a deep copy of m
+func (m *Mat) clone() *Mat {
+	result := NewMatWith(m.rows, m.cols, m.backend)
+	if m.backend == Uint32Backend {
+		copy(result.packed, m.packed)
+		return result
+	}
+	for i := range m.rows {
+		for j := range m.cols {
+			result.data[i][j].Set(m.data[i][j])
+		}
+	}
+	return result
+}
+
+func (m1 *Mat) MatMul(m2 *Mat, mod *big.Int) *Mat {
+	return m1.MatMulParallel(m2, mod, 1)
+}
+
+// MatMulChecked is [Mat.MatMul], but returns an error rather than panicking, see [Mat.MatMulParallelChecked]
+func (m1 *Mat) MatMulChecked(m2 *Mat, mod *big.Int) (*Mat, error) {
+	return m1.MatMulParallelChecked(m2, mod, 1)
+}
+
 // Matrix multiplication of a vector, modulo mod
 //
 // Returns a new vector with the result
 func (m *Mat) VecMul(v *Vec, mod *big.Int) *Vec {
-	// check dimensions, if improper, panic
-	if m.rows == 0 || m.cols == 0 || m.cols != v.size {
-		panic(fmt.Sprintf("incompatible dimensions (%v, %v) and (%v)", m.rows, m.cols, v.size))
-	}
+	return m.VecMulParallel(v, mod, 1)
+}
 
-	result := NewVec(v.size)
+// VecMulChecked is [Mat.VecMul], but returns an error rather than panicking, see [Mat.VecMulParallelChecked]
+func (m *Mat) VecMulChecked(v *Vec, mod *big.Int) (*Vec, error) {
+	return m.VecMulParallelChecked(v, mod, 1)
+}
 
-	temp := new(big.Int)
-	for i := range m.rows {
-		sum := big.NewInt(0)
-		for j := range v.size {
-			// multiply elements, store in temp
-			temp.Mul(m.data[i][j], v.data[j])
-			// take modulus, store in temp
-			temp.Mod(temp, mod)
-			// add temp to sum
-			sum.Add(sum, temp)
-			// take modulus of sum
-			sum.Mod(sum, mod)
-
-			// put sum in vector
-			result.data[i].Set(sum)
-		}
+// SetVecMul sets z to m·v (modulo mod) and returns z, like [big.Int.Mul]
+//
+// z must have one entry per row of m and use the backend of m, and must not be v, as v is read while z is written.
+// Panics on invalid operands as for [Mat.VecMul].
+func (z *Vec) SetVecMul(m *Mat, v *Vec, mod *big.Int) *Vec {
+	if err := z.checkMulDest(m, v, mod); err != nil {
+		panic(err)
 	}
-	return result
+	return m.vecMulParallel(v, z, mod, 1)
+}
 
+// checkMulDest checks that m·v (modulo mod) can be stored in z
+func (z *Vec) checkMulDest(m *Mat, v *Vec, mod *big.Int) error {
+	if err := m.checkVecMul(v, mod); err != nil {
+		return err
+	}
+	if err := z.checkOperand(); err != nil {
+		return err
+	}
+	if z.size != m.rows || z.backend != m.backend {
+		return fmt.Errorf("%w: destination must be a %v vector of size %v", ErrDimensionMismatch, m.backend, m.rows)
+	}
+	if z == v {
+		return fmt.Errorf("destination must not be the vector being multiplied")
+	}
+	return nil
 }
 
 // Fills the matrix with the data in the int64 slice
 //
+// For [Uint32Backend], values are stored modulo 2^32
+//
 // Returns a pointer to the filled matrix for convenience
 func (m *Mat) Fill(data []int64) *Mat {
-	if m.rows == 0 || m.cols == 0 {
-		panic("nil matrix")
+	return must(m.FillChecked(data))
+}
+
+// FillChecked is [Mat.Fill], but returns an error rather than panicking, wrapping [ErrEmpty] for an empty matrix
+// and [ErrDimensionMismatch] if data does not hold exactly one value per entry
+func (m *Mat) FillChecked(data []int64) (*Mat, error) {
+	if err := m.checkOperand(); err != nil {
+		return nil, err
 	}
 	if m.rows*m.cols != len(data) {
-		panic(fmt.Sprintf("size mismatch, got %vx%v and %v", m.rows, m.cols, len(data)))
+		return nil, fmt.Errorf("%w: got %vx%v matrix and %v values", ErrDimensionMismatch, m.rows, m.cols, len(data))
+	}
+	if m.backend == Uint32Backend {
+		for i, x := range data {
+			m.packed[i] = uint32(x)
+		}
+		return m, nil
 	}
 	for i := range m.rows {
 		for j := range m.cols {
 			m.data[i][j].SetInt64(data[i*m.cols+j])
 		}
 	}
-	return m
+	return m, nil
 }
 
 // fills the matrix with random [*big.Int]s in the range [0,max)
 //
 // returns the newly filled matrix
 func (m *Mat) FillRandom(max *big.Int) *Mat {
+	return m.FillRandomFrom(rand.Reader, max)
+}
+
+// fills the matrix with values in the range [0,max) read from r, deterministic for a deterministic r
+//
+// r can be e.g. [NewPRG] to make a run reproducible
+func (m *Mat) FillRandomFrom(r io.Reader, max *big.Int) *Mat {
+	if m.backend == Uint32Backend {
+		randUint32s(r, m.packed, max)
+		return m
+	}
 	for i := range m.rows {
 		for j := range m.cols {
-			r, err := rand.Int(rand.Reader, max)
-			if err != nil {
-				panic(err)
-			}
-			m.data[i][j].Set(r)
+			m.data[i][j].Set(uniformFrom(r, max))
 		}
 	}
 	return m
 }
+
+// setCol copies the entries of v into column j of m
+func (m *Mat) setCol(j int, v *Vec) {
+	if v.size != m.rows {
+		panic(fmt.Sprintf("size mismatch, got %v rows and vector of size %v", m.rows, v.size))
+	}
+	if m.backend == Uint32Backend && v.backend == Uint32Backend {
+		for i, x := range v.packed {
+			m.packed[i*m.cols+j] = x
+		}
+		return
+	}
+	for i := range m.rows {
+		m.Set(i, j, v.Get(i))
+	}
+}
+
+// col returns a new vector holding column j of m
+func (m *Mat) col(j int) *Vec {
+	v := NewVecWith(m.rows, m.backend)
+	for i := range m.rows {
+		v.Set(i, m.Get(i, j))
+	}
+	return v
+}
+
+// transpose returns a new matrix holding the transpose of m
+func (m *Mat) transpose() *Mat {
+	result := NewMatWith(m.cols, m.rows, m.backend)
+	for i := range m.rows {
+		for j := range m.cols {
+			if m.backend == Uint32Backend {
+				result.packed[j*m.rows+i] = m.packed[i*m.cols+j]
+			} else {
+				result.data[j][i].Set(m.data[i][j])
+			}
+		}
+	}
+	return result
+}
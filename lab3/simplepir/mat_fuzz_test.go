@@ -0,0 +1,223 @@
+package simplepir
+
+import (
+	"encoding/binary"
+	"math/big"
+	"testing"
+)
+
+// naiveMatMul is the textbook triple loop over big.Int entries, the reference the optimised products are checked against
+func naiveMatMul(m1, m2 *Mat, mod *big.Int) [][]*big.Int {
+	result := make([][]*big.Int, m1.rows)
+	for i := range m1.rows {
+		result[i] = make([]*big.Int, m2.cols)
+		for j := range m2.cols {
+			sum := new(big.Int)
+			for k := range m1.cols {
+				sum.Add(sum, new(big.Int).Mul(m1.Get(i, k), m2.Get(k, j)))
+			}
+			result[i][j] = sum.Mod(sum, mod)
+		}
+	}
+	return result
+}
+
+// naiveVecMul is the textbook double loop over big.Int entries, with one result entry per row of m
+func naiveVecMul(m *Mat, v *Vec, mod *big.Int) []*big.Int {
+	result := make([]*big.Int, m.rows)
+	for i := range m.rows {
+		sum := new(big.Int)
+		for j := range m.cols {
+			sum.Add(sum, new(big.Int).Mul(m.Get(i, j), v.Get(j)))
+		}
+		result[i] = sum.Mod(sum, mod)
+	}
+	return result
+}
+
+// matEqual reports whether m holds exactly the entries of want
+func matEqual(m *Mat, want [][]*big.Int) bool {
+	if m.rows != len(want) {
+		return false
+	}
+	for i := range want {
+		if m.cols != len(want[i]) {
+			return false
+		}
+		for j := range want[i] {
+			if m.Get(i, j).Cmp(want[i][j]) != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// vecEqual reports whether v holds exactly the entries of want
+func vecEqual(v *Vec, want []*big.Int) bool {
+	if v.size != len(want) {
+		return false
+	}
+	for i := range want {
+		if v.Get(i).Cmp(want[i]) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// vecEntries returns the entries of v
+func vecEntries(v *Vec) []*big.Int {
+	entries := make([]*big.Int, v.size)
+	for i := range entries {
+		entries[i] = v.Get(i)
+	}
+	return entries
+}
+
+// fuzzOperands derives a modulus and random operands of the given dimensions from the fuzzer's inputs
+//
+// the low 7 bits of modBits pick the size of the modulus, at most 2^32 for [Uint32Backend] and 2^100 for
+// [BigBackend], and the top bit picks a modulus that is not a power of two, so both uint32 code paths are covered.
+// extreme fills every entry with mod-1 instead of random values, the worst case for overflow.
+func fuzzOperands(seed uint64, modBits uint8, extreme bool, backend Backend, dims ...int) (*big.Int, []*Mat, *Vec) {
+	var seedBytes [SeedSize]byte
+	binary.LittleEndian.PutUint64(seedBytes[:], seed)
+	r := newPRG(seedBytes[:], []byte("simplepir fuzz"))
+
+	maxBits := uint(100)
+	if backend == Uint32Backend {
+		maxBits = 32
+	}
+	bits := 1 + uint(modBits&0x7f)%maxBits
+	mod := new(big.Int).Lsh(big.NewInt(1), bits)
+	if modBits&0x80 != 0 {
+		// a modulus such as a prime p, rather than a power of two
+		mod.Sub(mod, uniformFrom(r, new(big.Int).Rsh(mod, 1)))
+	}
+	fill := func(m *Mat) *Mat {
+		if !extreme {
+			return m.FillRandomFrom(r, mod)
+		}
+		top := new(big.Int).Sub(mod, big.NewInt(1))
+		for i := range m.rows {
+			for j := range m.cols {
+				m.Set(i, j, top)
+			}
+		}
+		return m
+	}
+
+	mats := make([]*Mat, len(dims)-1)
+	for k := range mats {
+		mats[k] = fill(NewMatWith(dims[k], dims[k+1], backend))
+	}
+	v := NewVecWith(dims[len(dims)-1], backend)
+	col := fill(NewMatWith(v.size, 1, backend))
+	for i := range v.size {
+		v.Set(i, col.Get(i, 0))
+	}
+	return mod, mats, v
+}
+
+// fuzzDims maps the fuzzer's bytes to dimensions in [1, 80], which covers both the narrow and tiled uint32 products
+func fuzzDims(raw ...uint8) []int {
+	dims := make([]int, len(raw))
+	for k, d := range raw {
+		dims[k] = 1 + int(d)%80
+	}
+	return dims
+}
+
+func FuzzMatMul(f *testing.F) {
+	f.Add(uint64(1), uint8(31), uint8(2), uint8(3), uint8(4), uint8(5), false, false)
+	f.Add(uint64(2), uint8(31), uint8(7), uint8(1), uint8(70), uint8(2), true, true)
+	f.Add(uint64(3), uint8(0x80|9), uint8(63), uint8(64), uint8(1), uint8(9), true, false)
+	f.Add(uint64(4), uint8(99), uint8(0), uint8(0), uint8(0), uint8(0), false, true)
+	f.Fuzz(func(t *testing.T, seed uint64, modBits, d0, d1, d2, d3 uint8, uint32Backend, extreme bool) {
+		backend := BigBackend
+		if uint32Backend {
+			backend = Uint32Backend
+		}
+		dims := fuzzDims(d0, d1, d2, d3)
+		mod, mats, _ := fuzzOperands(seed, modBits, extreme, backend, dims...)
+		a, b, c := mats[0], mats[1], mats[2]
+
+		ab := a.MatMul(b, mod)
+		if !matEqual(ab, naiveMatMul(a, b, mod)) {
+			t.Fatalf("MatMul() of %vx%v and %vx%v mod %v differs from the reference", a.rows, a.cols, b.rows, b.cols, mod)
+		}
+		if !matEqual(a.MatMulParallel(b, mod, 3), naiveMatMul(a, b, mod)) {
+			t.Fatalf("MatMulParallel() differs from the reference")
+		}
+
+		// associativity: (a·b)·c = a·(b·c)
+		if !matEqual(ab.MatMul(c, mod), naiveMatMul(a, b.MatMul(c, mod), mod)) {
+			t.Fatalf("(a·b)·c != a·(b·c) for dimensions %v mod %v", dims, mod)
+		}
+
+		// the uint32 backend agrees with the big backend whenever it applies
+		if backend == BigBackend && mod.Cmp(two32) <= 0 {
+			if !matEqual(a.as(Uint32Backend).MatMul(b.as(Uint32Backend), mod), naiveMatMul(a, b, mod)) {
+				t.Fatalf("uint32 MatMul() differs from big MatMul()")
+			}
+		}
+	})
+}
+
+func FuzzVecMul(f *testing.F) {
+	f.Add(uint64(1), uint8(31), uint8(3), uint8(5), false, false)
+	f.Add(uint64(2), uint8(31), uint8(70), uint8(2), true, true)
+	f.Add(uint64(3), uint8(0x80|9), uint8(1), uint8(64), true, false)
+	f.Add(uint64(4), uint8(99), uint8(0), uint8(0), false, true)
+	f.Fuzz(func(t *testing.T, seed uint64, modBits, d0, d1 uint8, uint32Backend, extreme bool) {
+		backend := BigBackend
+		if uint32Backend {
+			backend = Uint32Backend
+		}
+		dims := fuzzDims(d0, d1)
+		mod, mats, u := fuzzOperands(seed, modBits, extreme, backend, dims...)
+		m := mats[0]
+		_, _, v := fuzzOperands(seed+1, modBits, false, backend, dims...)
+
+		// rectangular matrices give one entry per row, not per entry of the vector
+		mu := m.VecMul(u, mod)
+		if !vecEqual(mu, naiveVecMul(m, u, mod)) {
+			t.Fatalf("VecMul() of %vx%v mod %v differs from the reference", m.rows, m.cols, mod)
+		}
+		if !vecEqual(m.VecMulParallel(u, mod, 3), vecEntries(mu)) {
+			t.Fatalf("VecMulParallel() differs from VecMul()")
+		}
+
+		// VecMul is MatMul by a single column
+		col := NewMatWith(u.size, 1, backend)
+		col.setCol(0, u)
+		prod := m.MatMul(col, mod)
+		for i := range m.rows {
+			if prod.Get(i, 0).Cmp(mu.Get(i)) != 0 {
+				t.Fatalf("VecMul() entry %v = %v, MatMul() by a column gives %v", i, mu.Get(i), prod.Get(i, 0))
+			}
+		}
+
+		// distributivity: m·(u+v) = m·u + m·v, and likewise for subtraction and scaling
+		if !vecEqual(m.VecMul(u.Add(v, mod), mod), vecEntries(mu.Add(m.VecMul(v, mod), mod))) {
+			t.Fatalf("m·(u+v) != m·u + m·v for %vx%v mod %v", m.rows, m.cols, mod)
+		}
+		if !vecEqual(m.VecMul(u.Sub(v, mod), mod), vecEntries(mu.Sub(m.VecMul(v, mod), mod))) {
+			t.Fatalf("m·(u-v) != m·u - m·v for %vx%v mod %v", m.rows, m.cols, mod)
+		}
+		s := new(big.Int).SetUint64(seed)
+		if !vecEqual(m.VecMul(u.Scale(s, mod), mod), vecEntries(mu.Scale(s, mod))) {
+			t.Fatalf("m·(s·u) != s·(m·u) for %vx%v mod %v", m.rows, m.cols, mod)
+		}
+		if !vecEqual(u.Sub(v, mod).Add(v, mod), vecEntries(u)) {
+			t.Fatalf("(u-v)+v != u mod %v", mod)
+		}
+
+		if backend == BigBackend && mod.Cmp(two32) <= 0 {
+			if !vecEqual(m.as(Uint32Backend).VecMul(u.as(Uint32Backend), mod), vecEntries(mu)) {
+				t.Fatalf("uint32 VecMul() differs from big VecMul()")
+			}
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package simplepir
 
 import (
+	"errors"
 	"math/big"
 	"reflect"
 	"testing"
@@ -15,7 +16,9 @@ func assertPanic(t *testing.T, f func(), msg string) {
 	f()
 }
 
-func TestMatMul(t *testing.T) {
+func TestMatMul(t *testing.T) { forEachBackend(t, testMatMul) }
+
+func testMatMul(t *testing.T, backend Backend) {
 	mod := big.NewInt(7) // use 7 as modulus for tests
 	tests := []struct {
 		name     string
@@ -26,38 +29,38 @@ func TestMatMul(t *testing.T) {
 	}{
 		{
 			name:     "empty matrices",
-			m1:       &Mat{},
-			m2:       &Mat{},
+			m1:       &Mat{backend: backend},
+			m2:       &Mat{backend: backend},
 			mod:      mod,
 			expected: nil,
 		},
 		{
 			name:     "incompatible dimensions",
-			m1:       NewMat(2, 3).Fill([]int64{1, 2, 3, 4, 5, 6}),
-			m2:       NewMat(2, 2).Fill([]int64{1, 2, 3, 4}),
+			m1:       NewMatWith(2, 3, backend).Fill([]int64{1, 2, 3, 4, 5, 6}),
+			m2:       NewMatWith(2, 2, backend).Fill([]int64{1, 2, 3, 4}),
 			mod:      mod,
 			expected: nil,
 		},
 		{
 			name:     "1x1 matrices",
-			m1:       NewMat(1, 1).Fill([]int64{5}),
-			m2:       NewMat(1, 1).Fill([]int64{3}),
+			m1:       NewMatWith(1, 1, backend).Fill([]int64{5}),
+			m2:       NewMatWith(1, 1, backend).Fill([]int64{3}),
 			mod:      mod,
-			expected: NewMat(1, 1).Fill([]int64{1}), // 15 mod 7 = 1
+			expected: NewMatWith(1, 1, backend).Fill([]int64{1}), // 15 mod 7 = 1
 		},
 		{
 			name:     "2x2 matrices",
-			m1:       NewMat(2, 2).Fill([]int64{1, 2, 3, 4}),
-			m2:       NewMat(2, 2).Fill([]int64{5, 6, 7, 8}),
+			m1:       NewMatWith(2, 2, backend).Fill([]int64{1, 2, 3, 4}),
+			m2:       NewMatWith(2, 2, backend).Fill([]int64{5, 6, 7, 8}),
 			mod:      mod,
-			expected: NewMat(2, 2).Fill([]int64{5, 1, 1, 1}), // Result mod 7
+			expected: NewMatWith(2, 2, backend).Fill([]int64{5, 1, 1, 1}), // Result mod 7
 		},
 		{
 			name:     "large numbers",
-			m1:       NewMat(2, 2).Fill([]int64{100, 200, 300, 400}),
-			m2:       NewMat(2, 2).Fill([]int64{500, 600, 700, 800}),
+			m1:       NewMatWith(2, 2, backend).Fill([]int64{100, 200, 300, 400}),
+			m2:       NewMatWith(2, 2, backend).Fill([]int64{500, 600, 700, 800}),
 			mod:      mod,
-			expected: NewMat(2, 2).Fill([]int64{6, 4, 4, 4}), // Result mod 7
+			expected: NewMatWith(2, 2, backend).Fill([]int64{6, 4, 4, 4}), // Result mod 7
 		},
 	}
 
@@ -78,7 +81,9 @@ func TestMatMul(t *testing.T) {
 	}
 }
 
-func TestVecMul(t *testing.T) {
+func TestVecMul(t *testing.T) { forEachBackend(t, testVecMul) }
+
+func testVecMul(t *testing.T, backend Backend) {
 	mod := big.NewInt(7) // use 7 as modulus for tests
 	tests := []struct {
 		name     string
@@ -89,38 +94,53 @@ func TestVecMul(t *testing.T) {
 	}{
 		{
 			name:     "empty matrices",
-			m:        &Mat{},
-			v:        &Vec{},
+			m:        &Mat{backend: backend},
+			v:        &Vec{backend: backend},
 			mod:      mod,
 			expected: nil,
 		},
 		{
 			name:     "incompatible dimensions",
-			m:        NewMat(2, 3).Fill([]int64{1, 2, 3, 4, 5, 6}),
-			v:        NewVec(10).Fill([]int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}),
+			m:        NewMatWith(2, 3, backend).Fill([]int64{1, 2, 3, 4, 5, 6}),
+			v:        NewVecWith(10, backend).Fill([]int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}),
 			mod:      mod,
 			expected: nil,
 		},
 		{
 			name:     "1x1 matrix and 1 vector",
-			m:        NewMat(1, 1).Fill([]int64{5}),
-			v:        NewVec(1).Fill([]int64{3}),
+			m:        NewMatWith(1, 1, backend).Fill([]int64{5}),
+			v:        NewVecWith(1, backend).Fill([]int64{3}),
 			mod:      mod,
-			expected: NewVec(1).Fill([]int64{1}), // 15 mod 7 = 1
+			expected: NewVecWith(1, backend).Fill([]int64{1}), // 15 mod 7 = 1
 		},
 		{
 			name:     "2x2 matrices",
-			m:        NewMat(2, 2).Fill([]int64{1, 2, 3, 4}),
-			v:        NewVec(2).Fill([]int64{5, 6}),
+			m:        NewMatWith(2, 2, backend).Fill([]int64{1, 2, 3, 4}),
+			v:        NewVecWith(2, backend).Fill([]int64{5, 6}),
+			mod:      mod,
+			expected: NewVecWith(2, backend).Fill([]int64{3, 4}), // Result mod 7
+		},
+		{
+			// regression: the result used to be sized by v.size rather than m.rows
+			name:     "rectangular matrix",
+			m:        NewMatWith(3, 2, backend).Fill([]int64{1, 2, 3, 4, 5, 6}),
+			v:        NewVecWith(2, backend).Fill([]int64{1, 1}),
 			mod:      mod,
-			expected: NewVec(2).Fill([]int64{3, 4}), // Result mod 7
+			expected: NewVecWith(3, backend).Fill([]int64{3, 0, 4}), // 3, 7, 11 mod 7
+		},
+		{
+			name:     "wide matrix",
+			m:        NewMatWith(1, 3, backend).Fill([]int64{1, 2, 3}),
+			v:        NewVecWith(3, backend).Fill([]int64{1, 1, 1}),
+			mod:      mod,
+			expected: NewVecWith(1, backend).Fill([]int64{6}),
 		},
 		{
 			name:     "large numbers",
-			m:        NewMat(2, 2).Fill([]int64{1_000_000, 2_000_000, 3_000_000, 4_000_000}),
-			v:        NewVec(2).Fill([]int64{5_000_000, 6_000_000}),
+			m:        NewMatWith(2, 2, backend).Fill([]int64{1_000_000, 2_000_000, 3_000_000, 4_000_000}),
+			v:        NewVecWith(2, backend).Fill([]int64{5_000_000, 6_000_000}),
 			mod:      mod,
-			expected: NewVec(2).Fill([]int64{3, 4}), // Result mod 7
+			expected: NewVecWith(2, backend).Fill([]int64{3, 4}), // Result mod 7
 		},
 	}
 
@@ -140,3 +160,102 @@ func TestVecMul(t *testing.T) {
 		})
 	}
 }
+
+func TestSetCol(t *testing.T) { forEachBackend(t, testSetCol) }
+
+func testSetCol(t *testing.T, backend Backend) {
+	m := NewMatWith(3, 2, backend)
+	m.setCol(1, NewVecWith(3, backend).Fill([]int64{4, 5, 6}))
+	expected := NewMatWith(3, 2, backend).Fill([]int64{0, 4, 0, 5, 0, 6})
+	if !reflect.DeepEqual(m, expected) {
+		t.Errorf("setCol() = %v, want %v", m, expected)
+	}
+	assertPanic(t, func() { m.setCol(0, NewVecWith(2, backend)) }, "expected panic about size mismatch")
+}
+
+func TestTranspose(t *testing.T) { forEachBackend(t, testTranspose) }
+
+func testTranspose(t *testing.T, backend Backend) {
+	m := NewMatWith(2, 3, backend).Fill([]int64{1, 2, 3, 4, 5, 6})
+	expected := NewMatWith(3, 2, backend).Fill([]int64{1, 4, 2, 5, 3, 6})
+	if result := m.transpose(); !reflect.DeepEqual(result, expected) {
+		t.Errorf("transpose() = %v, want %v", result, expected)
+	}
+}
+
+// forEachBackend runs f as a subtest once for every [Backend]
+func forEachBackend(t *testing.T, f func(t *testing.T, backend Backend)) {
+	for _, backend := range []Backend{BigBackend, Uint32Backend} {
+		t.Run(backend.String(), func(t *testing.T) { f(t, backend) })
+	}
+}
+
+func TestMatChecked(t *testing.T) { forEachBackend(t, testMatChecked) }
+
+func testMatChecked(t *testing.T, backend Backend) {
+	m := NewMatWith(2, 3, backend).Fill([]int64{1, 2, 3, 4, 5, 6})
+	v := NewVecWith(3, backend).Fill([]int64{1, 1, 1})
+	mod := big.NewInt(100)
+
+	tests := []struct {
+		name    string
+		op      func() (any, error)
+		wantErr error
+	}{
+		{"new", func() (any, error) { return NewMatChecked(2, 3, backend) }, nil},
+		{"new empty", func() (any, error) { return NewMatChecked(2, 0, backend) }, ErrEmpty},
+		{"fill wrong size", func() (any, error) { return NewMatWith(2, 3, backend).FillChecked([]int64{1}) }, ErrDimensionMismatch},
+		{"fill zero value", func() (any, error) { return (&Mat{}).FillChecked(nil) }, ErrEmpty},
+		{"mat mul", func() (any, error) { return m.MatMulChecked(NewMatWith(3, 4, backend), mod) }, nil},
+		{"mat mul nil", func() (any, error) { return m.MatMulChecked(nil, mod) }, ErrEmpty},
+		{"mat mul wrong shape", func() (any, error) { return m.MatMulChecked(m, mod) }, ErrDimensionMismatch},
+		{"mat mul parallel", func() (any, error) { return m.MatMulParallelChecked(NewMatWith(3, 1, backend), mod, 4) }, nil},
+		{"vec mul", func() (any, error) { return m.VecMulChecked(v, mod) }, nil},
+		{"vec mul zero value", func() (any, error) { return m.VecMulChecked(&Vec{}, mod) }, ErrEmpty},
+		{"vec mul wrong size", func() (any, error) { return m.VecMulParallelChecked(NewVecWith(2, backend), mod, 4) }, ErrDimensionMismatch},
+		{"vec mul missing storage", func() (any, error) { return m.VecMulChecked(&Vec{size: 3, backend: backend}, mod) }, ErrEmpty},
+		{"vec mul nil matrix", func() (any, error) { return (*Mat)(nil).VecMulChecked(v, mod) }, ErrEmpty},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.op(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("invalid modulus", func(t *testing.T) {
+		if _, err := m.VecMulChecked(v, big.NewInt(0)); err == nil {
+			t.Errorf("VecMulChecked() with zero modulus succeeded, want error")
+		}
+		if _, err := m.MatMulChecked(NewMatWith(3, 1, backend), nil); err == nil {
+			t.Errorf("MatMulChecked() with nil modulus succeeded, want error")
+		}
+	})
+
+	// the checked results agree with the panicking API
+	if got, _ := m.VecMulChecked(v, mod); !reflect.DeepEqual(got, m.VecMul(v, mod)) {
+		t.Errorf("VecMulChecked() = %v, want %v", got, m.VecMul(v, mod))
+	}
+}
+
+func TestSetVecMul(t *testing.T) { forEachBackend(t, testSetVecMul) }
+
+func testSetVecMul(t *testing.T, backend Backend) {
+	mod := big.NewInt(7)
+	m := NewMatWith(2, 3, backend).Fill([]int64{1, 2, 3, 4, 5, 6})
+	v := NewVecWith(3, backend).Fill([]int64{1, 2, 3})
+
+	// a destination holding an earlier result is overwritten rather than accumulated into
+	z := NewVecWith(2, backend).Fill([]int64{5, 5})
+	for range 2 {
+		if got := z.SetVecMul(m, v, mod); got != z || !equalEntries(z, m.VecMul(v, mod)) {
+			t.Errorf("SetVecMul() = %v, want %v stored in the receiver", got, m.VecMul(v, mod))
+		}
+	}
+
+	square := NewMatWith(3, 3, backend).Fill([]int64{1, 0, 0, 0, 1, 0, 0, 0, 1})
+	assertPanic(t, func() { v.SetVecMul(square, v, mod) }, "SetVecMul() into its own operand did not panic")
+	assertPanic(t, func() { NewVecWith(3, backend).SetVecMul(m, v, mod) }, "SetVecMul() into the wrong size did not panic")
+	assertPanic(t, func() { NewVecWith(2, backend).SetVecMul(m, NewVecWith(2, backend), mod) }, "SetVecMul() of mismatched operands did not panic")
+}
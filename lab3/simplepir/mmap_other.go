@@ -0,0 +1,25 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package simplepir
+
+import (
+	"io"
+	"os"
+)
+
+// mapFile reads the first size bytes of f into memory, on platforms without mmap
+func mapFile(f *os.File, size int) ([]byte, error) {
+	data := make([]byte, size)
+	if _, err := io.ReadFull(f, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// unmapFile does nothing, the garbage collector frees data read by [mapFile]
+func unmapFile(data []byte) error {
+	return nil
+}
+
+// releasePages does nothing, as data read by [mapFile] is not backed by the file
+func releasePages(data []byte, lo, hi int) {}
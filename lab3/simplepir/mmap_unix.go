@@ -0,0 +1,23 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package simplepir
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile maps the first size bytes of f into memory, privately so that writes are never written back to f
+func mapFile(f *os.File, size int) ([]byte, error) {
+	data, err := syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_PRIVATE)
+	if err != nil {
+		return nil, err
+	}
+	adviseSequential(data)
+	return data, nil
+}
+
+// unmapFile unmaps data returned by [mapFile]
+func unmapFile(data []byte) error {
+	return syscall.Munmap(data)
+}
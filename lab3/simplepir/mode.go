@@ -0,0 +1,109 @@
+package simplepir
+
+import (
+	"fmt"
+)
+
+// Mode is the scheme a server runs, plain LWE with [Server] and [Client] or RLWE with [RingServer] and [RingClient]
+//
+// Servers report their mode with [PIRServer.Mode], so that a client knows which to set up with [NewModeClient]
+type Mode int
+
+const (
+	LWEMode  Mode = iota // plain LWE, see [Server]
+	RingMode             // RLWE over Z_q[X]/(X^d+1), see [RingServer]
+)
+
+// String returns the name of the mode, as parsed by [ParseMode]
+func (m Mode) String() string {
+	switch m {
+	case LWEMode:
+		return "lwe"
+	case RingMode:
+		return "ring"
+	default:
+		return fmt.Sprintf("Mode(%d)", int(m))
+	}
+}
+
+// ParseMode parses a mode written by [Mode.String]
+func ParseMode(s string) (Mode, error) {
+	for _, m := range []Mode{LWEMode, RingMode} {
+		if s == m.String() {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown mode %q, want lwe or ring", s)
+}
+
+// QuerySize returns the number of entries in a query vector under the mode, for the given parameters
+func (m Mode) QuerySize(params Params) int {
+	if m == RingMode {
+		return ringColumns(params) * params.N
+	}
+	_, cols := params.Shape()
+	return cols
+}
+
+// PIRServer is the part of a server that clients talk to, shared by [Server] and [RingServer] so that callers
+// such as package pirhttp can serve either mode
+type PIRServer interface {
+	Mode() Mode
+	Params() Params
+	Seed() []byte // seed of the public matrix A, or of the ring elements in the ring mode
+	Hint() *Mat   // shared with the server, so it must not be modified, and never modified by the server
+	Version() HintVersion
+	CheckVersion(v HintVersion) error
+	Answer(qu *Vec) (*Vec, error)
+}
+
+// PIRClient fetches whole records, shared by [Client] and [RingClient] so that callers can use either mode
+type PIRClient interface {
+	Params() Params
+	Version() HintVersion
+	QueryRecord(layout Layout, i int) (*RecordState, []*Vec, error)
+	RecoverRecord(st *RecordState, answers []*Vec) ([]byte, error)
+}
+
+// Creates a new [PIRServer] for db running the given mode, with [NewServer] or [NewRingServer]
+func NewModeServer(mode Mode, params Params, db *Mat) (PIRServer, error) {
+	var server PIRServer
+	var err error
+	switch mode {
+	case LWEMode:
+		server, err = NewServer(params, db)
+	case RingMode:
+		server, err = NewRingServer(params, db)
+	default:
+		return nil, fmt.Errorf("unknown mode %v", mode)
+	}
+	if err != nil {
+		return nil, err // server holds a nil pointer, which would not compare equal to nil
+	}
+	return server, nil
+}
+
+// Creates a new [PIRClient] for a server running the given mode, with [NewClientFromSeed] or [NewRingClient]
+//
+// Usage:
+//
+//	server, err := NewModeServer(mode, params, db.Mat())
+//	client, err := NewModeClient(server.Mode(), params, server.Seed(), server.Hint())
+//	st, queries, err := client.QueryRecord(db.Layout(), i) // answer each query with server.Answer
+//	record, err := client.RecoverRecord(st, answers)
+func NewModeClient(mode Mode, params Params, seed []byte, hint *Mat) (PIRClient, error) {
+	var client PIRClient
+	var err error
+	switch mode {
+	case LWEMode:
+		client, err = NewClientFromSeed(params, seed, hint)
+	case RingMode:
+		client, err = NewRingClient(params, seed, hint)
+	default:
+		return nil, fmt.Errorf("unknown mode %v", mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
@@ -0,0 +1,105 @@
+package simplepir
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestModes(t *testing.T) {
+	records := make([][]byte, 40)
+	for i := range records {
+		records[i] = make([]byte, 6)
+		rand.Read(records[i])
+	}
+	db, err := NewDatabase(records, big.NewInt(991))
+	if err != nil {
+		t.Fatalf("NewDatabase() error = %v", err)
+	}
+	layout := db.Layout()
+
+	tests := []struct {
+		mode   Mode
+		params Params
+	}{
+		{LWEMode, Params{N: 64, Q: big.NewInt(1 << 32), P: big.NewInt(991), SqrtN: layout.SqrtN, Backend: Uint32Backend}},
+		{RingMode, Params{N: 16, Q: big.NewInt(RingModulus), P: big.NewInt(991), SqrtN: layout.SqrtN, Backend: Uint32Backend}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode.String(), func(t *testing.T) {
+			server, err := NewModeServer(tt.mode, tt.params, db.Mat())
+			if err != nil {
+				t.Fatalf("NewModeServer() error = %v", err)
+			}
+			if server.Mode() != tt.mode {
+				t.Errorf("Mode() = %v, want %v", server.Mode(), tt.mode)
+			}
+			if server.Hint() != server.Hint() {
+				t.Errorf("Hint() copied the hint, want the matrix shared with the server")
+			}
+			client, err := NewModeClient(server.Mode(), server.Params(), server.Seed(), server.Hint())
+			if err != nil {
+				t.Fatalf("NewModeClient() error = %v", err)
+			}
+			if err := server.CheckVersion(client.Version()); err != nil {
+				t.Errorf("CheckVersion() error = %v", err)
+			}
+			if err := server.CheckVersion(HintVersion{}); !errors.Is(err, ErrStaleHint) {
+				t.Errorf("CheckVersion() of another version error = %v, want %v", err, ErrStaleHint)
+			}
+
+			for _, i := range []int{0, 17, len(records) - 1} {
+				st, queries, err := client.QueryRecord(layout, i)
+				if err != nil {
+					t.Fatalf("QueryRecord() error = %v", err)
+				}
+				answers := make([]*Vec, len(queries))
+				for k, qu := range queries {
+					if qu.Size() != tt.mode.QuerySize(tt.params) {
+						t.Errorf("query has size %v, want %v", qu.Size(), tt.mode.QuerySize(tt.params))
+					}
+					if answers[k], err = server.Answer(qu); err != nil {
+						t.Fatalf("Answer() error = %v", err)
+					}
+				}
+				if got, err := client.RecoverRecord(st, answers); err != nil || !bytes.Equal(got, records[i]) {
+					t.Errorf("RecoverRecord(%v) = %x, %v, want %x", i, got, err, records[i])
+				}
+			}
+		})
+	}
+
+	t.Run("state from the other mode", func(t *testing.T) {
+		lwe, _ := NewModeClient(LWEMode, tests[0].params, make([]byte, 32), NewMatWith(layout.SqrtN, 64, Uint32Backend))
+		ring, _ := NewModeClient(RingMode, tests[1].params, make([]byte, 32), NewMatWith(layout.SqrtN, 16, Uint32Backend))
+		st, queries, err := ring.QueryRecord(layout, 0)
+		if err != nil {
+			t.Fatalf("QueryRecord() error = %v", err)
+		}
+		if _, err := lwe.RecoverRecord(st, queries); err == nil {
+			t.Errorf("RecoverRecord() with a ring state succeeded, want error")
+		}
+	})
+
+	t.Run("invalid mode", func(t *testing.T) {
+		if _, err := NewModeServer(Mode(7), tests[0].params, db.Mat()); err == nil {
+			t.Errorf("NewModeServer() with unknown mode succeeded, want error")
+		}
+		if server, err := NewModeServer(RingMode, tests[0].params, db.Mat()); err == nil || server != nil {
+			t.Errorf("NewModeServer() with LWE parameters for the ring mode = %v, %v, want nil and an error", server, err)
+		}
+	})
+}
+
+func TestParseMode(t *testing.T) {
+	for _, m := range []Mode{LWEMode, RingMode} {
+		if got, err := ParseMode(m.String()); err != nil || got != m {
+			t.Errorf("ParseMode(%q) = %v, %v, want %v", m.String(), got, err, m)
+		}
+	}
+	if _, err := ParseMode("double"); err == nil {
+		t.Errorf("ParseMode(\"double\") succeeded, want error")
+	}
+}
@@ -0,0 +1,211 @@
+package simplepir
+
+import (
+	"fmt"
+	"math/big"
+	"math/bits"
+)
+
+// RingModulus is the prime q = 2^32 - 2^20 + 1 used by [ChooseRingParams]
+//
+// q ≡ 1 mod 2^20, so Z_q has the 2d-th roots of unity the NTT needs for every ring degree d up to 2^19, and q
+// is just below 2^32 so that entries fit the [Uint32Backend]
+const RingModulus = 1<<32 - 1<<20 + 1
+
+// ring holds the tables for arithmetic in the negacyclic ring Z_q[X]/(X^d+1) with the number theoretic transform
+//
+// Polynomials are []uint32 of d coefficients in [0, q). The NTT maps a polynomial to its evaluations at the odd
+// powers of a primitive 2d-th root of unity ψ, in bit-reversed order, so that products in the ring become
+// pointwise products. Twiddle factors are multiplied with Shoup's trick, which trades the division for a second
+// multiplication by a precomputed ⌊w·2^32/q⌋.
+//
+// Sources: Longa and Naehrig's "Speeding up the Number Theoretic Transform for Faster Ideal Lattice-Based
+// Cryptography" (https://eprint.iacr.org/2016/504) for the transforms, and Shoup's NTL for the modular multiplication
+type ring struct {
+	d       int
+	q       uint64
+	barrett uint64   // ⌊(2^64-1)/q⌋, for reducing arbitrary products, see [ring.mul]
+	psi     []uint32 // psi[k] = ψ^bitrev(k)
+	psiInv  []uint32 // psiInv[k] = ψ^-bitrev(k)
+	psiS    []uint32 // Shoup precomputations for psi
+	psiInvS []uint32 // Shoup precomputations for psiInv
+	dInv    uint32   // d^-1 mod q, to scale the inverse transform
+	dInvS   uint32
+	r64     uint64 // 2^64 mod q, for folding the high words of a sum in [ring.mulSum]
+}
+
+// newRing builds the tables for Z_q[X]/(X^d+1), where d must be a power of two and q a prime below 2^32
+// with q ≡ 1 mod 2d
+func newRing(d int, q *big.Int) (*ring, error) {
+	if d < 2 || d&(d-1) != 0 {
+		return nil, fmt.Errorf("ring degree must be a power of two, got %v", d)
+	}
+	if q == nil || q.Sign() <= 0 || q.Cmp(two32) >= 0 || !q.ProbablyPrime(20) {
+		return nil, fmt.Errorf("ring modulus must be a prime below 2^32, got %v", q)
+	}
+	if new(big.Int).Mod(q, big.NewInt(int64(2*d))).Int64() != 1 {
+		return nil, fmt.Errorf("ring modulus %v is not 1 mod %v, so has no %v-th roots of unity", q, 2*d, 2*d)
+	}
+	r := &ring{d: d, q: q.Uint64(), barrett: ^uint64(0) / q.Uint64()}
+	r.r64 = (r.reduce(^uint64(0)) + 1) % r.q
+
+	// x^((q-1)/2d) has order dividing 2d, and exactly 2d when its d-th power is -1
+	exp := new(big.Int).Div(new(big.Int).Sub(q, big.NewInt(1)), big.NewInt(int64(2*d)))
+	var psi uint64
+	for x := int64(2); ; x++ {
+		psi = new(big.Int).Exp(big.NewInt(x), exp, q).Uint64()
+		if r.pow(psi, uint64(d)) == r.q-1 {
+			break
+		}
+	}
+	psiInv := r.pow(psi, r.q-2)
+
+	logD := bits.Len(uint(d)) - 1
+	r.psi, r.psiInv = make([]uint32, d), make([]uint32, d)
+	r.psiS, r.psiInvS = make([]uint32, d), make([]uint32, d)
+	for k := range d {
+		e := uint64(bits.Reverse(uint(k)) >> (bits.UintSize - logD))
+		r.psi[k], r.psiInv[k] = uint32(r.pow(psi, e)), uint32(r.pow(psiInv, e))
+		r.psiS[k], r.psiInvS[k] = r.shoup(r.psi[k]), r.shoup(r.psiInv[k])
+	}
+	r.dInv = uint32(r.pow(uint64(d), r.q-2))
+	r.dInvS = r.shoup(r.dInv)
+	return r, nil
+}
+
+// mul returns a·b mod q for any a, b < 2^32, using Barrett reduction
+func (r *ring) mul(a, b uint64) uint64 {
+	return r.reduce(a * b)
+}
+
+// reduce returns x mod q, using Barrett reduction as the compiler cannot avoid the division for a variable q
+func (r *ring) reduce(x uint64) uint64 {
+	est, _ := bits.Mul64(x, r.barrett)
+	x -= est * r.q
+	// the estimate of the quotient is at most 2 too small
+	if x >= r.q {
+		x -= r.q
+	}
+	if x >= r.q {
+		x -= r.q
+	}
+	return x
+}
+
+// pow returns x^e mod q
+func (r *ring) pow(x, e uint64) uint64 {
+	result := uint64(1)
+	for ; e > 0; e >>= 1 {
+		if e&1 == 1 {
+			result = r.mul(result, x)
+		}
+		x = r.mul(x, x)
+	}
+	return result
+}
+
+// shoup returns ⌊w·2^32/q⌋, for multiplying by the constant w with [ring.mulShoup]
+func (r *ring) shoup(w uint32) uint32 {
+	return uint32((uint64(w) << 32) / r.q)
+}
+
+// mulShoup returns a·w mod q, where ws = [ring.shoup](w)
+//
+// ws approximates w/q, so (a·ws)>>32 is within one of the quotient ⌊a·w/q⌋ and a single correction is needed
+func (r *ring) mulShoup(a, w, ws uint32) uint32 {
+	x := uint64(a)*uint64(w) - (uint64(a)*uint64(ws)>>32)*r.q
+	if x >= r.q {
+		x -= r.q
+	}
+	return uint32(x)
+}
+
+func (r *ring) add(a, b uint32) uint32 {
+	x := uint64(a) + uint64(b)
+	if x >= r.q {
+		x -= r.q
+	}
+	return uint32(x)
+}
+
+func (r *ring) sub(a, b uint32) uint32 {
+	x := uint64(a) + r.q - uint64(b)
+	if x >= r.q {
+		x -= r.q
+	}
+	return uint32(x)
+}
+
+// ntt transforms the polynomial a in place into the evaluation domain, in bit-reversed order
+//
+// Cooley-Tukey butterflies with the powers of ψ merged in, so no separate pre-multiplication is needed for the
+// negacyclic wrap. Costs d/2·log₂ d multiplications.
+func (r *ring) ntt(a []uint32) {
+	t := r.d
+	for m := 1; m < r.d; m <<= 1 {
+		t >>= 1
+		for i := range m {
+			w, ws := r.psi[m+i], r.psiS[m+i]
+			lo := a[2*i*t : 2*i*t+t]
+			hi := a[2*i*t+t : 2*i*t+2*t]
+			for j := range lo {
+				u, v := lo[j], r.mulShoup(hi[j], w, ws)
+				lo[j], hi[j] = r.add(u, v), r.sub(u, v)
+			}
+		}
+	}
+}
+
+// intt is the inverse of [ring.ntt], with Gentleman-Sande butterflies
+func (r *ring) intt(a []uint32) {
+	t := 1
+	for m := r.d; m > 1; m >>= 1 {
+		h := m / 2
+		for i := range h {
+			w, ws := r.psiInv[h+i], r.psiInvS[h+i]
+			lo := a[2*i*t : 2*i*t+t]
+			hi := a[2*i*t+t : 2*i*t+2*t]
+			for j := range lo {
+				u, v := lo[j], hi[j]
+				lo[j], hi[j] = r.add(u, v), r.mulShoup(r.sub(u, v), w, ws)
+			}
+		}
+		t <<= 1
+	}
+	for j := range a {
+		a[j] = r.mulShoup(a[j], r.dInv, r.dInvS)
+	}
+}
+
+// mulSum sets dst to Σ_j x_j·y_j, for x and y each holding the same number of polynomials in the evaluation
+// domain one after another
+//
+// Each coefficient's products are below q² < 2^64 and summed in 128 bits without reduction, so a sum of up to 2^31
+// products only needs reducing once
+func (r *ring) mulSum(dst, x, y []uint32) {
+	y = y[:len(x)]
+	for c := range dst {
+		var lo, hi, carry uint64
+		for j := c; j < len(x); j += r.d {
+			lo, carry = bits.Add64(lo, uint64(x[j])*uint64(y[j]), 0)
+			hi += carry
+		}
+		// hi < 2^31 and 2^64 mod q < 2^32, so folding in the high word cannot overflow
+		dst[c] = uint32(r.reduce(r.reduce(lo) + hi*r.r64))
+	}
+}
+
+// coeff returns coefficient c of a·b in the ring, with a and b in the coefficient domain
+//
+// Costs d multiplications rather than the three transforms of a full product, as X^d = -1 turns the terms that
+// wrap around into subtractions: (a·b)_c = Σ_{t≤c} a_t·b_{c-t} - Σ_{t>c} a_t·b_{c-t+d}
+func (r *ring) coeff(a, b []uint32, c int) uint32 {
+	var plus, minus uint64
+	for t := 0; t <= c; t++ {
+		plus += r.mul(uint64(a[t]), uint64(b[c-t]))
+	}
+	for t := c + 1; t < r.d; t++ {
+		minus += r.mul(uint64(a[t]), uint64(b[c-t+r.d]))
+	}
+	return r.sub(uint32(r.reduce(plus)), uint32(r.reduce(minus)))
+}
@@ -0,0 +1,158 @@
+package simplepir
+
+import (
+	"fmt"
+	"math/big"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// negacyclicMul multiplies a and b in Z_q[X]/(X^d+1) the schoolbook way, as a reference for the NTT
+func negacyclicMul(a, b []uint32, q uint64) []uint32 {
+	d := len(a)
+	result := make([]uint64, d)
+	for i, x := range a {
+		for j, y := range b {
+			prod := uint64(x) * uint64(y) % q
+			if k := i + j; k < d {
+				result[k] = (result[k] + prod) % q
+			} else {
+				result[k-d] = (result[k-d] + q - prod) % q
+			}
+		}
+	}
+	out := make([]uint32, d)
+	for i, x := range result {
+		out[i] = uint32(x)
+	}
+	return out
+}
+
+func randPoly(rng *rand.Rand, d int, q uint64) []uint32 {
+	a := make([]uint32, d)
+	for i := range a {
+		a[i] = uint32(rng.Uint64N(q))
+	}
+	return a
+}
+
+func TestNTT(t *testing.T) {
+	tests := []struct {
+		name string
+		d    int
+		q    int64
+	}{
+		{"tiny prime", 8, 17},
+		{"degree 2", 2, RingModulus},
+		{"degree 16", 16, RingModulus},
+		{"degree 256", 256, RingModulus},
+		{"other prime", 64, 7681},
+	}
+	rng := rand.New(rand.NewPCG(1, 2))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := newRing(tt.d, big.NewInt(tt.q))
+			if err != nil {
+				t.Fatalf("newRing() error = %v", err)
+			}
+			a, b := randPoly(rng, tt.d, r.q), randPoly(rng, tt.d, r.q)
+			// the largest coefficients exercise the corrections in the reductions
+			a[0], b[tt.d-1] = uint32(r.q-1), uint32(r.q-1)
+
+			x := slices.Clone(a)
+			r.ntt(x)
+			if r.intt(x); !slices.Equal(x, a) {
+				t.Errorf("intt(ntt(a)) = %v, want %v", x, a)
+			}
+
+			want := negacyclicMul(a, b, r.q)
+			x, y := slices.Clone(a), slices.Clone(b)
+			r.ntt(x)
+			r.ntt(y)
+			r.mulSum(x, x, y)
+			if r.intt(x); !slices.Equal(x, want) {
+				t.Errorf("product through the NTT = %v, want %v", x, want)
+			}
+			for _, c := range []int{0, tt.d / 2, tt.d - 1} {
+				if got := r.coeff(a, b, c); got != want[c] {
+					t.Errorf("coeff(%v) = %v, want %v", c, got, want[c])
+				}
+			}
+		})
+	}
+}
+
+func TestRingArithmetic(t *testing.T) {
+	r, err := newRing(16, big.NewInt(RingModulus))
+	if err != nil {
+		t.Fatalf("newRing() error = %v", err)
+	}
+	q := r.q
+	values := []uint64{0, 1, 2, q / 2, q - 2, q - 1, 1<<32 - 1}
+	for _, a := range values {
+		for _, b := range values {
+			want := new(big.Int).Mul(new(big.Int).SetUint64(a), new(big.Int).SetUint64(b))
+			want.Mod(want, big.NewInt(RingModulus))
+			if got := r.mul(a, b); got != want.Uint64() {
+				t.Errorf("mul(%v, %v) = %v, want %v", a, b, got, want)
+			}
+			if got := r.reduce(a * b); got != want.Uint64() {
+				t.Errorf("reduce(%v·%v) = %v, want %v", a, b, got, want)
+			}
+			if b < q {
+				if got := r.mulShoup(uint32(a), uint32(b), r.shoup(uint32(b))); uint64(got) != want.Uint64() {
+					t.Errorf("mulShoup(%v, %v) = %v, want %v", a, b, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestRingReduce(t *testing.T) {
+	r, _ := newRing(16, big.NewInt(RingModulus))
+	for _, x := range []uint64{0, r.q - 1, r.q, 2*r.q + 5, 1<<63 + 12345, ^uint64(0)} {
+		if got, want := r.reduce(x), x%r.q; got != want {
+			t.Errorf("reduce(%v) = %v, want %v", x, got, want)
+		}
+	}
+}
+
+func TestNewRing(t *testing.T) {
+	tests := []struct {
+		name    string
+		d       int
+		q       *big.Int
+		wantErr bool
+	}{
+		{"valid", 1024, big.NewInt(RingModulus), false},
+		{"largest degree", 1 << 19, big.NewInt(RingModulus), false},
+		{"degree too large for q", 1 << 20, big.NewInt(RingModulus), true},
+		{"degree not a power of two", 12, big.NewInt(RingModulus), true},
+		{"degree 1", 1, big.NewInt(RingModulus), true},
+		{"composite modulus", 8, big.NewInt(17 * 97), true},
+		{"power of two modulus", 8, big.NewInt(1 << 32), true},
+		{"no roots of unity", 16, big.NewInt(17), true},
+		{"nil modulus", 8, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newRing(tt.d, tt.q); (err != nil) != tt.wantErr {
+				t.Errorf("newRing() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func BenchmarkNTT(b *testing.B) {
+	for _, d := range []int{1024, 2048} {
+		r, _ := newRing(d, big.NewInt(RingModulus))
+		a := randPoly(rand.New(rand.NewPCG(1, 2)), d, r.q)
+		b.Run(fmt.Sprintf("d=%d", d), func(b *testing.B) {
+			for b.Loop() {
+				r.ntt(a)
+				r.intt(a)
+			}
+		})
+	}
+}
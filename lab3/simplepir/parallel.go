@@ -0,0 +1,313 @@
+package simplepir
+
+import (
+	"fmt"
+	"math/big"
+	"runtime"
+	"sync"
+)
+
+// narrowCols is the number of columns below which a right operand is multiplied via its transpose
+const narrowCols = 64
+
+// blockSize is the number of uint32 entries (16KiB) in a cache block, chosen to sit comfortably in L1
+const blockSize = 4096
+
+// tileBytes is the size of a tile of rows that [Mat.vecMulUint32] keeps in cache, chosen to sit in L2
+const tileBytes = 1 << 20
+
+// resolveWorkers returns the number of goroutines to use, where workers ≤ 0 means one per available CPU
+func resolveWorkers(workers int) int {
+	if workers <= 0 {
+		return runtime.GOMAXPROCS(0)
+	}
+	return workers
+}
+
+// parallelRows splits [0, rows) into contiguous chunks and calls f on each chunk from its own goroutine
+//
+// runs f inline when a single worker is requested, so the sequential path spawns no goroutines
+func parallelRows(rows, workers int, f func(lo, hi int)) {
+	workers = min(resolveWorkers(workers), rows)
+	if workers <= 1 {
+		f(0, rows)
+		return
+	}
+	chunk := (rows + workers - 1) / workers
+	var wg sync.WaitGroup
+	for lo := 0; lo < rows; lo += chunk {
+		wg.Add(1)
+		go func(lo, hi int) {
+			defer wg.Done()
+			f(lo, hi)
+		}(lo, min(lo+chunk, rows))
+	}
+	wg.Wait()
+}
+
+// MatMulParallel computes m1·m2 (modulo mod) using the given number of goroutines
+//
+// The rows of the result are split between workers, workers ≤ 0 uses one per available CPU.
+// [Mat.MatMul] is the single worker case. Panics on invalid operands, see [Mat.MatMulParallelChecked].
+func (m1 *Mat) MatMulParallel(m2 *Mat, mod *big.Int, workers int) *Mat {
+	return must(m1.MatMulParallelChecked(m2, mod, workers))
+}
+
+// MatMulParallelChecked is [Mat.MatMulParallel], but returns an error rather than panicking
+//
+// The error wraps [ErrEmpty] if either operand is nil or empty, and [ErrDimensionMismatch] if the columns of m1
+// do not match the rows of m2. mod must be positive, and at most 2^32 for [Uint32Backend].
+func (m1 *Mat) MatMulParallelChecked(m2 *Mat, mod *big.Int, workers int) (*Mat, error) {
+	if err := m1.checkOperand(); err != nil {
+		return nil, err
+	}
+	if err := m2.checkOperand(); err != nil {
+		return nil, err
+	}
+	if m1.cols != m2.rows {
+		return nil, fmt.Errorf("%w: cannot multiply (%v, %v) and (%v, %v)", ErrDimensionMismatch, m1.rows, m1.cols, m2.rows, m2.cols)
+	}
+	if err := checkMod(m1.backend, mod); err != nil {
+		return nil, err
+	}
+
+	m2 = m2.as(m1.backend)
+	result := NewMatWith(m1.rows, m2.cols, m1.backend)
+
+	// for narrow right operands, such as a batch of queries, dot products against the transpose reuse each row of m1
+	var m2T *Mat
+	if m1.backend == Uint32Backend && m2.cols < narrowCols {
+		m2T = m2.transpose()
+	}
+
+	parallelRows(m1.rows, workers, func(lo, hi int) {
+		if m2T != nil {
+			m1.matMulUint32Narrow(m2T, result, mod, lo, hi)
+		} else if m1.backend == Uint32Backend {
+			m1.matMulUint32(m2, result, mod, lo, hi)
+		} else {
+			m1.matMulBig(m2, result, mod, lo, hi)
+		}
+	})
+	return result, nil
+}
+
+// VecMulParallel computes m·v (modulo mod) using the given number of goroutines
+//
+// The rows of m are split between workers, workers ≤ 0 uses one per available CPU.
+// [Mat.VecMul] is the single worker case. Panics on invalid operands, see [Mat.VecMulParallelChecked].
+func (m *Mat) VecMulParallel(v *Vec, mod *big.Int, workers int) *Vec {
+	return must(m.VecMulParallelChecked(v, mod, workers))
+}
+
+// VecMulParallelChecked is [Mat.VecMulParallel], but returns an error rather than panicking
+//
+// The error wraps [ErrEmpty] if either operand is nil or empty, and [ErrDimensionMismatch] if the columns of m
+// do not match the size of v. mod must be positive, and at most 2^32 for [Uint32Backend].
+func (m *Mat) VecMulParallelChecked(v *Vec, mod *big.Int, workers int) (*Vec, error) {
+	if err := m.checkVecMul(v, mod); err != nil {
+		return nil, err
+	}
+
+	// result has one entry per row of m, which differs from v.size for non-square matrices
+	return m.vecMulParallel(v, NewVecWith(m.rows, m.backend), mod, workers), nil
+}
+
+// checkVecMul checks that m and v can be multiplied modulo mod
+func (m *Mat) checkVecMul(v *Vec, mod *big.Int) error {
+	if err := m.checkOperand(); err != nil {
+		return err
+	}
+	if err := v.checkOperand(); err != nil {
+		return err
+	}
+	if m.cols != v.size {
+		return fmt.Errorf("%w: cannot multiply (%v, %v) and (%v)", ErrDimensionMismatch, m.rows, m.cols, v.size)
+	}
+	return checkMod(m.backend, mod)
+}
+
+// vecMulParallel sets result to m·v (modulo mod) without checking the operands and returns result
+//
+// result must have one entry per row of m, use the backend of m, and not be v
+func (m *Mat) vecMulParallel(v, result *Vec, mod *big.Int, workers int) *Vec {
+	v = v.as(m.backend)
+	if m.backend == Uint32Backend {
+		// vecMulUint32 accumulates into result
+		clear(result.packed)
+	}
+	if min(resolveWorkers(workers), m.rows) <= 1 {
+		// called directly rather than through parallelRows, as the closure would escape to the heap
+		m.vecMulRows(v, result, mod, 0, m.rows)
+		return result
+	}
+	parallelRows(m.rows, workers, func(lo, hi int) {
+		m.vecMulRows(v, result, mod, lo, hi)
+	})
+	return result
+}
+
+// vecMulRows computes entries [lo, hi) of result = m·v (modulo mod) with the kernel for the backend of m
+func (m *Mat) vecMulRows(v, result *Vec, mod *big.Int, lo, hi int) {
+	if m.backend == Uint32Backend {
+		m.vecMulUint32(v, result, mod, lo, hi)
+	} else {
+		m.vecMulBig(v, result, mod, lo, hi)
+	}
+}
+
+// matMulBig computes rows [lo, hi) of result = m1·m2 (modulo mod) for [BigBackend] matrices
+func (m1 *Mat) matMulBig(m2, result *Mat, mod *big.Int, lo, hi int) {
+	temp, sum, quo := new(big.Int), new(big.Int), new(big.Int)
+	for i := lo; i < hi; i++ {
+		for j := range m2.cols {
+			sum.SetInt64(0)
+			for k := range m1.cols {
+				// multiply elements, store in temp
+				temp.Mul(m1.data[i][k], m2.data[k][j])
+				// take modulus, store in temp
+				reduce(temp, quo, mod)
+				// add temp to sum
+				sum.Add(sum, temp)
+				// take modulus of sum
+				reduce(sum, quo, mod)
+			}
+			// put sum in array
+			result.data[i][j].Set(sum)
+		}
+	}
+}
+
+// matMulUint32 computes rows [lo, hi) of result = m1·m2 (modulo mod) for [Uint32Backend] matrices
+//
+// loops in i-k-j order so that the rows of m2 and result are walked contiguously, and tiles the
+// k and j loops so that the block of m2 being read stays in cache across rows of m1
+func (m1 *Mat) matMulUint32(m2, result *Mat, mod *big.Int, lo, hi int) {
+	m, wrap := uint32Mod(mod)
+	// tile sizes such that a kTile × jTile block of m2 fills roughly one cache block
+	jTile := min(m2.cols, blockSize)
+	kTile := max(1, blockSize/jTile)
+	for k0 := 0; k0 < m1.cols; k0 += kTile {
+		k1 := min(k0+kTile, m1.cols)
+		for j0 := 0; j0 < m2.cols; j0 += jTile {
+			j1 := min(j0+jTile, m2.cols)
+			for i := lo; i < hi; i++ {
+				out := result.packed[i*result.cols+j0 : i*result.cols+j1]
+				for k := k0; k < k1; k++ {
+					a := m1.packed[i*m1.cols+k]
+					row := m2.packed[k*m2.cols+j0 : k*m2.cols+j1]
+					if wrap {
+						for j, b := range row {
+							out[j] += a * b
+						}
+					} else {
+						a64 := uint64(a) % m
+						for j, b := range row {
+							out[j] = uint32((uint64(out[j]) + a64*(uint64(b)%m)) % m)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// matMulUint32Narrow computes rows [lo, hi) of result = m1·m2 (modulo mod), given m2T the transpose of m2
+//
+// each row of m1 is read once from memory and kept in cache for its dot products with every row of m2T,
+// which amortises the scan of m1 over the columns of m2. With q = 2^32 four dot products share each load of m1.
+func (m1 *Mat) matMulUint32Narrow(m2T, result *Mat, mod *big.Int, lo, hi int) {
+	m, wrap := uint32Mod(mod)
+	n := m2T.cols
+	for i := lo; i < hi; i++ {
+		row := m1.packed[i*n : (i+1)*n]
+		out := result.packed[i*result.cols : (i+1)*result.cols]
+		j := 0
+		if wrap {
+			for ; j+4 <= m2T.rows; j += 4 {
+				c0 := m2T.packed[j*n : (j+1)*n]
+				c1 := m2T.packed[(j+1)*n : (j+2)*n]
+				c2 := m2T.packed[(j+2)*n : (j+3)*n]
+				c3 := m2T.packed[(j+3)*n : (j+4)*n]
+				var s0, s1, s2, s3 uint32
+				for k, a := range row {
+					s0 += a * c0[k]
+					s1 += a * c1[k]
+					s2 += a * c2[k]
+					s3 += a * c3[k]
+				}
+				out[j], out[j+1], out[j+2], out[j+3] = s0, s1, s2, s3
+			}
+		}
+		for ; j < m2T.rows; j++ {
+			col := m2T.packed[j*n : (j+1)*n]
+			if wrap {
+				var sum uint32
+				for k, a := range row {
+					sum += a * col[k]
+				}
+				out[j] = sum
+			} else {
+				var sum uint64
+				for k, a := range row {
+					sum = (sum + (uint64(a)%m)*(uint64(col[k])%m)) % m
+				}
+				out[j] = uint32(sum)
+			}
+		}
+	}
+}
+
+// vecMulBig computes entries [lo, hi) of result = m·v (modulo mod) for [BigBackend] matrices and vectors
+func (m *Mat) vecMulBig(v, result *Vec, mod *big.Int, lo, hi int) {
+	// sum is shared between rows, so that only the first row of each chunk allocates
+	temp, sum, quo := new(big.Int), new(big.Int), new(big.Int)
+	for i := lo; i < hi; i++ {
+		sum.SetInt64(0)
+		for j := range v.size {
+			// multiply elements, store in temp
+			temp.Mul(m.data[i][j], v.data[j])
+			// take modulus, store in temp
+			reduce(temp, quo, mod)
+			// add temp to sum
+			sum.Add(sum, temp)
+			// take modulus of sum
+			reduce(sum, quo, mod)
+		}
+		// put sum in vector
+		result.data[i].Set(sum)
+	}
+}
+
+// vecMulUint32 computes entries [lo, hi) of result = m·v (modulo mod) for [Uint32Backend] matrices and vectors
+//
+// walks the columns in blocks, so that the slice of v being read stays in cache across rows of m. The rows are
+// taken a tile of [tileBytes] at a time, so that each row is read from memory (or from disk, for a matrix mapped by
+// [OpenMatFile]) once and stays in cache while its column blocks are walked.
+func (m *Mat) vecMulUint32(v, result *Vec, mod *big.Int, lo, hi int) {
+	q, wrap := uint32Mod(mod)
+	rowTile := max(1, tileBytes/(4*m.cols))
+	for i0 := lo; i0 < hi; i0 += rowTile {
+		i1 := min(i0+rowTile, hi)
+		for j0 := 0; j0 < m.cols; j0 += blockSize {
+			j1 := min(j0+blockSize, m.cols)
+			block := v.packed[j0:j1]
+			for i := i0; i < i1; i++ {
+				row := m.packed[i*m.cols+j0 : i*m.cols+j1]
+				if wrap {
+					sum := result.packed[i]
+					for j, a := range row {
+						sum += a * block[j]
+					}
+					result.packed[i] = sum
+				} else {
+					sum := uint64(result.packed[i])
+					for j, a := range row {
+						sum = (sum + (uint64(a)%q)*(uint64(block[j])%q)) % q
+					}
+					result.packed[i] = uint32(sum)
+				}
+			}
+		}
+	}
+}
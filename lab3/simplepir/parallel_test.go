@@ -0,0 +1,140 @@
+package simplepir
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestResolveWorkers(t *testing.T) {
+	if got := resolveWorkers(0); got != runtime.GOMAXPROCS(0) {
+		t.Errorf("resolveWorkers(0) = %v, want GOMAXPROCS %v", got, runtime.GOMAXPROCS(0))
+	}
+	if got := resolveWorkers(-3); got != runtime.GOMAXPROCS(0) {
+		t.Errorf("resolveWorkers(-3) = %v, want GOMAXPROCS %v", got, runtime.GOMAXPROCS(0))
+	}
+	if got := resolveWorkers(5); got != 5 {
+		t.Errorf("resolveWorkers(5) = %v, want 5", got)
+	}
+}
+
+func TestParallelRows(t *testing.T) {
+	for _, tt := range []struct{ rows, workers int }{{10, 1}, {10, 3}, {10, 10}, {3, 8}, {1, 0}} {
+		covered := make([]int, tt.rows)
+		parallelRows(tt.rows, tt.workers, func(lo, hi int) {
+			for i := lo; i < hi; i++ {
+				covered[i]++
+			}
+		})
+		for i, c := range covered {
+			if c != 1 {
+				t.Errorf("rows=%d workers=%d: row %d covered %d times, want 1", tt.rows, tt.workers, i, c)
+			}
+		}
+	}
+}
+
+func TestMatMulParallel(t *testing.T) { forEachBackend(t, testMatMulParallel) }
+
+func testMatMulParallel(t *testing.T, backend Backend) {
+	for _, mod := range []*big.Int{big.NewInt(1 << 32), big.NewInt(97)} {
+		// shapes larger than blockSize exercise the tiling, and narrow shapes the transposed kernel
+		for _, dims := range []struct{ rows, inner, cols int }{{7, 5, 3}, {3, blockSize + 3, 2}, {5, 2, blockSize + 7}, {4, 9, narrowCols}} {
+			m1 := NewMatWith(dims.rows, dims.inner, backend).FillRandom(mod)
+			m2 := NewMatWith(dims.inner, dims.cols, backend).FillRandom(mod)
+			expected := m1.as(BigBackend).MatMul(m2.as(BigBackend), mod)
+
+			for _, workers := range []int{1, 2, 4, 0} {
+				t.Run(fmt.Sprintf("mod=%v/%dx%dx%d/workers=%d", mod, dims.rows, dims.inner, dims.cols, workers), func(t *testing.T) {
+					result := m1.MatMulParallel(m2, mod, workers)
+					if !reflect.DeepEqual(result.as(BigBackend), expected) {
+						t.Error("MatMulParallel() does not match sequential big.Int result")
+					}
+				})
+			}
+		}
+	}
+	assertPanic(t, func() { NewMatWith(2, 3, backend).MatMulParallel(NewMatWith(2, 3, backend), big.NewInt(7), 2) }, "expected panic about dimension mismatch")
+}
+
+func TestVecMulParallel(t *testing.T) { forEachBackend(t, testVecMulParallel) }
+
+func testVecMulParallel(t *testing.T, backend Backend) {
+	for _, mod := range []*big.Int{big.NewInt(1 << 32), big.NewInt(97)} {
+		for _, dims := range []struct{ rows, cols int }{{9, 4}, {4, 2*blockSize + 1}} {
+			m := NewMatWith(dims.rows, dims.cols, backend).FillRandom(mod)
+			v := NewVecWith(dims.cols, backend).FillRandom(mod)
+			expected := m.as(BigBackend).VecMul(v.as(BigBackend), mod)
+
+			for _, workers := range []int{1, 2, 3, 16, 0} {
+				t.Run(fmt.Sprintf("mod=%v/%dx%d/workers=%d", mod, dims.rows, dims.cols, workers), func(t *testing.T) {
+					result := m.VecMulParallel(v, mod, workers)
+					if !reflect.DeepEqual(result.as(BigBackend), expected) {
+						t.Error("VecMulParallel() does not match sequential big.Int result")
+					}
+				})
+			}
+		}
+	}
+	assertPanic(t, func() { NewMatWith(2, 3, backend).VecMulParallel(NewVecWith(2, backend), big.NewInt(7), 2) }, "expected panic about dimension mismatch")
+}
+
+// benchmarkWorkers lists the worker counts to benchmark, up to the number of available CPUs
+func benchmarkWorkers() []int {
+	workers := []int{1}
+	for w := 2; w <= runtime.GOMAXPROCS(0); w *= 2 {
+		workers = append(workers, w)
+	}
+	return workers
+}
+
+// BenchmarkAnswer measures pirAnswer on databases of 2^20 and more entries, for increasing worker counts
+func BenchmarkAnswer(b *testing.B) {
+	q := big.NewInt(1 << 32)
+	for _, logN := range []int{20, 22} {
+		sqrtN := 1 << (logN / 2)
+		db := NewMatWith(sqrtN, sqrtN, Uint32Backend).FillRandom(big.NewInt(256))
+		qu := NewVecWith(sqrtN, Uint32Backend).FillRandom(q)
+		for _, workers := range benchmarkWorkers() {
+			b.Run(fmt.Sprintf("N=2^%d/workers=%d", logN, workers), func(b *testing.B) {
+				params := Params{Q: q, Workers: workers}
+				b.SetBytes(int64(4 * sqrtN * sqrtN))
+				for b.Loop() {
+					pirAnswer(db, qu, params)
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkAnswerBig measures pirAnswer on a 2^20 entry database with the math/big backend
+func BenchmarkAnswerBig(b *testing.B) {
+	q := big.NewInt(1 << 32)
+	db := NewMat(1024, 1024).FillRandom(big.NewInt(256))
+	qu := NewVec(1024).FillRandom(q)
+	for _, workers := range benchmarkWorkers() {
+		b.Run(fmt.Sprintf("N=2^20/workers=%d", workers), func(b *testing.B) {
+			params := Params{Q: q, Workers: workers}
+			for b.Loop() {
+				pirAnswer(db, qu, params)
+			}
+		})
+	}
+}
+
+// BenchmarkSetup measures the hint computation db·A for a 2^20 entry database, for increasing worker counts
+func BenchmarkSetup(b *testing.B) {
+	q := big.NewInt(1 << 32)
+	db := NewMatWith(1024, 1024, Uint32Backend).FillRandom(big.NewInt(256))
+	A := NewMatWith(1024, 256, Uint32Backend).FillRandom(q)
+	for _, workers := range benchmarkWorkers() {
+		b.Run(fmt.Sprintf("N=2^20/n=256/workers=%d", workers), func(b *testing.B) {
+			params := Params{Q: q, Workers: workers}
+			for b.Loop() {
+				pirSetup(db, A, params)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package simplepir
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// Params holds the public parameters of a SimplePIR instance
+//
+// Naming follows Hezinger et al.'s Simple PIR paper, the database is an ℓ × m matrix over Z_p (see [Params.Shape]),
+// the public matrix A is m × N over Z_q and the error is drawn from Sampler (χ in the paper).
+// The paper's square database, with ℓ = m = √N, is the SqrtN shorthand.
+type Params struct {
+	N       int      // LWE secret dimension n
+	Q       *big.Int // ciphertext modulus q
+	P       *big.Int // plaintext modulus p, each database entry holds a value in Z_p
+	SqrtN   int      // the database is a SqrtN × SqrtN matrix, unless Rows and Cols are set
+	Rows    int      // rows ℓ of a rectangular database, the number of entries in an answer
+	Cols    int      // columns m of a rectangular database, the number of entries in a query
+	Sampler Sampler  // error distribution χ, if nil the default Gaussian sampler is used
+	Backend Backend  // storage used for matrices and vectors, defaults to [BigBackend]
+	Workers int      // goroutines used for the server's matrix products, ≤ 0 means one per available CPU
+	// recovery fails with [ErrLowMargin] when the noise margin (see [Client.RecoverWithMargin]) is below this,
+	// if nil every value is returned however close it came to being rounded the wrong way
+	SafetyBand *big.Int
+	// source of the randomness for queries, errors and seeds, if nil crypto/rand is used. Only set this to
+	// replay runs, e.g. to [NewPRG] in tests, as anyone who knows the stream can recover the queried index.
+	Rand io.Reader
+}
+
+// Validate checks that the parameters describe a usable instance of the scheme
+func (p Params) Validate() error {
+	if p.N <= 0 {
+		return fmt.Errorf("LWE dimension n must be positive, got %v", p.N)
+	}
+	if err := checkShape(p.SqrtN, p.Rows, p.Cols); err != nil {
+		return err
+	}
+	if p.Q == nil || p.Q.Cmp(big.NewInt(2)) < 0 {
+		return fmt.Errorf("modulus q must be at least 2, got %v", p.Q)
+	}
+	if p.P == nil || p.P.Cmp(big.NewInt(2)) < 0 || !p.P.IsUint64() {
+		return fmt.Errorf("plaintext modulus p must be at least 2 and fit in 64 bits, got %v", p.P)
+	}
+	if p.P.Cmp(p.Q) >= 0 {
+		return fmt.Errorf("plaintext modulus p = %v must be smaller than q = %v", p.P, p.Q)
+	}
+	if p.Backend == Uint32Backend && p.Q.Cmp(two32) > 0 {
+		return fmt.Errorf("uint32 backend requires q ≤ 2^32, got %v", p.Q)
+	}
+	if p.Backend != BigBackend && p.Backend != Uint32Backend {
+		return fmt.Errorf("unknown backend %v", p.Backend)
+	}
+	if p.SafetyBand != nil && p.SafetyBand.Sign() < 0 {
+		return fmt.Errorf("safety band must not be negative, got %v", p.SafetyBand)
+	}
+	return nil
+}
+
+// Shape returns the dimensions ℓ × m of the database, Rows × Cols if they are set and SqrtN × SqrtN otherwise
+func (p Params) Shape() (rows, cols int) {
+	return shape(p.SqrtN, p.Rows, p.Cols)
+}
+
+// shape resolves the SqrtN shorthand shared by [Params] and [Layout]
+func shape(sqrtN, rows, cols int) (int, int) {
+	if rows == 0 && cols == 0 {
+		return sqrtN, sqrtN
+	}
+	return rows, cols
+}
+
+// checkShape checks that exactly one of sqrtN and the pair rows, cols is set, and that it is positive
+func checkShape(sqrtN, rows, cols int) error {
+	if rows == 0 && cols == 0 {
+		if sqrtN <= 0 {
+			return fmt.Errorf("database dimension sqrtN must be positive, got %v", sqrtN)
+		}
+		return nil
+	}
+	if sqrtN != 0 {
+		return fmt.Errorf("set either sqrtN or rows and cols, got sqrtN = %v and %vx%v", sqrtN, rows, cols)
+	}
+	if rows <= 0 || cols <= 0 {
+		return fmt.Errorf("database dimensions must be positive, got %vx%v", rows, cols)
+	}
+	return nil
+}
+
+// sampler returns the configured error sampler, falling back to the default Gaussian
+func (p Params) sampler() Sampler {
+	if p.Sampler == nil {
+		return chi
+	}
+	return p.Sampler
+}
+
+// random returns the configured source of randomness, falling back to crypto/rand
+func (p Params) random() io.Reader {
+	if p.Rand == nil {
+		return rand.Reader
+	}
+	return p.Rand
+}
+
+// delta returns the scaling factor Δ = ⌊q/p⌋
+func (p Params) delta() *big.Int {
+	return new(big.Int).Div(p.Q, p.P)
+}
+
+// round maps x ∈ Z_q to the nearest multiple of Δ, returning the multiple in Z_p
+func (p Params) round(x *big.Int) uint64 {
+	val, _ := p.decode(x)
+	return val
+}
+
+// decode rounds x ∈ Z_q as in [Params.round], and also returns its margin, the distance from x to the nearest
+// rounding threshold
+//
+// round(x / Δ) = ⌊(x + ⌊Δ/2⌋) / Δ⌋, then reduce mod p since values just below q wrap around to 0.
+// With r = (x + ⌊Δ/2⌋) mod Δ, x can move down by r or up by Δ-1-r and still round to the same value,
+// so the margin is the smaller of the two, at most ⌊(Δ-1)/2⌋ for noise-free values and 0 right next to a threshold.
+func (p Params) decode(x *big.Int) (uint64, *big.Int) {
+	delta := p.delta()
+	val := new(big.Int).Rsh(delta, 1)
+	val.Add(val, x)
+	val, r := val.DivMod(val, delta, new(big.Int))
+	val.Mod(val, p.P)
+
+	up := new(big.Int).Sub(delta, r)
+	up.Sub(up, big.NewInt(1))
+	if up.Cmp(r) < 0 {
+		r = up
+	}
+	return val.Uint64(), r
+}
+
+// checkMargin returns an error wrapping [ErrLowMargin] if margin is inside the safety band
+func (p Params) checkMargin(margin *big.Int) error {
+	if p.SafetyBand != nil && margin.Cmp(p.SafetyBand) < 0 {
+		return fmt.Errorf("%w: margin %v is below %v", ErrLowMargin, margin, p.SafetyBand)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package simplepir
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestParamsValidate(t *testing.T) {
+	valid := Params{N: 8, Q: big.NewInt(1 << 16), P: big.NewInt(2), SqrtN: 8}
+	tests := []struct {
+		name    string
+		modify  func(p *Params)
+		wantErr bool
+	}{
+		{"valid", func(p *Params) {}, false},
+		{"zero n", func(p *Params) { p.N = 0 }, true},
+		{"negative sqrtN", func(p *Params) { p.SqrtN = -1 }, true},
+		{"rectangular", func(p *Params) { p.SqrtN, p.Rows, p.Cols = 0, 4, 16 }, false},
+		{"sqrtN and rows set", func(p *Params) { p.Rows, p.Cols = 4, 16 }, true},
+		{"rows without cols", func(p *Params) { p.SqrtN, p.Rows = 0, 4 }, true},
+		{"negative cols", func(p *Params) { p.SqrtN, p.Rows, p.Cols = 0, 4, -16 }, true},
+		{"nil q", func(p *Params) { p.Q = nil }, true},
+		{"q too small", func(p *Params) { p.Q = big.NewInt(1) }, true},
+		{"nil p", func(p *Params) { p.P = nil }, true},
+		{"p too small", func(p *Params) { p.P = big.NewInt(1) }, true},
+		{"multi-bit p", func(p *Params) { p.P = big.NewInt(256) }, false},
+		{"p equal to q", func(p *Params) { p.P = big.NewInt(1 << 16) }, true},
+		{"uint32 backend with q = 2^32", func(p *Params) { p.Q = big.NewInt(1 << 32); p.Backend = Uint32Backend }, false},
+		{"uint32 backend with q > 2^32", func(p *Params) { p.Q = big.NewInt(1<<32 + 1); p.Backend = Uint32Backend }, true},
+		{"unknown backend", func(p *Params) { p.Backend = Backend(7) }, true},
+		{"safety band", func(p *Params) { p.SafetyBand = big.NewInt(100) }, false},
+		{"negative safety band", func(p *Params) { p.SafetyBand = big.NewInt(-1) }, true},
+		{"p too large for 64 bits", func(p *Params) { p.P = new(big.Int).Lsh(big.NewInt(1), 64); p.Q = new(big.Int).Lsh(big.NewInt(1), 80) }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := valid
+			tt.modify(&p)
+			err := p.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParamsDefaultSampler(t *testing.T) {
+	p := Params{}
+	if p.sampler() != chi {
+		t.Errorf("sampler() = %v, want default sampler %v", p.sampler(), chi)
+	}
+	g := NewGaussSampler(0, 1, 10)
+	p.Sampler = g
+	if p.sampler() != g {
+		t.Errorf("sampler() = %v, want configured sampler %v", p.sampler(), g)
+	}
+}
+
+func TestParamsDelta(t *testing.T) {
+	tests := []struct {
+		q, p, expected int64
+	}{
+		{1 << 16, 2, 1 << 15},
+		{1 << 16, 256, 1 << 8},
+		{100, 3, 33},
+	}
+	for _, tt := range tests {
+		p := Params{Q: big.NewInt(tt.q), P: big.NewInt(tt.p)}
+		if got := p.delta(); got.Int64() != tt.expected {
+			t.Errorf("delta() for q=%d, p=%d = %v, want %v", tt.q, tt.p, got, tt.expected)
+		}
+	}
+}
+
+func TestParamsDecode(t *testing.T) {
+	// Δ = 10, so values round to the nearest multiple of 10 with thresholds between 4|5, 14|15, ...
+	params := Params{Q: big.NewInt(100), P: big.NewInt(10)}
+	tests := []struct {
+		x      int64
+		value  uint64
+		margin int64
+	}{
+		{30, 3, 4},
+		{26, 3, 1},
+		{25, 3, 0},
+		{24, 2, 0},
+		{34, 3, 0},
+		{35, 4, 0},
+		{0, 0, 4},
+		{99, 0, 4}, // wraps around to 0
+		{95, 0, 0},
+	}
+	for _, tt := range tests {
+		value, margin := params.decode(big.NewInt(tt.x))
+		if value != tt.value || margin.Int64() != tt.margin {
+			t.Errorf("decode(%v) = %v, %v, want %v, %v", tt.x, value, margin, tt.value, tt.margin)
+		}
+		if got := params.round(big.NewInt(tt.x)); got != value {
+			t.Errorf("round(%v) = %v, want %v as from decode()", tt.x, got, value)
+		}
+	}
+
+	if err := params.checkMargin(big.NewInt(0)); err != nil {
+		t.Errorf("checkMargin() without a safety band error = %v", err)
+	}
+	params.SafetyBand = big.NewInt(2)
+	if err := params.checkMargin(big.NewInt(2)); err != nil {
+		t.Errorf("checkMargin() on the band error = %v", err)
+	}
+	if err := params.checkMargin(big.NewInt(1)); !errors.Is(err, ErrLowMargin) {
+		t.Errorf("checkMargin() inside the band error = %v, want %v", err, ErrLowMargin)
+	}
+}
+
+func TestParamsShape(t *testing.T) {
+	tests := []struct {
+		name       string
+		params     Params
+		rows, cols int
+	}{
+		{"square", Params{SqrtN: 8}, 8, 8},
+		{"rectangular", Params{Rows: 4, Cols: 16}, 4, 16},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rows, cols := tt.params.Shape(); rows != tt.rows || cols != tt.cols {
+				t.Errorf("Shape() = %v, %v, want %v, %v", rows, cols, tt.rows, tt.cols)
+			}
+		})
+	}
+}
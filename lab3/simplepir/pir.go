@@ -15,66 +15,145 @@ var chi GaussSampler = GaussSampler{t: t, sigma: sigma, tau: tau}
 
 // pirSetup initializes the hint values
 //
-// Corresponds to pirSetup(db ∈ Z^{√𝑁×√𝑁}_p) -> (hintS,hintC)
+// Corresponds to pirSetup(db ∈ Z^{√𝑁×√𝑁}_p) -> (hintS,hintC), generalised to db ∈ Z^{ℓ×m}_p giving hintC ∈ Z^{ℓ×n}_q
 //
 // hintS seems unused, and does not correspond to anything in the slides, so it is omitted here
 //
 // In the slides hintC is called A'
 //
+// Additional inputs: the modulus q and the number of goroutines params.Workers to split the multiplication over
+//
 // Source: Hezinger et al.'s Simple PIR (https://www.usenix.org/system/files/usenixsecurity23-henzinger.pdf)
-func pirSetup(db, A *Mat, mod *big.Int) *Mat {
-	return db.MatMul(A, mod) // hintC aka A'
+//
+// A database mapped from a file with [OpenMatFile] is streamed through a block of rows at a time, so that the
+// whole file is never resident at once
+func pirSetup(db, A *Mat, params Params) *Mat {
+	if db.release == nil {
+		return db.MatMulParallel(A, params.Q, params.Workers) // hintC aka A'
+	}
+	hintC := NewMatWith(db.rows, A.cols, db.backend)
+	db.streamRows(func(lo, hi int) {
+		block := db.rowBlock(lo, hi).MatMulParallel(A, params.Q, params.Workers)
+		copy(hintC.packed[lo*hintC.cols:hi*hintC.cols], block.packed)
+	})
+	return hintC
 }
 
-type queryState struct {
-	j int
-	s *Vec
+// QueryState is the client-side state kept between issuing a query and recovering the answer
+//
+// Fields are hidden as they include the secret s, which must never leave the client
+type QueryState struct {
+	row int  // row of the database entry being retrieved
+	col int  // column of the database entry, which the client decodes in full to verify answers
+	s   *Vec // LWE secret used to build the query
 }
 
-// pirQuery generates the query vector
+// pirQuery generates the query vector for the database entry at (i, j)
+//
+// takes indexes i (row) and j (column) and the public matrix A
 //
-// takes indexes i,j and matrix A
+// also depends on the parameters q, p and the error sampler χ from params, and draws s and the error
+// from params.Rand when it is set. The query has one entry per row of A, i.e. per column of the database.
 //
-// also depends on sqrtN and q, which are parameters of the scheme and a random sampler
-func pirQuery(i, j int, A *Mat, sqrtN int, q *big.Int, sampler Sampler) (queryState, *Vec) {
-	v := NewVec(sqrtN).OneHot(i)
-	s := NewVec(sqrtN).FillRandom(q)
-	e_vals := make([]int64, sqrtN)
-	for i := range sqrtN {
-		e_vals[i] = int64(sampler.Sample())
+// the one-hot vector is scaled by Δ = ⌊q/p⌋, so that each database entry can hold a value in Z_p
+func pirQuery(i, j int, A *Mat, params Params) (QueryState, *Vec) {
+	m, q := A.rows, params.Q
+	sampler := params.sampler()
+	s := NewVecWith(A.cols, params.Backend).FillRandomFrom(params.random(), q)
+	// e + Δ·u_j is built in a scratch vector, and qu is the only vector allocated besides s
+	e := getScratch(m, params.Backend)
+	defer putScratch(e)
+	// reduce the error mod q up front, as [Uint32Backend] would otherwise wrap negative samples mod 2^32
+	x := new(big.Int)
+	for i := range m {
+		e.Set(i, x.Mod(x.SetInt64(int64(sampleFrom(sampler, params.Rand))), q))
 	}
-	e := NewVec(sqrtN).Fill(e_vals)
-	q_over_2 := new(big.Int).Div(q, big.NewInt(2))
-	return queryState{j, s}, A.VecMul(s, q).Add(e, q).Add(v.Scale(q_over_2, q), q)
+	e.Set(j, x.Mod(x.Add(e.Get(j), params.delta()), q))
+	qu := NewVecWith(m, A.backend).SetVecMul(A, s, q)
+	return QueryState{i, j, s}, qu.SetAdd(qu, e, q)
 }
 
 // pirAnswer computes the answer based on the query
 //
 // Inputs: takes the database db and the query qu
 //
-// Additional inputs: parameter q of the protocol
+// Additional inputs: parameter q of the protocol, and the number of goroutines params.Workers to split the scan over
 //
-// responds with ans (called c' in the slides)
-func pirAnswer(db *Mat, qu *Vec, q *big.Int) *Vec {
-	return db.VecMul(qu, q)
+// responds with ans (called c' in the slides). qu arrives from the network, so a malformed query gives an error
+// (see [Mat.VecMulParallelChecked]) rather than a panic
+func pirAnswer(db *Mat, qu *Vec, params Params) (*Vec, error) {
+	return db.VecMulParallelChecked(qu, params.Q, params.Workers)
 }
 
 // pirRecover extracts the database value from the answer
 //
 // input: takes ans (c' in the slides)
 //
-// additional inputs: the state st (comprising j and s from [query]), hintC aka A' and the modulus q.
+// additional inputs: the state st (comprising the row and s from [pirQuery]), hintC aka A' and the parameters q and p.
+//
+// ans - hintC·s = Δ·db[row][col] + noise, so rounding to the nearest multiple of Δ = ⌊q/p⌋ gives back the value in Z_p.
+// Also returns the margin of the rounding (see [Params.decode]), how much further the noise could have grown
+// before the value came out wrong.
+func pirRecover(ans *Vec, st QueryState, hintC *Mat, params Params) (uint64, *big.Int) {
+	r := getScratch(hintC.rows, hintC.backend)
+	defer putScratch(r)
+	r.SetVecMul(hintC, st.s, params.Q).SetSub(ans, r, params.Q)
+	return params.decode(r.Get(st.row))
+}
+
+// pirRecoverColumn extracts every value in the queried column from the answer
+//
+// a query for (row, col) hides col from the server, but ans carries Δ·db[r][col] + noise for every row r,
+// so the client can decode the whole column at the cost of a single query. The margin of each value is returned alongside it.
+func pirRecoverColumn(ans *Vec, st QueryState, hintC *Mat, params Params) ([]uint64, []*big.Int) {
+	r := getScratch(hintC.rows, hintC.backend)
+	defer putScratch(r)
+	r.SetVecMul(hintC, st.s, params.Q).SetSub(ans, r, params.Q)
+	result, margins := make([]uint64, r.size), make([]*big.Int, r.size)
+	for i := range r.size {
+		result[i], margins[i] = params.decode(r.Get(i))
+	}
+	return result, margins
+}
+
+// pirQueryBatch generates one query per index, packed as the columns of an m × k matrix
+//
+// each column is built exactly as in [pirQuery], with its own secret and error, so the queries are independent
+func pirQueryBatch(indices []Cell, A *Mat, params Params) ([]QueryState, *Mat) {
+	states := make([]QueryState, len(indices))
+	qus := NewMatWith(A.rows, len(indices), params.Backend)
+	for k, idx := range indices {
+		var qu *Vec
+		states[k], qu = pirQuery(idx.Row, idx.Col, A, params)
+		qus.setCol(k, qu)
+	}
+	return states, qus
+}
+
+// pirAnswerBatch answers every query column of qus with a single pass over the database
+//
+// ans = db·qus, so column k of ans is [pirAnswer] applied to column k of qus
+func pirAnswerBatch(db, qus *Mat, params Params) (*Mat, error) {
+	return db.MatMulParallelChecked(qus, params.Q, params.Workers)
+}
+
+// pirRecoverBatch extracts the database value for every query in the batch
 //
-// returns the bit value inside the database (i.e. true for 1 and false for 0)
-func pirRecover(ans *Vec, st queryState, hintC *Mat, q *big.Int) byte {
-	r := ans.Sub(hintC.VecMul(st.s, q), q)
-	q_over_4 := new(big.Int).Div(q, big.NewInt(4))
-	q_over_4_times_3 := new(big.Int).Mul(q_over_4, big.NewInt(3))
+// the hint products hintC·s for all k secrets are computed together as hintC·S, where column k of S is the k-th secret.
+// The margin of each value is returned alongside it.
+func pirRecoverBatch(ans *Mat, states []QueryState, hintC *Mat, params Params) ([]uint64, []*big.Int) {
+	secrets := NewMatWith(hintC.cols, len(states), params.Backend)
+	for k, st := range states {
+		secrets.setCol(k, st.s)
+	}
+	hs := hintC.MatMul(secrets, params.Q)
 
-	ind := r.data[st.j]
-	if ind.Cmp(q_over_4) >= 0 && ind.Cmp(q_over_4_times_3) <= 0 {
-		return 1
-	} else {
-		return 0
+	result, margins := make([]uint64, len(states)), make([]*big.Int, len(states))
+	r := new(big.Int)
+	for k, st := range states {
+		r.Sub(ans.Get(st.row, k), hs.Get(st.row, k))
+		r.Mod(r, params.Q)
+		result[k], margins[k] = params.decode(r)
 	}
+	return result, margins
 }
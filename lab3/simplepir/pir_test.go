@@ -7,7 +7,14 @@ import (
 	"testing"
 )
 
-func TestSetup(t *testing.T) {
+// testParams builds square [Params] with n = sqrtN and p = 2 for the unit tests below
+func testParams(sqrtN int, q *big.Int, sampler Sampler, backend Backend) Params {
+	return Params{N: sqrtN, Q: q, P: big.NewInt(2), SqrtN: sqrtN, Sampler: sampler, Backend: backend}
+}
+
+func TestSetup(t *testing.T) { forEachBackend(t, testSetup) }
+
+func testSetup(t *testing.T, backend Backend) {
 	testCases := []struct {
 		name    string
 		dbRows  int
@@ -22,10 +29,10 @@ func TestSetup(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			mod := big.NewInt(tc.modulus)
-			db := NewMat(tc.dbRows, tc.dbCols).FillRandom(mod)
-			A := NewMat(tc.dbCols, tc.dbCols).FillRandom(mod)
+			db := NewMatWith(tc.dbRows, tc.dbCols, backend).FillRandom(mod)
+			A := NewMatWith(tc.dbCols, tc.dbCols, backend).FillRandom(mod)
 
-			hintC := pirSetup(db, A, mod)
+			hintC := pirSetup(db, A, testParams(tc.dbCols, mod, chi, backend))
 
 			// Verify hintC = db * A
 			expected := db.MatMul(A, mod)
@@ -42,7 +49,9 @@ func TestSetup(t *testing.T) {
 	}
 }
 
-func TestQuery(t *testing.T) {
+func TestQuery(t *testing.T) { forEachBackend(t, testQuery) }
+
+func testQuery(t *testing.T, backend Backend) {
 	testCases := []struct {
 		name    string
 		sqrtN   int
@@ -60,7 +69,7 @@ func TestQuery(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			q := big.NewInt(tc.modulus)
-			A := NewMat(tc.sqrtN, tc.sqrtN).FillRandom(q)
+			A := NewMatWith(tc.sqrtN, tc.sqrtN, backend).FillRandom(q)
 			sampler := chi
 
 			// Verify i and j are in bounds
@@ -68,15 +77,15 @@ func TestQuery(t *testing.T) {
 				t.Fatalf("Invalid index values: i=%d, j=%d for sqrtN=%d", tc.i, tc.j, tc.sqrtN)
 			}
 
-			st, qu := pirQuery(tc.i, tc.j, A, tc.sqrtN, q, sampler)
+			st, qu := pirQuery(tc.i, tc.j, A, testParams(tc.sqrtN, q, sampler, backend))
 
 			// Basic checks
 			if qu == nil {
 				t.Fatal("Query failed: qu is nil")
 			}
 
-			if st.j != tc.j {
-				t.Errorf("Query state incorrect: expected j=%d, got j=%d", tc.j, st.j)
+			if st.row != tc.i {
+				t.Errorf("Query state incorrect: expected row=%d, got row=%d", tc.i, st.row)
 			}
 
 			if st.s == nil {
@@ -90,15 +99,17 @@ func TestQuery(t *testing.T) {
 
 			// Verify values are in range [0, q-1]
 			for i := range qu.size {
-				if qu.data[i].Cmp(big.NewInt(0)) < 0 || qu.data[i].Cmp(q) >= 0 {
-					t.Errorf("Query vector contains out-of-range value at index %d: %v", i, qu.data[i])
+				if qu.Get(i).Cmp(big.NewInt(0)) < 0 || qu.Get(i).Cmp(q) >= 0 {
+					t.Errorf("Query vector contains out-of-range value at index %d: %v", i, qu.Get(i))
 				}
 			}
 		})
 	}
 }
 
-func TestAnswer(t *testing.T) {
+func TestAnswer(t *testing.T) { forEachBackend(t, testAnswer) }
+
+func testAnswer(t *testing.T, backend Backend) {
 	testCases := []struct {
 		name    string
 		dbRows  int
@@ -113,10 +124,10 @@ func TestAnswer(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			mod := big.NewInt(tc.modulus)
-			db := NewMat(tc.dbRows, tc.dbCols).FillRandom(mod)
-			qu := NewVec(tc.dbCols).FillRandom(mod)
+			db := NewMatWith(tc.dbRows, tc.dbCols, backend).FillRandom(mod)
+			qu := NewVecWith(tc.dbCols, backend).FillRandom(mod)
 
-			ans := pirAnswer(db, qu, mod)
+			ans, _ := pirAnswer(db, qu, testParams(tc.dbCols, mod, chi, backend))
 
 			// Basic check
 			if ans == nil {
@@ -136,15 +147,17 @@ func TestAnswer(t *testing.T) {
 
 			// Verify values are in range [0, mod-1]
 			for i := range ans.size {
-				if ans.data[i].Cmp(big.NewInt(0)) < 0 || ans.data[i].Cmp(mod) >= 0 {
-					t.Errorf("Answer vector contains out-of-range value at index %d: %v", i, ans.data[i])
+				if ans.Get(i).Cmp(big.NewInt(0)) < 0 || ans.Get(i).Cmp(mod) >= 0 {
+					t.Errorf("Answer vector contains out-of-range value at index %d: %v", i, ans.Get(i))
 				}
 			}
 		})
 	}
 }
 
-func TestFullProtocol(t *testing.T) {
+func TestFullProtocol(t *testing.T) { forEachBackend(t, testFullProtocol) }
+
+func testFullProtocol(t *testing.T, backend Backend) {
 	testCases := []struct {
 		name    string
 		sqrtN   int
@@ -161,37 +174,37 @@ func TestFullProtocol(t *testing.T) {
 			mod := big.NewInt(tc.modulus)
 
 			// Create database with known values
-			db := NewMat(tc.sqrtN, tc.sqrtN)
+			db := NewMatWith(tc.sqrtN, tc.sqrtN, backend)
 			for i := range tc.sqrtN {
 				for j := range tc.sqrtN {
 					// Fill with 0s and 1s in a checkerboard pattern
 					if (i+j)%2 == 0 {
-						db.data[i][j] = big.NewInt(0)
+						db.Set(i, j, big.NewInt(0))
 					} else {
-						db.data[i][j] = big.NewInt(1)
+						db.Set(i, j, big.NewInt(1))
 					}
 				}
 			}
 
 			// Initialise scheme
-			A := NewMat(tc.sqrtN, tc.sqrtN).FillRandom(mod)
-			hintC := pirSetup(db, A, mod)
+			A := NewMatWith(tc.sqrtN, tc.sqrtN, backend).FillRandom(mod)
+			hintC := pirSetup(db, A, testParams(tc.sqrtN, mod, chi, backend))
 
 			// Test multiple positions
 			for i := range tc.sqrtN {
 				for j := range tc.sqrtN {
 					t.Run(fmt.Sprintf("Position_%d_%d", i, j), func(t *testing.T) {
 						// Create query
-						st, qu := pirQuery(i, j, A, tc.sqrtN, mod, chi)
+						st, qu := pirQuery(i, j, A, testParams(tc.sqrtN, mod, chi, backend))
 
 						// Generate answer
-						ans := pirAnswer(db, qu, mod)
+						ans, _ := pirAnswer(db, qu, testParams(tc.sqrtN, mod, chi, backend))
 
 						// Recover the result
-						result := pirRecover(ans, st, hintC, mod)
+						result, _ := pirRecover(ans, st, hintC, testParams(tc.sqrtN, mod, chi, backend))
 
 						// Verify result
-						expected := byte(db.data[i][j].Bit(0))
+						expected := db.Get(i, j).Uint64()
 						if result != expected {
 							t.Errorf("Recover failed at position (%d,%d): got %v, expected %v", i, j, result, expected)
 						}
@@ -202,7 +215,9 @@ func TestFullProtocol(t *testing.T) {
 	}
 }
 
-func TestRecover(t *testing.T) {
+func TestRecover(t *testing.T) { forEachBackend(t, testRecover) }
+
+func testRecover(t *testing.T, backend Backend) {
 	// The issue might be with the modulus value or the way we test recovery
 	// Let's use a known working modulus and simplify the test
 
@@ -214,54 +229,54 @@ func TestRecover(t *testing.T) {
 
 	t.Run("SimpleRecoveryTest", func(t *testing.T) {
 		// Create a deterministic database with known values for testing
-		db := NewMat(sqrtN, sqrtN)
+		db := NewMatWith(sqrtN, sqrtN, backend)
 		for i := range sqrtN {
 			for j := range sqrtN {
 				// Simple pattern: even indices get 0, odd indices get 1
 				if (i+j)%2 == 0 {
-					db.data[i][j] = big.NewInt(0)
+					db.Set(i, j, big.NewInt(0))
 				} else {
-					db.data[i][j] = big.NewInt(1)
+					db.Set(i, j, big.NewInt(1))
 				}
 			}
 		}
 
 		// Initialise a deterministic matrix A instead of random
 		// set to the identitity matrix
-		A := NewMat(sqrtN, sqrtN)
+		A := NewMatWith(sqrtN, sqrtN, backend)
 		for i := range sqrtN {
 			for j := range sqrtN {
 				if i == j {
-					A.data[i][j] = big.NewInt(1)
+					A.Set(i, j, big.NewInt(1))
 				} else {
-					A.data[i][j] = big.NewInt(0)
+					A.Set(i, j, big.NewInt(0))
 				}
 			}
 		}
 
 		// Pre-compute the hint once
-		hintC := pirSetup(db, A, mod)
+		hintC := pirSetup(db, A, testParams(sqrtN, mod, chi, backend))
 
 		// Test a single element first to debug
 		i, j := 1, 2 // Choose coordinates where we know the value
-		expected := byte(db.data[i][j].Bit(0))
+		expected := db.Get(i, j).Uint64()
 
 		// Go through the full protocol with detailed logging
-		st, qu := pirQuery(i, j, A, sqrtN, mod, sampler)
+		st, qu := pirQuery(i, j, A, testParams(sqrtN, mod, sampler, backend))
 
 		// Verify the state has correct j value
-		if st.j != j {
-			t.Errorf("Query state has incorrect j value: expected %d, got %d", j, st.j)
+		if st.row != i {
+			t.Errorf("Query state has incorrect row value: expected %d, got %d", i, st.row)
 		}
 
-		ans := pirAnswer(db, qu, mod)
+		ans, _ := pirAnswer(db, qu, testParams(sqrtN, mod, chi, backend))
 
 		// Print intermediate values for debugging
 		t.Logf("Testing recovery for position (%d,%d), expected value: %d", i, j, expected)
 		t.Logf("hintC dimensions: %dx%d", hintC.rows, hintC.cols)
 		t.Logf("answer vector size: %d", ans.size)
 
-		result := pirRecover(ans, st, hintC, mod)
+		result, _ := pirRecover(ans, st, hintC, testParams(sqrtN, mod, sampler, backend))
 
 		if result != expected {
 			t.Errorf("Recovery failed at (%d,%d): got %v, expected %v", i, j, result, expected)
@@ -277,17 +292,17 @@ func TestRecover(t *testing.T) {
 		}
 
 		// Create a binary database with random values
-		db := NewMat(sqrtN, sqrtN).FillRandom(big.NewInt(2))
+		db := NewMatWith(sqrtN, sqrtN, backend).FillRandom(big.NewInt(2))
 
 		// Use a deterministic A matrix for testing
-		A := NewMat(sqrtN, sqrtN)
+		A := NewMatWith(sqrtN, sqrtN, backend)
 		for i := range sqrtN {
 			for j := range sqrtN {
-				A.data[i][j] = big.NewInt(int64(i+j+1) % mod.Int64())
+				A.Set(i, j, big.NewInt(int64(i+j+1)%mod.Int64()))
 			}
 		}
 
-		hintC := pirSetup(db, A, mod)
+		hintC := pirSetup(db, A, testParams(sqrtN, mod, chi, backend))
 
 		// Test a few random positions
 		testPositions := []struct{ i, j int }{
@@ -300,11 +315,11 @@ func TestRecover(t *testing.T) {
 
 		for _, pos := range testPositions {
 			i, j := pos.i, pos.j
-			expected := byte(db.data[i][j].Bit(0))
+			expected := db.Get(i, j).Uint64()
 
-			st, qu := pirQuery(i, j, A, sqrtN, mod, sampler)
-			ans := pirAnswer(db, qu, mod)
-			result := pirRecover(ans, st, hintC, mod)
+			st, qu := pirQuery(i, j, A, testParams(sqrtN, mod, sampler, backend))
+			ans, _ := pirAnswer(db, qu, testParams(sqrtN, mod, chi, backend))
+			result, _ := pirRecover(ans, st, hintC, testParams(sqrtN, mod, sampler, backend))
 
 			if result != expected {
 				t.Errorf("Recovery failed at (%d,%d): got %v, expected %v", i, j, result, expected)
@@ -313,7 +328,9 @@ func TestRecover(t *testing.T) {
 	})
 }
 
-func TestEdgeCases(t *testing.T) {
+func TestEdgeCases(t *testing.T) { forEachBackend(t, testEdgeCases) }
+
+func testEdgeCases(t *testing.T, backend Backend) {
 	// Test with different moduli values
 	moduli := []int64{1 << 10, 1 << 14, 1 << 20}
 	sqrtN := 8
@@ -322,24 +339,24 @@ func TestEdgeCases(t *testing.T) {
 	t.Run("AllZeros", func(t *testing.T) {
 		for _, modVal := range moduli {
 			mod := big.NewInt(modVal)
-			db := NewMat(sqrtN, sqrtN)
+			db := NewMatWith(sqrtN, sqrtN, backend)
 			// Fill with zeros
 			for i := range sqrtN {
 				for j := range sqrtN {
-					db.data[i][j] = big.NewInt(0)
+					db.Set(i, j, big.NewInt(0))
 				}
 			}
 
-			A := NewMat(sqrtN, sqrtN).FillRandom(mod)
-			hintC := pirSetup(db, A, mod)
+			A := NewMatWith(sqrtN, sqrtN, backend).FillRandom(mod)
+			hintC := pirSetup(db, A, testParams(sqrtN, mod, chi, backend))
 
 			// Test a few positions
 			for _, idx := range []int{0, sqrtN / 2, sqrtN - 1} {
-				st, qu := pirQuery(idx, idx, A, sqrtN, mod, chi)
-				ans := pirAnswer(db, qu, mod)
-				result := pirRecover(ans, st, hintC, mod)
+				st, qu := pirQuery(idx, idx, A, testParams(sqrtN, mod, chi, backend))
+				ans, _ := pirAnswer(db, qu, testParams(sqrtN, mod, chi, backend))
+				result, _ := pirRecover(ans, st, hintC, testParams(sqrtN, mod, chi, backend))
 
-				expected := byte(0)
+				expected := uint64(0)
 				if result != expected {
 					t.Errorf("Failed with all-zero DB at pos (%d,%d): got %v, expected %v", idx, idx, result, expected)
 				}
@@ -351,24 +368,24 @@ func TestEdgeCases(t *testing.T) {
 	t.Run("AllOnes", func(t *testing.T) {
 		for _, modVal := range moduli {
 			mod := big.NewInt(modVal)
-			db := NewMat(sqrtN, sqrtN)
+			db := NewMatWith(sqrtN, sqrtN, backend)
 			// Fill with ones
 			for i := range sqrtN {
 				for j := range sqrtN {
-					db.data[i][j] = big.NewInt(1)
+					db.Set(i, j, big.NewInt(1))
 				}
 			}
 
-			A := NewMat(sqrtN, sqrtN).FillRandom(mod)
-			hintC := pirSetup(db, A, mod)
+			A := NewMatWith(sqrtN, sqrtN, backend).FillRandom(mod)
+			hintC := pirSetup(db, A, testParams(sqrtN, mod, chi, backend))
 
 			// Test a few positions
 			for _, idx := range []int{0, sqrtN / 2, sqrtN - 1} {
-				st, qu := pirQuery(idx, idx, A, sqrtN, mod, chi)
-				ans := pirAnswer(db, qu, mod)
-				result := pirRecover(ans, st, hintC, mod)
+				st, qu := pirQuery(idx, idx, A, testParams(sqrtN, mod, chi, backend))
+				ans, _ := pirAnswer(db, qu, testParams(sqrtN, mod, chi, backend))
+				result, _ := pirRecover(ans, st, hintC, testParams(sqrtN, mod, chi, backend))
 
-				expected := byte(1)
+				expected := uint64(1)
 				if result != expected {
 					t.Errorf("Failed with all-one DB at pos (%d,%d): got %v, expected %v", idx, idx, result, expected)
 				}
@@ -376,3 +393,151 @@ func TestEdgeCases(t *testing.T) {
 		}
 	})
 }
+
+func TestMultiBitProtocol(t *testing.T) { forEachBackend(t, testMultiBitProtocol) }
+
+func testMultiBitProtocol(t *testing.T, backend Backend) {
+	testCases := []struct {
+		name    string
+		sqrtN   int
+		modulus int64
+		p       int64
+	}{
+		{"Ternary entries", 8, 1 << 16, 3},
+		{"Nibble entries", 8, 1 << 20, 16},
+		{"Byte entries", 16, 1 << 32, 256},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			params := testParams(tc.sqrtN, big.NewInt(tc.modulus), chi, backend)
+			params.P = big.NewInt(tc.p)
+
+			db := NewMatWith(tc.sqrtN, tc.sqrtN, backend).FillRandom(params.P)
+			A := NewMatWith(tc.sqrtN, tc.sqrtN, backend).FillRandom(params.Q)
+			hintC := pirSetup(db, A, params)
+
+			for i := range tc.sqrtN {
+				for j := range tc.sqrtN {
+					st, qu := pirQuery(i, j, A, params)
+					ans, _ := pirAnswer(db, qu, params)
+					result, _ := pirRecover(ans, st, hintC, params)
+
+					if expected := db.Get(i, j).Uint64(); result != expected {
+						t.Errorf("Recover failed at (%d,%d): got %v, expected %v", i, j, result, expected)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRecoverColumn(t *testing.T) { forEachBackend(t, testRecoverColumn) }
+
+func testRecoverColumn(t *testing.T, backend Backend) {
+	sqrtN := 8
+	params := testParams(sqrtN, big.NewInt(1<<32), chi, backend)
+	params.P = big.NewInt(256)
+
+	db := NewMatWith(sqrtN, sqrtN, backend).FillRandom(params.P)
+	A := NewMatWith(sqrtN, sqrtN, backend).FillRandom(params.Q)
+	hintC := pirSetup(db, A, params)
+
+	for col := range sqrtN {
+		st, qu := pirQuery(0, col, A, params)
+		ans, _ := pirAnswer(db, qu, params)
+		column, _ := pirRecoverColumn(ans, st, hintC, params)
+
+		if len(column) != sqrtN {
+			t.Fatalf("pirRecoverColumn() returned %d values, want %d", len(column), sqrtN)
+		}
+		for row, got := range column {
+			if expected := db.Get(row, col).Uint64(); got != expected {
+				t.Errorf("column %d row %d = %v, want %v", col, row, got, expected)
+			}
+		}
+	}
+}
+
+func TestBatchProtocol(t *testing.T) { forEachBackend(t, testBatchProtocol) }
+
+func testBatchProtocol(t *testing.T, backend Backend) {
+	sqrtN := 8
+	params := testParams(sqrtN, big.NewInt(1<<32), chi, backend)
+	params.P = big.NewInt(256)
+
+	db := NewMatWith(sqrtN, sqrtN, backend).FillRandom(params.P)
+	A := NewMatWith(sqrtN, 16, backend).FillRandom(params.Q)
+	params.N = 16
+	hintC := pirSetup(db, A, params)
+
+	indices := []Cell{{0, 0}, {7, 7}, {3, 5}, {5, 3}, {3, 5}, {2, 0}}
+	states, qus := pirQueryBatch(indices, A, params)
+	if qus.rows != sqrtN || qus.cols != len(indices) {
+		t.Fatalf("query batch has dimensions (%d,%d), want (%d,%d)", qus.rows, qus.cols, sqrtN, len(indices))
+	}
+
+	ans, _ := pirAnswerBatch(db, qus, params)
+	results, _ := pirRecoverBatch(ans, states, hintC, params)
+
+	for k, idx := range indices {
+		if expected := db.Get(idx.Row, idx.Col).Uint64(); results[k] != expected {
+			t.Errorf("batch entry %d at (%d,%d) = %v, want %v", k, idx.Row, idx.Col, results[k], expected)
+		}
+	}
+}
+
+// BenchmarkBatchAnswer compares k separate answers against one batched answer on a 2^20 entry database
+func BenchmarkBatchAnswer(b *testing.B) {
+	q := big.NewInt(1 << 32)
+	params := Params{Q: q, Workers: 1}
+	db := NewMatWith(1024, 1024, Uint32Backend).FillRandom(big.NewInt(256))
+	for _, k := range []int{1, 8, 32} {
+		qus := NewMatWith(1024, k, Uint32Backend).FillRandom(q)
+		b.Run(fmt.Sprintf("k=%d/separate", k), func(b *testing.B) {
+			vecs := make([]*Vec, k)
+			for j := range k {
+				vecs[j] = NewVecWith(1024, Uint32Backend)
+				for i := range 1024 {
+					vecs[j].Set(i, qus.Get(i, j))
+				}
+			}
+			for b.Loop() {
+				for _, qu := range vecs {
+					pirAnswer(db, qu, params)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("k=%d/batched", k), func(b *testing.B) {
+			for b.Loop() {
+				pirAnswerBatch(db, qus, params)
+			}
+		})
+	}
+}
+
+// BenchmarkQueryRecover measures the client side of a query on a 2^16 entry database, reporting the garbage per query
+//
+// almost all of what is left when querying comes from the rejection sampling in [GaussSampler]
+func BenchmarkQueryRecover(b *testing.B) {
+	for _, backend := range []Backend{BigBackend, Uint32Backend} {
+		params := Params{N: 512, Q: big.NewInt(1 << 32), P: big.NewInt(256), SqrtN: 256, Backend: backend}
+		A := NewMatWith(256, params.N, backend).FillRandom(params.Q)
+		db := NewMatWith(256, 256, backend).FillRandom(params.P)
+		hintC := pirSetup(db, A, params)
+		st, qu := pirQuery(3, 5, A, params)
+		ans, _ := pirAnswer(db, qu, params)
+		b.Run(backend.String()+"/query", func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				pirQuery(3, 5, A, params)
+			}
+		})
+		b.Run(backend.String()+"/recover", func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				pirRecover(ans, st, hintC, params)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package simplepir
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrStaleHint is returned when a client's hint no longer matches the server's database, the client should
+// download the hint again
+var ErrStaleHint = errors.New("hint does not match the server's database")
+
+// HintVersion identifies a hint, so that a client can check whether its cached hint is still current
+//
+// It is a SHA-256 digest of the hint, which changes whenever the database or A does, so the client can compute it
+// from the hint it downloaded rather than trusting a version number sent by the server
+type HintVersion [sha256.Size]byte
+
+// String returns the version as hex
+func (v HintVersion) String() string {
+	return hex.EncodeToString(v[:])
+}
+
+// ParseHintVersion parses a version written by [HintVersion.String]
+func ParseHintVersion(s string) (HintVersion, error) {
+	var v HintVersion
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != len(v) {
+		return v, fmt.Errorf("could not parse hint version %q", s)
+	}
+	copy(v[:], b)
+	return v, nil
+}
+
+// hintVersion digests the dimensions and entries of hint, each entry as a fixed-width little-endian integer,
+// so that the digest does not depend on the [Backend]
+//
+// Rows are digested separately and the version is the digest of the row digests, so that an update only needs to
+// rehash the rows it changes, see [PreparedDB.UpdateBatch]
+func hintVersion(hint *Mat, params Params) HintVersion {
+	return combineRowDigests(hint, hintRowDigests(hint, params))
+}
+
+// hintRowDigests digests every row of hint with [hintRowDigest]
+func hintRowDigests(hint *Mat, params Params) [][sha256.Size]byte {
+	digests := make([][sha256.Size]byte, hint.rows)
+	for i := range digests {
+		digests[i] = hintRowDigest(hint, i, params)
+	}
+	return digests
+}
+
+// hintRowDigest digests row i of hint
+func hintRowDigest(hint *Mat, i int, params Params) [sha256.Size]byte {
+	width := max(1, (params.Q.BitLen()+7)/8)
+	buf := make([]byte, 0, hint.cols*width)
+	for j := range hint.cols {
+		buf = appendEntry(buf, hint.Get(i, j), width)
+	}
+	return sha256.Sum256(buf)
+}
+
+// combineRowDigests returns the version of hint from the digests of its rows
+func combineRowDigests(hint *Mat, digests [][sha256.Size]byte) HintVersion {
+	h := sha256.New()
+	buf := binary.LittleEndian.AppendUint64(nil, uint64(hint.rows))
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(hint.cols))
+	h.Write(buf)
+	for _, d := range digests {
+		h.Write(d[:])
+	}
+	return HintVersion(h.Sum(nil))
+}
+
+// PreparedDB is a database after the offline phase: A has been expanded and the hint computed and cached
+//
+// The offline phase is the expensive [pirSetup], done once per database. Clients download the hint once and check
+// [PreparedDB.Version] in the online phase, in which each query only costs a pass over the database.
+//
+// The database can be changed afterwards with [PreparedDB.UpdateBatch], which patches the database and hint
+// rather than recomputing the hint. Neither the matrix passed to [Prepare] nor a hint returned by
+// [PreparedDB.Hint] is ever modified: an update first copies whichever of them it would write to.
+//
+// hidden fields so that the database and hint can only change through updates, construct with [Prepare].
+// Safe for concurrent use.
+type PreparedDB struct {
+	params     Params
+	seed       []byte
+	a          *Mat
+	mu         sync.RWMutex // guards db, hint, rowDigests, version and commitment, which updates modify in place
+	db         *Mat
+	dbShared   bool // db is the caller's matrix, copied by the first update
+	hint       *Mat
+	hintShared atomic.Bool         // hint has been returned by [PreparedDB.Hint], copied by the next update
+	rowDigests [][sha256.Size]byte // of each row of the hint, see [hintVersion]
+	version    HintVersion
+	commitment *Commitment // nil until first requested with [PreparedDB.Commitment]
+}
+
+// Prepare runs the offline phase for db under the given parameters
+//
+// Expands the public matrix A from a fresh seed and computes the hint with [pirSetup]. db is kept rather than
+// copied when it already uses params.Backend, so callers must not modify it afterwards. Updates never write to it,
+// the first one copies it.
+func Prepare(params Params, db *Mat) (*PreparedDB, error) {
+	if err := checkDatabase(params, db); err != nil {
+		return nil, err
+	}
+	prepared := db.as(params.Backend)
+	seed := newSeedFrom(params.random())
+	A, err := ExpandA(seed, params)
+	if err != nil {
+		return nil, fmt.Errorf("could not expand A: %v", err)
+	}
+	hint := pirSetup(prepared, A, params)
+	digests := hintRowDigests(hint, params)
+	return &PreparedDB{
+		params: params, db: prepared, dbShared: prepared == db, seed: seed, a: A, hint: hint, rowDigests: digests,
+		version: combineRowDigests(hint, digests),
+	}, nil
+}
+
+// Params returns the parameters the database was prepared with
+func (p *PreparedDB) Params() Params {
+	return p.params
+}
+
+// Seed returns the seed A was expanded from
+func (p *PreparedDB) Seed() []byte {
+	return slices.Clone(p.seed) // defensive clone
+}
+
+// Hint returns the cached hint hintC = db·A
+//
+// The returned matrix is shared with the database and must not be modified. Updates copy the hint before
+// patching it, so the returned matrix keeps the values it had when returned.
+func (p *PreparedDB) Hint() *Mat {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	p.hintShared.Store(true)
+	return p.hint
+}
+
+// Version returns the version of the cached hint
+func (p *PreparedDB) Version() HintVersion {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.version
+}
+
+// CheckVersion returns an error wrapping [ErrStaleHint] if v is not the version of the cached hint
+func (p *PreparedDB) CheckVersion(v HintVersion) error {
+	if current := p.Version(); v != current {
+		return fmt.Errorf("%w: client has version %v, server has %v", ErrStaleHint, v, current)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package simplepir
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestPrepare(t *testing.T) {
+	forEachBackend(t, testPrepare)
+}
+
+func testPrepare(t *testing.T, backend Backend) {
+	params := Params{N: 32, Q: new(big.Int).Lsh(big.NewInt(1), 32), P: big.NewInt(991), SqrtN: 8, Backend: backend}
+	db := NewMatWith(8, 8, backend).FillRandom(params.P)
+	prep, err := Prepare(params, db)
+	if err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+	server := NewServerFromPrepared(prep)
+	client, err := NewClientFromSeed(params, server.Seed(), server.Hint())
+	if err != nil {
+		t.Fatalf("NewClientFromSeed() error = %v", err)
+	}
+	if client.Version() != server.Version() {
+		t.Errorf("client version %v, server version %v, want equal", client.Version(), server.Version())
+	}
+	if err := server.CheckVersion(client.Version()); err != nil {
+		t.Errorf("CheckVersion() error = %v", err)
+	}
+
+	// the version depends only on the hint's values, not its backend
+	other := params
+	other.Backend = BigBackend
+	if backend == BigBackend {
+		other.Backend = Uint32Backend
+	}
+	if v := hintVersion(server.Hint().as(other.Backend), other); v != server.Version() {
+		t.Errorf("version changed with backend: got %v, want %v", v, server.Version())
+	}
+
+	st, qu, err := client.Query(3, 5)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	ans, err := server.Answer(qu)
+	if err != nil {
+		t.Fatalf("Answer() error = %v", err)
+	}
+	if got, err := client.Recover(st, ans); err != nil || got != db.Get(3, 5).Uint64() {
+		t.Errorf("Recover() = %v, %v, want %v", got, err, db.Get(3, 5))
+	}
+
+	// preparing a different database gives a different hint, so the old client's version is stale
+	prep2, err := Prepare(params, db.transpose())
+	if err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+	if err := prep2.CheckVersion(client.Version()); !errors.Is(err, ErrStaleHint) {
+		t.Errorf("CheckVersion() of stale version error = %v, want %v", err, ErrStaleHint)
+	}
+}
+
+func TestPrepareErrors(t *testing.T) {
+	params := Params{N: 16, Q: big.NewInt(1 << 16), P: big.NewInt(2), SqrtN: 8}
+	tests := []struct {
+		name   string
+		params Params
+		db     *Mat
+	}{
+		{"invalid params", Params{N: 0, Q: params.Q, P: params.P, SqrtN: 8}, NewMat(8, 8)},
+		{"wrong shape", params, NewMat(8, 4)},
+		{"nil database", params, nil},
+		{"entry outside Z_p", params, NewMat(8, 8).FillRandom(big.NewInt(3)).Set(0, 0, big.NewInt(2))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Prepare(tt.params, tt.db); err == nil {
+				t.Errorf("Prepare() succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestParseHintVersion(t *testing.T) {
+	var v HintVersion
+	for i := range v {
+		v[i] = byte(i)
+	}
+	got, err := ParseHintVersion(v.String())
+	if err != nil || got != v {
+		t.Errorf("ParseHintVersion(%v) = %v, %v, want %v", v, got, err, v)
+	}
+	for _, s := range []string{"", "zz", v.String()[2:], v.String() + "00"} {
+		if _, err := ParseHintVersion(s); err == nil {
+			t.Errorf("ParseHintVersion(%q) succeeded, want error", s)
+		}
+	}
+}
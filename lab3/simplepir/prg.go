@@ -0,0 +1,93 @@
+package simplepir
+
+import (
+	"crypto/rand"
+	"crypto/sha3"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// SeedSize is the size in bytes of the seed the public matrix A is expanded from
+const SeedSize = 32
+
+// customisation string for cSHAKE128, separating the expansion of A from any other use of the seed
+var aDomain = []byte("simplepir public matrix A")
+
+// customisation string for cSHAKE128, separating the streams returned by [NewPRG] from the expansion of A
+var prgDomain = []byte("simplepir prg")
+
+// NewSeed returns a fresh random seed for [ExpandA]
+func NewSeed() []byte {
+	return newSeedFrom(rand.Reader)
+}
+
+// newSeedFrom reads a seed for [ExpandA] from r
+func newSeedFrom(r io.Reader) []byte {
+	seed := make([]byte, SeedSize)
+	if _, err := io.ReadFull(r, seed); err != nil {
+		panic(fmt.Sprintf("failed to generate seed, err: %v", err))
+	}
+	return seed
+}
+
+// NewPRG returns a deterministic stream of pseudorandom bytes expanded from seed, for use as Params.Rand
+//
+// Setting Params.Rand = NewPRG(seed) makes every query, error and server seed reproducible from seed alone,
+// so a failing run can be replayed. The stream is not safe for concurrent use.
+func NewPRG(seed []byte) io.Reader {
+	return newPRG(seed, prgDomain)
+}
+
+// newPRG returns a deterministic stream of pseudorandom bytes expanded from seed with cSHAKE128
+//
+// domain separates the streams for different uses of the same seed
+func newPRG(seed, domain []byte) io.Reader {
+	h := sha3.NewCSHAKE128(nil, domain)
+	h.Write(seed)
+	return h
+}
+
+// ExpandA deterministically expands seed into the m × N public matrix A over Z_q, for m the columns of the database
+//
+// The server only needs to publish the seed, as clients can regenerate A locally
+func ExpandA(seed []byte, params Params) (*Mat, error) {
+	_, cols := params.Shape()
+	return expandMat(seed, aDomain, cols, params)
+}
+
+// expandMat expands seed into a rows × N matrix over Z_q, using the cSHAKE128 customisation string domain
+func expandMat(seed, domain []byte, rows int, params Params) (*Mat, error) {
+	if len(seed) != SeedSize {
+		return nil, fmt.Errorf("seed must be %v bytes, got %v", SeedSize, len(seed))
+	}
+	if err := params.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %v", err)
+	}
+	return NewMatWith(rows, params.N, params.Backend).FillRandomFrom(newPRG(seed, domain), params.Q), nil
+}
+
+// uniformFrom returns a uniformly random value in [0, max) read from r
+//
+// uses rejection sampling on the smallest number of bits covering max-1, so that the output only depends
+// on the bytes read from r (unlike [rand.Int], whose use of the reader is an implementation detail)
+func uniformFrom(r io.Reader, max *big.Int) *big.Int {
+	if max.Sign() <= 0 {
+		panic(fmt.Sprintf("max must be positive, got %v", max))
+	}
+	bits := new(big.Int).Sub(max, big.NewInt(1)).BitLen()
+	buf := make([]byte, (bits+7)/8)
+	n := new(big.Int)
+	for {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			panic(err)
+		}
+		// clear the excess high bits, so at most half of the candidates are rejected
+		if len(buf) > 0 {
+			buf[0] &= byte(1<<(bits-8*(len(buf)-1)) - 1)
+		}
+		if n.SetBytes(buf).Cmp(max) < 0 {
+			return n
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package simplepir
+
+import (
+	"bytes"
+	"io"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func TestNewSeed(t *testing.T) {
+	s1, s2 := NewSeed(), NewSeed()
+	if len(s1) != SeedSize {
+		t.Errorf("len(NewSeed()) = %v, want %v", len(s1), SeedSize)
+	}
+	if bytes.Equal(s1, s2) {
+		t.Error("two calls to NewSeed() returned the same seed")
+	}
+}
+
+func TestExpandA(t *testing.T) {
+	params := Params{N: 16, Q: big.NewInt(1 << 32), P: big.NewInt(2), SqrtN: 8}
+	seed := NewSeed()
+
+	t.Run("deterministic", func(t *testing.T) {
+		A1, err := ExpandA(seed, params)
+		if err != nil {
+			t.Fatalf("ExpandA() error = %v", err)
+		}
+		A2, _ := ExpandA(seed, params)
+		if !reflect.DeepEqual(A1, A2) {
+			t.Error("ExpandA() is not deterministic for a fixed seed")
+		}
+		if A1.rows != params.SqrtN || A1.cols != params.N {
+			t.Errorf("A has dimensions (%d,%d), want (%d,%d)", A1.rows, A1.cols, params.SqrtN, params.N)
+		}
+	})
+
+	t.Run("different seeds", func(t *testing.T) {
+		A1, _ := ExpandA(seed, params)
+		A2, _ := ExpandA(NewSeed(), params)
+		if reflect.DeepEqual(A1, A2) {
+			t.Error("ExpandA() gave the same matrix for different seeds")
+		}
+	})
+
+	t.Run("same matrix for both backends", func(t *testing.T) {
+		for _, q := range []int64{1 << 32, 97, 1<<31 - 1} {
+			params := params
+			params.Q = big.NewInt(q)
+			ABig, _ := ExpandA(seed, params)
+			params.Backend = Uint32Backend
+			AU, _ := ExpandA(seed, params)
+			if !reflect.DeepEqual(ABig, AU.as(BigBackend)) {
+				t.Errorf("q=%d: backends expand the seed to different matrices", q)
+			}
+		}
+	})
+
+	t.Run("entries in range", func(t *testing.T) {
+		params := params
+		params.Q = big.NewInt(97)
+		A, _ := ExpandA(seed, params)
+		for i := range A.rows {
+			for j := range A.cols {
+				if x := A.Get(i, j); x.Sign() < 0 || x.Cmp(params.Q) >= 0 {
+					t.Errorf("A[%d][%d] = %v, not in Z_q", i, j, x)
+				}
+			}
+		}
+	})
+
+	t.Run("invalid inputs", func(t *testing.T) {
+		if _, err := ExpandA(seed[:16], params); err == nil {
+			t.Error("expected error for short seed")
+		}
+		bad := params
+		bad.N = 0
+		if _, err := ExpandA(seed, bad); err == nil {
+			t.Error("expected error for invalid params")
+		}
+	})
+}
+
+func TestUniformFrom(t *testing.T) {
+	const samples = 10_000
+	max := big.NewInt(10)
+	counts := make([]int, 10)
+	prg := newPRG(NewSeed(), aDomain)
+	for range samples {
+		x := uniformFrom(prg, max)
+		if x.Sign() < 0 || x.Cmp(max) >= 0 {
+			t.Fatalf("uniformFrom() = %v, want value in [0,%v)", x, max)
+		}
+		counts[x.Int64()]++
+	}
+	// each value should appear roughly samples/10 times
+	for v, c := range counts {
+		if c < samples/10-300 || c > samples/10+300 {
+			t.Errorf("value %d appeared %d times, want roughly %d", v, c, samples/10)
+		}
+	}
+
+	// max of 1 only has a single valid value
+	if x := uniformFrom(prg, big.NewInt(1)); x.Sign() != 0 {
+		t.Errorf("uniformFrom(1) = %v, want 0", x)
+	}
+	assertPanic(t, func() { uniformFrom(prg, big.NewInt(0)) }, "expected panic for zero max")
+}
+
+func TestNewPRG(t *testing.T) {
+	seed := NewSeed()
+	read := func(r io.Reader) []byte {
+		buf := make([]byte, 64)
+		io.ReadFull(r, buf)
+		return buf
+	}
+	if !bytes.Equal(read(NewPRG(seed)), read(NewPRG(seed))) {
+		t.Error("NewPRG() is not deterministic for a fixed seed")
+	}
+	if bytes.Equal(read(NewPRG(seed)), read(NewPRG(NewSeed()))) {
+		t.Error("NewPRG() gave the same stream for different seeds")
+	}
+	// domain separated from the expansion of A
+	if bytes.Equal(read(NewPRG(seed)), read(newPRG(seed, aDomain))) {
+		t.Error("NewPRG() gave the same stream as the expansion of A")
+	}
+}
@@ -0,0 +1,451 @@
+package simplepir
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+)
+
+// customisation string for cSHAKE128, separating the expansion of the ring elements a from the matrix A
+var ringDomain = []byte("simplepir public ring elements a")
+
+// candidate ring degrees tried by [ChooseRingParams], smallest first
+var candidateRingDegrees = []int{256, 512, 1024, 2048, 4096}
+
+// The ring mode replaces the LWE matrix A with ring elements in R_q = Z_q[X]/(X^d+1), with d = params.N.
+//
+// Each row of the ℓ × m database is cut into k = ⌈m/d⌉ blocks of d entries, block j holding the coefficients of the
+// ring element D_ij (the last block padded with zeros), so the database becomes an ℓ × k matrix over R_p. A query
+// for column col = j'·d + c encrypts the one-hot vector u_j' under RLWE, qu_j = a_j·s + e_j + Δ·[j = j'], and the
+// answer is D·qu, so row i of the answer is H_i·s + noise + Δ·D_ij' for the hint H = D·a. Coefficient c of
+// D_ij' is the entry db[i][col], and as in [pirRecoverColumn] the other coefficients come for free.
+//
+// Products in R_q are pointwise in the NTT domain (see [ring]), so the server keeps the database there and the
+// answer costs ℓ·m multiplications, as for LWE, plus k transforms. The answer is left in the NTT domain, so the
+// client transforms back only the row it needs. The hint is ℓ × d where LWE's is ℓ × n, but it is about n times
+// cheaper to compute, and the answer holds ℓ·d entries instead of ℓ.
+
+// ringColumns returns the number k of ring elements in each row of the database
+func ringColumns(params Params) int {
+	_, cols := params.Shape()
+	return (cols + params.N - 1) / params.N
+}
+
+// newRingFor checks that params describe a ring instance, see [RingServer], and builds the ring tables
+func newRingFor(params Params) (*ring, error) {
+	if err := params.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %v", err)
+	}
+	if params.Backend != Uint32Backend {
+		return nil, fmt.Errorf("ring mode requires the uint32 backend, got %v", params.Backend)
+	}
+	r, err := newRing(params.N, params.Q)
+	if err != nil {
+		return nil, fmt.Errorf("invalid parameters: %v", err)
+	}
+	return r, nil
+}
+
+// expandRing expands seed into the k public ring elements a_j in the NTT domain, concatenated
+func expandRing(seed []byte, r *ring, params Params) ([]uint32, error) {
+	a, err := expandMat(seed, ringDomain, ringColumns(params), params)
+	if err != nil {
+		return nil, err
+	}
+	// the a_j are expanded as coefficients, so that they do not depend on the ordering of the transform
+	for j := range a.rows {
+		r.ntt(a.packed[j*r.d : (j+1)*r.d])
+	}
+	return a.packed, nil
+}
+
+// ringPirSetup transforms the database into the NTT domain and computes the hint H = D·a
+//
+// Returns the ℓ·k ring elements D_ij in the NTT domain, concatenated row by row, and H as an ℓ × d matrix
+// whose row i holds the coefficients of H_i. Costs ℓ·k + ℓ transforms and ℓ·m multiplications, where the LWE
+// hint costs ℓ·m·n.
+func ringPirSetup(db *Mat, a []uint32, r *ring, params Params) ([]uint32, *Mat) {
+	k, d := ringColumns(params), r.d
+	dbHat := make([]uint32, db.rows*k*d)
+	hint := NewMatWith(db.rows, d, Uint32Backend)
+	parallelRows(db.rows, params.Workers, func(lo, hi int) {
+		for i := lo; i < hi; i++ {
+			row := db.packed[i*db.cols : (i+1)*db.cols]
+			for j := range k {
+				poly := dbHat[(i*k+j)*d : (i*k+j+1)*d]
+				copy(poly, row[j*d:min((j+1)*d, db.cols)])
+				r.ntt(poly)
+			}
+			h := hint.packed[i*d : (i+1)*d]
+			r.mulSum(h, dbHat[i*k*d:(i+1)*k*d], a)
+			r.intt(h)
+		}
+	})
+	return dbHat, hint
+}
+
+// RingQueryState is the client-side state kept between issuing a ring query and recovering the answer
+//
+// Fields are hidden as they include the secret s, which must never leave the client
+type RingQueryState struct {
+	row int
+	col int
+	s   []uint32 // RLWE secret, in the coefficient domain
+}
+
+// ringPirQuery generates the query for the database entry at (i, col), given the public ring elements a in the
+// NTT domain
+//
+// s is uniform in R_q, as the secret is in [pirQuery], and the error coefficients are drawn from params' sampler.
+// The query holds k·d entries, the k ring elements qu_j one after the other.
+func ringPirQuery(i, col int, a []uint32, r *ring, params Params) (RingQueryState, *Vec) {
+	k, d := ringColumns(params), r.d
+	s := make([]uint32, d)
+	randUint32s(params.random(), s, params.Q)
+	sHat := slices.Clone(s)
+	r.ntt(sHat)
+
+	qu := NewVecWith(k*d, Uint32Backend)
+	for j := range k {
+		poly := qu.packed[j*d : (j+1)*d]
+		aj := a[j*d : (j+1)*d]
+		for c := range poly {
+			poly[c] = uint32(r.mul(uint64(aj[c]), uint64(sHat[c])))
+		}
+		r.intt(poly)
+	}
+	// reduce the error mod q up front, as for [pirQuery]
+	sampler := params.sampler()
+	for c := range qu.packed {
+		e := int64(sampleFrom(sampler, params.Rand)) % int64(r.q)
+		if e < 0 {
+			e += int64(r.q)
+		}
+		qu.packed[c] = r.add(qu.packed[c], uint32(e))
+	}
+	j := col / d
+	qu.packed[j*d] = r.add(qu.packed[j*d], uint32(params.delta().Uint64()))
+	return RingQueryState{i, col, s}, qu
+}
+
+// ringPirAnswer computes the answer D·qu to a query, with the database dbHat in the NTT domain
+//
+// transforms each qu_j and takes the pointwise products, splitting the rows between params.Workers goroutines.
+// The answer holds ℓ·d entries, the NTT of each row's ring element one after another.
+func ringPirAnswer(dbHat []uint32, qu *Vec, r *ring, params Params) (*Vec, error) {
+	k, d := ringColumns(params), r.d
+	if err := qu.checkOperand(); err != nil {
+		return nil, err
+	}
+	if qu.size != k*d {
+		return nil, fmt.Errorf("%w: query must be a vector of size %v, got %v", ErrDimensionMismatch, k*d, qu.size)
+	}
+	quHat := make([]uint32, k*d)
+	for c := range quHat {
+		if qu.backend == Uint32Backend {
+			quHat[c] = uint32(r.reduce(uint64(qu.packed[c])))
+		} else {
+			quHat[c] = uint32(new(big.Int).Mod(qu.data[c], params.Q).Uint64())
+		}
+	}
+	parallelRows(k, params.Workers, func(lo, hi int) {
+		for j := lo; j < hi; j++ {
+			r.ntt(quHat[j*d : (j+1)*d])
+		}
+	})
+
+	rows := len(dbHat) / (k * d)
+	ans := NewVecWith(rows*d, Uint32Backend)
+	parallelRows(rows, params.Workers, func(lo, hi int) {
+		for i := lo; i < hi; i++ {
+			r.mulSum(ans.packed[i*d:(i+1)*d], dbHat[i*k*d:(i+1)*k*d], quHat)
+		}
+	})
+	return ans, nil
+}
+
+// ringPirRecover extracts the database value from the answer
+//
+// transforms row st.row of ans back to the coefficient domain, where coefficient c of ans_row - H_row·s is
+// Δ·db[row][col] + noise for col = j'·d + c. Only that coefficient of H_row·s is needed, which [ring.coeff] computes
+// with d multiplications. Also returns the margin, as for [pirRecover].
+func ringPirRecover(ans *Vec, st RingQueryState, hint *Mat, r *ring, params Params) (uint64, *big.Int) {
+	row := make([]uint32, r.d)
+	if ans.backend == Uint32Backend {
+		for c, x := range ans.packed[st.row*r.d : (st.row+1)*r.d] {
+			row[c] = uint32(r.reduce(uint64(x)))
+		}
+	} else {
+		for c := range row {
+			row[c] = uint32(new(big.Int).Mod(ans.data[st.row*r.d+c], params.Q).Uint64())
+		}
+	}
+	r.intt(row)
+	c := st.col % r.d
+	hs := r.coeff(hint.packed[st.row*r.d:(st.row+1)*r.d], st.s, c)
+	return params.decode(big.NewInt(int64(r.sub(row[c], hs))))
+}
+
+// ChooseRingLayout is [ChooseLayout] for a [RingServer] with ring degree d, minimising [Layout.RingCommunication]
+//
+// Ring answers hold d entries per row, so the best shapes are far wider than for LWE, with about √(N/d) rows for N cells
+func ChooseRingLayout(numRecords, recordSize int, p *big.Int, d, fetches int) (Layout, error) {
+	base, err := newLayout(numRecords, recordSize, p)
+	if err != nil {
+		return Layout{}, err
+	}
+	if d <= 0 || fetches <= 0 {
+		return Layout{}, fmt.Errorf("d and fetches must be positive, got %v and %v", d, fetches)
+	}
+	return base.minimise(func(l Layout) int64 { return l.RingCommunication(d, fetches) }), nil
+}
+
+// RingCommunication is [Layout.Communication] for a [RingServer] with ring degree d
+//
+// The hint is ℓ × d, and each query sends k·d entries for k = ⌈m/d⌉ and receives ℓ·d
+func (l Layout) RingCommunication(d, fetches int) int64 {
+	rows, cols := l.Shape()
+	k := (cols + d - 1) / d
+	return int64(rows)*int64(d) + int64(fetches)*int64(l.colsPerRecord())*int64(k*d+rows*d)
+}
+
+// RingServer holds the database and hint for the ring mode, a drop-in alternative to [Server] built on RLWE
+// over Z_q[X]/(X^d+1) rather than plain LWE, with d = params.N
+//
+// params.N must be a power of two, params.Q a prime with q ≡ 1 mod 2d such as [RingModulus], and params.Backend
+// [Uint32Backend]. [ChooseRingParams] picks them, and [Params.RingCosts] compares the mode with [Server].
+// Both implement [PIRServer], so callers can swap one for the other, but the ring mode has no updates or commitments.
+//
+// hidden fields, construct with [NewRingServer]
+type RingServer struct {
+	params  Params
+	ring    *ring
+	seed    []byte
+	db      []uint32 // the ring elements of the database in the NTT domain, see [ringPirSetup]
+	hint    *Mat
+	version HintVersion
+}
+
+// Creates a new [*RingServer] for db under the given parameters.
+//
+// Expands the public ring elements from a fresh seed and computes the hint with [ringPirSetup]. The database is
+// copied into the NTT domain, so a matrix mapped with [OpenMatFile] is read into memory.
+//
+// Usage:
+//
+//	server, err := NewRingServer(params, db)
+//	client, err := NewRingClient(params, server.Seed(), server.Hint())
+//	st, qu, err := client.Query(row, col) // send qu to the server
+//	ans, err := server.Answer(qu)         // send ans back to the client
+//	value, err := client.Recover(st, ans)
+func NewRingServer(params Params, db *Mat) (*RingServer, error) {
+	if err := checkDatabase(params, db); err != nil {
+		return nil, err
+	}
+	r, err := newRingFor(params)
+	if err != nil {
+		return nil, err
+	}
+	seed := newSeedFrom(params.random())
+	a, err := expandRing(seed, r, params)
+	if err != nil {
+		return nil, fmt.Errorf("could not expand a: %v", err)
+	}
+	dbHat, hint := ringPirSetup(db.as(Uint32Backend), a, r, params)
+	return &RingServer{params: params, ring: r, seed: seed, db: dbHat, hint: hint, version: hintVersion(hint, params)}, nil
+}
+
+// Mode returns [RingMode]
+func (s *RingServer) Mode() Mode {
+	return RingMode
+}
+
+// Params returns the parameters the server was set up with
+func (s *RingServer) Params() Params {
+	return s.params
+}
+
+// Seed returns the seed the public ring elements were expanded from
+func (s *RingServer) Seed() []byte {
+	return slices.Clone(s.seed) // defensive clone
+}
+
+// Hint returns H = D·a as an ℓ × d matrix, which clients need to recover answers
+//
+// The returned matrix is shared with the server and must not be modified. The server never modifies it either,
+// as the ring mode has no updates.
+func (s *RingServer) Hint() *Mat {
+	return s.hint
+}
+
+// Version returns the version of the hint, which never changes as the ring mode has no updates
+func (s *RingServer) Version() HintVersion {
+	return s.version
+}
+
+// CheckVersion returns an error wrapping [ErrStaleHint] if a client's hint version v is not the server's
+func (s *RingServer) CheckVersion(v HintVersion) error {
+	if v != s.version {
+		return fmt.Errorf("%w: client has version %v, server has %v", ErrStaleHint, v, s.version)
+	}
+	return nil
+}
+
+// Answer responds to a query vector qu produced by [RingClient.Query] or [RingClient.QueryRecord]
+//
+// The answer holds ℓ·d entries, d times as many as a [Server] answer for the same database
+func (s *RingServer) Answer(qu *Vec) (*Vec, error) {
+	return ringPirAnswer(s.db, qu, s.ring, s.params)
+}
+
+// RingClient builds ring queries and recovers answers, using the hint from a [RingServer]
+//
+// hidden fields, construct with [NewRingClient]
+type RingClient struct {
+	params  Params
+	ring    *ring
+	a       []uint32 // public ring elements in the NTT domain
+	hint    *Mat
+	version HintVersion
+}
+
+// Creates a new [*RingClient] from the seed of the public ring elements and the hint downloaded from the server
+func NewRingClient(params Params, seed []byte, hint *Mat) (*RingClient, error) {
+	r, err := newRingFor(params)
+	if err != nil {
+		return nil, err
+	}
+	a, err := expandRing(seed, r, params)
+	if err != nil {
+		return nil, fmt.Errorf("could not expand a: %v", err)
+	}
+	if rows, _ := params.Shape(); hint == nil || hint.rows != rows || hint.cols != params.N {
+		return nil, fmt.Errorf("hint must be a %vx%v matrix", rows, params.N)
+	}
+	if err := hint.checkOperand(); err != nil {
+		return nil, fmt.Errorf("invalid hint: %v", err)
+	}
+	hint = hint.as(Uint32Backend)
+	return &RingClient{params: params, ring: r, a: a, hint: hint, version: hintVersion(hint, params)}, nil
+}
+
+// Params returns the parameters the client was set up with
+func (c *RingClient) Params() Params {
+	return c.params
+}
+
+// Version returns the version of the client's hint, which the server can check with [RingServer.CheckVersion]
+func (c *RingClient) Version() HintVersion {
+	return c.version
+}
+
+// Query builds a query for the database entry at (row, col)
+//
+// Returns the state needed by [RingClient.Recover], which must be kept secret, and the query vector to send to the server
+func (c *RingClient) Query(row, col int) (*RingQueryState, *Vec, error) {
+	if err := checkIndex(row, col, c.params); err != nil {
+		return nil, nil, err
+	}
+	st, qu := ringPirQuery(row, col, c.a, c.ring, c.params)
+	return &st, qu, nil
+}
+
+// Recover extracts the database entry from the server's answer, using the state st returned by [RingClient.Query]
+//
+// The returned value lies in Z_p
+func (c *RingClient) Recover(st *RingQueryState, ans *Vec) (uint64, error) {
+	val, _, err := c.RecoverWithMargin(st, ans)
+	return val, err
+}
+
+// RecoverWithMargin is [RingClient.Recover], but also returns the noise margin as in [Client.RecoverWithMargin]
+func (c *RingClient) RecoverWithMargin(st *RingQueryState, ans *Vec) (uint64, *big.Int, error) {
+	if st == nil || len(st.s) != c.params.N {
+		return 0, nil, fmt.Errorf("query state does not match client parameters")
+	}
+	if rows, _ := c.params.Shape(); ans.checkOperand() != nil || ans.size != rows*c.params.N {
+		return 0, nil, fmt.Errorf("answer must be a vector of size %v", rows*c.params.N)
+	}
+	val, margin := ringPirRecover(ans, *st, c.hint, c.ring, c.params)
+	if err := c.params.checkMargin(margin); err != nil {
+		return 0, nil, err
+	}
+	return val, margin, nil
+}
+
+// QueryRecord builds the queries for record i of a [Database] with the given layout, as [Client.QueryRecord] does
+//
+// Send each query to the server, and pass the answers, in the same order, to [RingClient.RecoverRecord]
+func (c *RingClient) QueryRecord(layout Layout, i int) (*RecordState, []*Vec, error) {
+	st, err := newRecordState(layout, i, c.params)
+	if err != nil {
+		return nil, nil, err
+	}
+	st.ringStates = make([]RingQueryState, len(st.cols))
+	queries := make([]*Vec, len(st.cols))
+	for k, col := range st.cols {
+		st.ringStates[k], queries[k] = ringPirQuery(0, col, c.a, c.ring, c.params)
+	}
+	return st, queries, nil
+}
+
+// RecoverRecord rebuilds a record from the server's answers to the queries returned by [RingClient.QueryRecord]
+//
+// Each of the record's cells costs a transform of its row of the answer, see [ringPirRecover]. Returns an error
+// wrapping [ErrLowMargin] if any cell has a margin below Params.SafetyBand.
+func (c *RingClient) RecoverRecord(st *RecordState, answers []*Vec) ([]byte, error) {
+	if st == nil || len(st.ringStates) != len(st.cols) {
+		return nil, fmt.Errorf("record state was not made by this client's QueryRecord")
+	}
+	if len(answers) != len(st.cols) {
+		return nil, fmt.Errorf("expected %v answers, got %v", len(st.cols), len(answers))
+	}
+	rows, _ := c.params.Shape()
+	for k, ans := range answers {
+		if ans.checkOperand() != nil || ans.size != rows*c.params.N {
+			return nil, fmt.Errorf("answer %v must be a vector of size %v", k, rows*c.params.N)
+		}
+	}
+
+	values := make([]uint64, len(st.cells))
+	for k, cell := range st.cells {
+		q := slices.Index(st.cols, cell.Col)
+		qst := st.ringStates[q]
+		qst.row, qst.col = cell.Row, cell.Col
+		var margin *big.Int
+		values[k], margin = ringPirRecover(answers[q], qst, c.hint, c.ring, c.params)
+		if err := c.params.checkMargin(margin); err != nil {
+			return nil, fmt.Errorf("cell %v: %w", cell, err)
+		}
+	}
+	return st.layout.decode(values), nil
+}
+
+// ChooseRingParams picks the ring degree and error distribution for a [RingServer] over a database of the shape
+// and plaintext modulus of base, e.g. parameters from [ChooseParamsForLayout] to compare the two modes
+//
+// q is [RingModulus] and σ the SimplePIR paper's 6.4 as for [ChooseParams], and d is the smallest power of two for
+// which [Params.SecurityBits] reaches securityBits, treating RLWE of degree d as LWE of dimension d as is usual.
+// Returns an error if recovery would fail with probability above [MaxFailureProbability], in which case a smaller
+// p is needed.
+func ChooseRingParams(base Params, securityBits float64) (Params, error) {
+	params := base
+	params.N = candidateRingDegrees[0]
+	params.Q = big.NewInt(RingModulus)
+	params.Sampler = NewGaussSampler(t, sigma, tau)
+	params.Backend = Uint32Backend
+	if err := params.Validate(); err != nil {
+		return Params{}, fmt.Errorf("invalid parameters: %v", err)
+	}
+	if fail, err := params.FailureProbability(); err != nil || fail > MaxFailureProbability {
+		rows, cols := params.Shape()
+		return Params{}, fmt.Errorf("q = %v is too small for a %vx%v database with p = %v", params.Q, rows, cols, params.P)
+	}
+	for _, d := range candidateRingDegrees {
+		params.N = d
+		if bits, _ := params.SecurityBits(); bits >= securityBits {
+			return params, nil
+		}
+	}
+	return Params{}, fmt.Errorf("no ring degree up to %v reaches %v bits of security", candidateRingDegrees[len(candidateRingDegrees)-1], securityBits)
+}
@@ -0,0 +1,314 @@
+package simplepir
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+// ringTestParams returns ring parameters for an rows × cols database, with a ring degree far too small to be secure
+// so that the tests run quickly
+func ringTestParams(d, rows, cols int, p int64) Params {
+	return Params{N: d, Q: big.NewInt(RingModulus), P: big.NewInt(p), Rows: rows, Cols: cols, Backend: Uint32Backend}
+}
+
+func TestRingProtocol(t *testing.T) {
+	testCases := []struct {
+		name       string
+		d          int
+		rows, cols int
+		p          int64
+	}{
+		{"one ring element per row", 16, 8, 16, 991},
+		{"several ring elements per row", 16, 5, 64, 256},
+		{"padded last element", 16, 6, 40, 2},
+		{"row shorter than the degree", 32, 12, 7, 1 << 10},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			params := ringTestParams(tc.d, tc.rows, tc.cols, tc.p)
+			db := NewMatWith(tc.rows, tc.cols, Uint32Backend).FillRandom(params.P)
+			server, err := NewRingServer(params, db)
+			if err != nil {
+				t.Fatalf("NewRingServer() error = %v", err)
+			}
+			if hint := server.Hint(); hint.Rows() != tc.rows || hint.Cols() != tc.d {
+				t.Errorf("Hint() is %vx%v, want %vx%v", hint.Rows(), hint.Cols(), tc.rows, tc.d)
+			}
+			client, err := NewRingClient(params, server.Seed(), server.Hint())
+			if err != nil {
+				t.Fatalf("NewRingClient() error = %v", err)
+			}
+			for i := range tc.rows {
+				for j := range tc.cols {
+					st, qu, err := client.Query(i, j)
+					if err != nil {
+						t.Fatalf("Query() error = %v", err)
+					}
+					if want := ringColumns(params) * tc.d; qu.Size() != want {
+						t.Fatalf("Query() has size %v, want %v", qu.Size(), want)
+					}
+					ans, err := server.Answer(qu)
+					if err != nil {
+						t.Fatalf("Answer() error = %v", err)
+					}
+					if got, err := client.Recover(st, ans); err != nil || got != db.Get(i, j).Uint64() {
+						t.Errorf("Recover() at (%v, %v) = %v, %v, want %v", i, j, got, err, db.Get(i, j))
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestRingSetup checks the hint against the negacyclic products computed the schoolbook way
+func TestRingSetup(t *testing.T) {
+	params := ringTestParams(8, 3, 20, 991)
+	db := NewMatWith(3, 20, Uint32Backend).FillRandom(params.P)
+	r, _ := newRing(8, params.Q)
+	seed := NewSeed()
+	a, _ := expandMat(seed, ringDomain, ringColumns(params), params)
+	aHat, err := expandRing(seed, r, params)
+	if err != nil {
+		t.Fatalf("expandRing() error = %v", err)
+	}
+	_, hint := ringPirSetup(db, aHat, r, params)
+	for i := range 3 {
+		want := make([]uint32, 8)
+		for j := range ringColumns(params) {
+			poly := make([]uint32, 8)
+			for c := range poly {
+				if col := j*8 + c; col < 20 {
+					poly[c] = db.packed[i*20+col]
+				}
+			}
+			prod := negacyclicMul(poly, a.packed[j*8:(j+1)*8], r.q)
+			for c := range want {
+				want[c] = r.add(want[c], prod[c])
+			}
+		}
+		for c := range want {
+			if got := hint.packed[i*8+c]; got != want[c] {
+				t.Errorf("hint row %v coefficient %v = %v, want %v", i, c, got, want[c])
+			}
+		}
+	}
+}
+
+func TestRingErrors(t *testing.T) {
+	params := ringTestParams(16, 4, 32, 991)
+	db := NewMatWith(4, 32, Uint32Backend).FillRandom(params.P)
+	server, err := NewRingServer(params, db)
+	if err != nil {
+		t.Fatalf("NewRingServer() error = %v", err)
+	}
+	client, err := NewRingClient(params, server.Seed(), server.Hint())
+	if err != nil {
+		t.Fatalf("NewRingClient() error = %v", err)
+	}
+
+	t.Run("parameters", func(t *testing.T) {
+		withN, withQ, withBackend := params, params, params
+		withN.N = 24
+		withQ.Q = big.NewInt(1 << 32)
+		withBackend.Backend = BigBackend
+		for _, bad := range []Params{withN, withQ, withBackend} {
+			if _, err := NewRingServer(bad, db); err == nil {
+				t.Errorf("NewRingServer() with N = %v, q = %v, backend %v succeeded, want error", bad.N, bad.Q, bad.Backend)
+			}
+			if _, err := NewRingClient(bad, server.Seed(), server.Hint()); err == nil {
+				t.Errorf("NewRingClient() with N = %v, q = %v, backend %v succeeded, want error", bad.N, bad.Q, bad.Backend)
+			}
+		}
+		if _, err := NewRingServer(params, NewMatWith(4, 31, Uint32Backend)); err == nil {
+			t.Errorf("NewRingServer() with wrong shape succeeded, want error")
+		}
+		if _, err := NewRingClient(params, server.Seed(), NewMatWith(4, 32, Uint32Backend)); err == nil {
+			t.Errorf("NewRingClient() with wrong hint shape succeeded, want error")
+		}
+		if _, err := NewRingClient(params, []byte("short"), server.Hint()); err == nil {
+			t.Errorf("NewRingClient() with short seed succeeded, want error")
+		}
+	})
+
+	t.Run("queries", func(t *testing.T) {
+		if _, _, err := client.Query(4, 0); err == nil {
+			t.Errorf("Query() out of range succeeded, want error")
+		}
+		for _, qu := range []*Vec{nil, NewVecWith(16, Uint32Backend), {size: 32}} {
+			if _, err := server.Answer(qu); err == nil {
+				t.Errorf("Answer() of malformed query succeeded, want error")
+			}
+		}
+		st, qu, _ := client.Query(1, 2)
+		if _, err := client.Recover(st, NewVecWith(4, Uint32Backend)); err == nil {
+			t.Errorf("Recover() of short answer succeeded, want error")
+		}
+		if _, err := client.Recover(&RingQueryState{}, NewVecWith(4*16, Uint32Backend)); err == nil {
+			t.Errorf("Recover() with empty state succeeded, want error")
+		}
+		// answers decoded with the big backend are recovered the same
+		ans, _ := server.Answer(qu.as(BigBackend))
+		if got, err := client.Recover(st, ans.as(BigBackend)); err != nil || got != db.Get(1, 2).Uint64() {
+			t.Errorf("Recover() of big backend answer = %v, %v, want %v", got, err, db.Get(1, 2))
+		}
+	})
+}
+
+func TestChooseRingParams(t *testing.T) {
+	layout, err := newLayout(1<<16, 32, big.NewInt(256))
+	if err != nil {
+		t.Fatalf("newLayout() error = %v", err)
+	}
+	base, err := ChooseParamsForLayout(layout, big.NewInt(256), 128)
+	if err != nil {
+		t.Fatalf("ChooseParamsForLayout() error = %v", err)
+	}
+	params, err := ChooseRingParams(base, 128)
+	if err != nil {
+		t.Fatalf("ChooseRingParams() error = %v", err)
+	}
+	if rows, cols := params.Shape(); params.P.Cmp(base.P) != 0 || rows != base.SqrtN || cols != base.SqrtN {
+		t.Errorf("ChooseRingParams() changed the database to %vx%v over Z_%v", rows, cols, params.P)
+	}
+	if _, err := newRingFor(params); err != nil {
+		t.Errorf("ChooseRingParams() gave unusable parameters: %v", err)
+	}
+	if bits, _ := params.SecurityBits(); bits < 128 {
+		t.Errorf("ChooseRingParams() gives %.1f bits of security, want at least 128", bits)
+	}
+	if smaller := (Params{N: params.N / 2, Q: params.Q, P: params.P, SqrtN: base.SqrtN}); params.N > candidateRingDegrees[0] {
+		if bits, _ := smaller.SecurityBits(); bits >= 128 {
+			t.Errorf("ChooseRingParams() picked d = %v, but %v already gives %.1f bits", params.N, smaller.N, bits)
+		}
+	}
+
+	if _, err := ChooseRingParams(Params{P: big.NewInt(1 << 20), SqrtN: 1 << 10}, 128); err == nil {
+		t.Errorf("ChooseRingParams() with p too large for q succeeded, want error")
+	}
+	if _, err := ChooseRingParams(base, 1000); err == nil {
+		t.Errorf("ChooseRingParams() with unreachable security succeeded, want error")
+	}
+}
+
+func TestChooseRingLayout(t *testing.T) {
+	p := big.NewInt(256)
+	l, err := ChooseRingLayout(1<<16, 4, p, 2048, 1)
+	if err != nil {
+		t.Fatalf("ChooseRingLayout() error = %v", err)
+	}
+	if err := l.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	rows, cols := l.Shape()
+	for _, r := range []int{rows - 1, rows + 1} {
+		if r > 0 && l.withRows(r).RingCommunication(2048, 1) < l.RingCommunication(2048, 1) {
+			t.Errorf("%v rows beats the chosen %vx%v", r, rows, cols)
+		}
+	}
+	// ring answers hold d entries per row, so the ring layout is wider than the LWE one for the same records
+	lwe, _ := ChooseLayout(1<<16, 4, p, 2048, 1)
+	if lweRows, _ := lwe.Shape(); rows >= lweRows {
+		t.Errorf("ChooseRingLayout() = %vx%v, want fewer rows than the %v of ChooseLayout()", rows, cols, lweRows)
+	}
+	if want := int64(rows*2048 + ((cols+2047)/2048*2048 + rows*2048)); l.RingCommunication(2048, 1) != want {
+		t.Errorf("RingCommunication() = %v, want %v", l.RingCommunication(2048, 1), want)
+	}
+
+	if _, err := ChooseRingLayout(1<<16, 4, p, 0, 1); err == nil {
+		t.Errorf("ChooseRingLayout() with d = 0 succeeded, want error")
+	}
+	if _, err := ChooseRingLayout(0, 4, p, 2048, 1); err == nil {
+		t.Errorf("ChooseRingLayout() with no records succeeded, want error")
+	}
+}
+
+func TestRingCosts(t *testing.T) {
+	lwe := Params{N: 1024, Q: big.NewInt(1 << 32), P: big.NewInt(256), Rows: 1024, Cols: 4096}
+	ring := ringTestParams(2048, 1024, 4096, 256)
+	lweCosts, err := lwe.Costs()
+	if err != nil {
+		t.Fatalf("Costs() error = %v", err)
+	}
+	ringCosts, err := ring.RingCosts()
+	if err != nil {
+		t.Fatalf("RingCosts() error = %v", err)
+	}
+	ntt := uint64(1024 * 11)
+	want := []struct {
+		name      string
+		got, want uint64
+	}{
+		{"LWE hint bytes", uint64(lweCosts.HintBytes), 1024 * 1024 * 4},
+		{"LWE query bytes", uint64(lweCosts.QueryBytes), 4096 * 4},
+		{"LWE answer bytes", uint64(lweCosts.AnswerBytes), 1024 * 4},
+		{"LWE setup", lweCosts.SetupMults, 1024 * 4096 * 1024},
+		{"LWE answer", lweCosts.AnswerMults, 1024 * 4096},
+		{"ring hint bytes", uint64(ringCosts.HintBytes), 1024 * 2048 * 4},
+		{"ring query bytes", uint64(ringCosts.QueryBytes), 4096 * 4},
+		{"ring answer bytes", uint64(ringCosts.AnswerBytes), 1024 * 2048 * 4},
+		{"ring setup", ringCosts.SetupMults, (1024*2+1024)*ntt + 1024*4096},
+		{"ring answer", ringCosts.AnswerMults, 2*ntt + 1024*4096},
+		{"ring client", ringCosts.ClientMults, 4*ntt + 4096 + 2048},
+	}
+	for _, tt := range want {
+		if tt.got != tt.want {
+			t.Errorf("%v = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+	if _, err := lwe.RingCosts(); err == nil {
+		t.Errorf("RingCosts() for LWE parameters succeeded, want error")
+	}
+}
+
+func TestElemBytes(t *testing.T) {
+	tests := []struct {
+		q    *big.Int
+		want int
+	}{
+		{big.NewInt(256), 1},
+		{big.NewInt(257), 2}, // 256 needs 9 bits
+		{big.NewInt(1000), 2},
+		{big.NewInt(1 << 16), 2},
+		{big.NewInt(1<<16 + 1), 3},
+		{big.NewInt(RingModulus), 4},
+		{big.NewInt(1 << 32), 4},
+	}
+	for _, tt := range tests {
+		if got := (Params{Q: tt.q}).elemBytes(); got != tt.want {
+			t.Errorf("elemBytes() for q = %v = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+}
+
+// BenchmarkRingAnswer compares answering a query with [RingServer] against [Server] for 2^22 entry databases,
+// square-ish and wide, as the ring answer's reductions are amortised over the k = ⌈m/d⌉ products of each coefficient
+func BenchmarkRingAnswer(b *testing.B) {
+	for _, shape := range []struct{ rows, cols int }{{1024, 4096}, {256, 16384}} {
+		db := NewMatWith(shape.rows, shape.cols, Uint32Backend).FillRandom(big.NewInt(256))
+		lwe := Params{N: 1024, Q: big.NewInt(1 << 32), P: big.NewInt(256), Rows: shape.rows, Cols: shape.cols, Backend: Uint32Backend, Workers: 1}
+		ring := lwe
+		ring.N, ring.Q = 2048, big.NewInt(RingModulus)
+
+		b.Run(fmt.Sprintf("%vx%v/lwe", shape.rows, shape.cols), func(b *testing.B) {
+			server, err := NewServer(lwe, db)
+			if err != nil {
+				b.Fatalf("NewServer() error = %v", err)
+			}
+			qu := NewVecWith(shape.cols, Uint32Backend).FillRandom(lwe.Q)
+			for b.Loop() {
+				server.Answer(qu)
+			}
+		})
+		b.Run(fmt.Sprintf("%vx%v/ring", shape.rows, shape.cols), func(b *testing.B) {
+			server, err := NewRingServer(ring, db)
+			if err != nil {
+				b.Fatalf("NewRingServer() error = %v", err)
+			}
+			qu := NewVecWith(ringColumns(ring)*ring.N, Uint32Backend).FillRandom(ring.Q)
+			for b.Loop() {
+				server.Answer(qu)
+			}
+		})
+	}
+}
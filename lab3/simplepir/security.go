@@ -0,0 +1,203 @@
+package simplepir
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// MaxFailureProbability is the largest per-entry probability of a wrong recovery [ChooseParams] accepts
+//
+// this matches the 2^-40 correctness target of the SimplePIR paper
+const MaxFailureProbability = 0x1p-40
+
+// candidate moduli and LWE dimensions tried by [ChooseParams], smallest (and so cheapest) first
+var (
+	candidateLogQ = []uint{32, 64}
+	candidateN    = func() []int {
+		var ns []int
+		for n := 256; n <= 4096; n += 64 {
+			ns = append(ns, n)
+		}
+		return ns
+	}()
+)
+
+// gauss returns the distribution of the error sampler as a [GaussSampler], which the estimates below need to know the width of
+func (p Params) gauss() (GaussSampler, error) {
+	g, ok := p.sampler().(GaussSampler)
+	if c, isCDT := p.sampler().(*CDTSampler); isCDT {
+		g, ok = c.gauss, true
+	}
+	if !ok {
+		return GaussSampler{}, fmt.Errorf("estimates need a GaussSampler, got %T", p.sampler())
+	}
+	if g.sigma <= 0 {
+		return GaussSampler{}, fmt.Errorf("Gaussian width must be positive, got %v", g.sigma)
+	}
+	return g, nil
+}
+
+// stdev returns the standard deviation of [GaussSampler.Sample], which weights x by exp(-π(x-t)²/σ²)
+func (g GaussSampler) stdev() float64 {
+	return g.sigma / math.Sqrt(2*math.Pi)
+}
+
+// FailureProbability bounds the probability that recovering a single database entry returns the wrong value
+//
+// Recovery computes Δ·db[row][col] + Σ_j db[row][j]·e_j and rounds to the nearest multiple of Δ, so it fails only
+// if the noise reaches Δ/2. The noise is a sum of m (the inner dimension, the columns of the database) errors, each
+// scaled by an entry of at most p-1, so it is subgaussian with parameter √m·(p-1)·s, for s the standard deviation of
+// the sampler, giving the tail bound 2·exp(-(Δ/2)²/(2·m·(p-1)²·s²)). The sampler also never returns values past its tailcut
+// |t| + τσ, so the probability is 0 whenever even the largest possible noise stays below Δ/2.
+func (p Params) FailureProbability() (float64, error) {
+	if err := p.Validate(); err != nil {
+		return 0, err
+	}
+	g, err := p.gauss()
+	if err != nil {
+		return 0, err
+	}
+
+	// values just below q wrap around to 0, which [Params.round] only handles when q mod p ≤ ⌈Δ/2⌉
+	delta := p.delta()
+	r := new(big.Int).Mod(p.Q, p.P)
+	if r.Cmp(new(big.Int).Rsh(new(big.Int).Add(delta, big.NewInt(1)), 1)) > 0 {
+		return 1, nil
+	}
+
+	bound, _ := new(big.Float).SetInt(delta).Float64()
+	bound /= 2
+	maxEntry, _ := new(big.Float).SetInt(new(big.Int).Sub(p.P, big.NewInt(1))).Float64()
+
+	_, cols := p.Shape()
+	maxError := math.Ceil(math.Abs(g.t) + g.tau*g.sigma)
+	if float64(cols)*maxEntry*maxError < bound {
+		return 0, nil
+	}
+	variance := float64(cols) * maxEntry * maxEntry * g.stdev() * g.stdev()
+	return min(1, 2*math.Exp(-bound*bound/(2*variance))), nil
+}
+
+// SecurityBits estimates the bit-security of the LWE instance the queries hide the client's index behind
+//
+// This is the primal (uSVP) attack estimate of Alkim et al. (ADPS16): BKZ with block size β recovers the secret
+// once s·√β ≤ δ_β^(2β-d-1)·q^(m/d), for d = n + m + 1 and any number of samples m. The cost of the smallest
+// such β is 0.292β + 16.4 + log2(8d), the BDGL16 sieving cost used by the lattice estimator for the SimplePIR
+// parameters. The attacker is given as many samples as helps them, so the estimate does not depend on the shape
+// of the database.
+// It is an estimate rather than a proof, and ignores e.g. the dual and hybrid attacks.
+func (p Params) SecurityBits() (float64, error) {
+	if err := p.Validate(); err != nil {
+		return 0, err
+	}
+	g, err := p.gauss()
+	if err != nil {
+		return 0, err
+	}
+
+	// log2 q = exp + log2 mant for q = mant·2^exp with mant in [0.5, 1), which works for q beyond float64 range
+	mant := new(big.Float)
+	exp := new(big.Float).SetInt(p.Q).MantExp(mant)
+	mf, _ := mant.Float64()
+	logQ := float64(exp) + math.Log2(mf)
+	logS := math.Log2(g.stdev())
+	n := p.N
+	step := max(1, n/128)
+	for beta := 40; beta <= 4*n; beta++ {
+		logDelta := math.Log2(rootHermite(float64(beta)))
+		for m := step; m <= 4*n; m += step {
+			d := float64(n + m + 1)
+			if logS+0.5*math.Log2(float64(beta)) <= (2*float64(beta)-d-1)*logDelta+float64(m)*logQ/d {
+				return 0.292*float64(beta) + 16.4 + math.Log2(8*d), nil
+			}
+		}
+	}
+	return math.Inf(1), nil
+}
+
+// rootHermite returns the root Hermite factor δ_β achieved by BKZ with block size beta
+func rootHermite(beta float64) float64 {
+	return math.Pow(math.Pow(math.Pi*beta, 1/beta)*beta/(2*math.Pi*math.E), 1/(2*(beta-1)))
+}
+
+// CheckSecurity returns an error if the parameters are estimated to give fewer than securityBits bits of security,
+// or if recovering an entry fails with probability above [MaxFailureProbability]
+func (p Params) CheckSecurity(securityBits float64) error {
+	bits, err := p.SecurityBits()
+	if err != nil {
+		return fmt.Errorf("could not estimate security: %v", err)
+	}
+	if bits < securityBits {
+		return fmt.Errorf("parameters give an estimated %.1f bits of security, below the target of %v", bits, securityBits)
+	}
+	fail, err := p.FailureProbability()
+	if err != nil {
+		return fmt.Errorf("could not bound failure probability: %v", err)
+	}
+	if fail > MaxFailureProbability {
+		return fmt.Errorf("recovery fails with probability up to 2^%.1f, above the target of 2^-40", math.Log2(fail))
+	}
+	return nil
+}
+
+// ChooseParams picks n, q and the error distribution for a database of numEntries entries in Z_p
+//
+// q is 2^32 when that is large enough for p and the database size, so the [Uint32Backend] can be used, and
+// 2^64 otherwise. σ is the SimplePIR paper's 6.4, and n is the smallest multiple of 64 for which
+// [Params.SecurityBits] reaches securityBits. The database is a SqrtN × SqrtN matrix with SqrtN = ⌈√numEntries⌉.
+//
+// Returns an error if no candidate reaches the security target with [Params.FailureProbability] at most
+// [MaxFailureProbability], in which case a smaller p is needed.
+func ChooseParams(numEntries int, p *big.Int, securityBits float64) (Params, error) {
+	if numEntries <= 0 {
+		return Params{}, fmt.Errorf("database must hold at least one entry, got %v", numEntries)
+	}
+	sqrtN := int(math.Ceil(math.Sqrt(float64(numEntries))))
+	for sqrtN*sqrtN < numEntries {
+		sqrtN++
+	}
+	return chooseParams(Params{P: p, SqrtN: sqrtN}, securityBits)
+}
+
+// ChooseParamsForLayout is [ChooseParams] for a database of the shape given by layout, which may be rectangular
+func ChooseParamsForLayout(layout Layout, p *big.Int, securityBits float64) (Params, error) {
+	if err := layout.Validate(); err != nil {
+		return Params{}, fmt.Errorf("invalid layout: %v", err)
+	}
+	base := Params{P: p, SqrtN: layout.SqrtN}
+	if layout.SqrtN == 0 {
+		base.Rows, base.Cols = layout.Shape()
+	}
+	return chooseParams(base, securityBits)
+}
+
+// chooseParams fills in n, q, the sampler and the backend of base, which sets p and the shape of the database
+func chooseParams(base Params, securityBits float64) (Params, error) {
+	p := base.P
+	for _, logQ := range candidateLogQ {
+		params := base
+		params.Q = new(big.Int).Lsh(big.NewInt(1), logQ)
+		params.Sampler = NewGaussSampler(t, sigma, tau)
+		params.Backend = BigBackend
+		if params.Q.Cmp(two32) <= 0 {
+			params.Backend = Uint32Backend
+		}
+
+		params.N = candidateN[0]
+		if err := params.Validate(); err != nil {
+			continue // p too large for this q
+		}
+		if fail, _ := params.FailureProbability(); fail > MaxFailureProbability {
+			continue // n does not affect correctness, so try a larger q
+		}
+		for _, n := range candidateN {
+			params.N = n
+			if bits, _ := params.SecurityBits(); bits >= securityBits {
+				return params, nil
+			}
+		}
+	}
+	rows, cols := base.Shape()
+	return Params{}, fmt.Errorf("no parameters reach %v bits of security for a %vx%v database with p = %v", securityBits, rows, cols, p)
+}
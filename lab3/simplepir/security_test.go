@@ -0,0 +1,278 @@
+package simplepir
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestFailureProbability(t *testing.T) {
+	tests := []struct {
+		name     string
+		params   Params
+		minFail  float64
+		maxFail  float64
+		wantErrs bool
+	}{
+		// Δ = 2^31, the noise cannot reach Δ/2 within the tailcut
+		{"tailcut", Params{N: 1024, Q: two32, P: big.NewInt(2), SqrtN: 1024}, 0, 0, false},
+		{"paper parameters", Params{N: 1024, Q: two32, P: big.NewInt(991), SqrtN: 1024}, 0, 0x1p-100, false},
+		{"large p", Params{N: 1024, Q: two32, P: big.NewInt(1 << 14), SqrtN: 1024}, 0x1p-40, 1, false},
+		// the toy moduli used in the unit tests leave no room for noise
+		{"toy modulus", Params{N: 16, Q: big.NewInt(97), P: big.NewInt(2), SqrtN: 16}, 0x1p-10, 1, false},
+		// q mod p > Δ/2
+		{"wrap around", Params{N: 4, Q: big.NewInt(30), P: big.NewInt(7), SqrtN: 4}, 1, 1, false},
+		{"custom sampler", Params{N: 16, Q: two32, P: big.NewInt(2), SqrtN: 16, Sampler: constSampler{}}, 0, 0, true},
+		{"invalid", Params{}, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fail, err := tt.params.FailureProbability()
+			if (err != nil) != tt.wantErrs {
+				t.Fatalf("FailureProbability() error = %v, wantErr %v", err, tt.wantErrs)
+			}
+			if fail < tt.minFail || fail > tt.maxFail {
+				t.Errorf("FailureProbability() = %v, want in [%v, %v]", fail, tt.minFail, tt.maxFail)
+			}
+		})
+	}
+
+	// more entries in the inner product means more noise
+	small := Params{N: 1024, Q: two32, P: big.NewInt(1 << 12), SqrtN: 1 << 10}
+	large := small
+	large.SqrtN = 1 << 14
+	fs, _ := small.FailureProbability()
+	fl, _ := large.FailureProbability()
+	if fs >= fl {
+		t.Errorf("FailureProbability() with SqrtN = %v is %v, not below %v with SqrtN = %v", small.SqrtN, fs, fl, large.SqrtN)
+	}
+}
+
+func TestSecurityBits(t *testing.T) {
+	base := Params{N: 1024, Q: two32, P: big.NewInt(991), SqrtN: 1024}
+	bits, err := base.SecurityBits()
+	if err != nil {
+		t.Fatalf("SecurityBits() error = %v", err)
+	}
+	// the paper reports roughly 128 bits for n = 1024 and q = 2^32, with a wider error than the default sampler
+	if bits < 100 || bits > 140 {
+		t.Errorf("SecurityBits() = %v, want roughly 110-130", bits)
+	}
+
+	// security grows with n and σ, and shrinks with q
+	tests := []struct {
+		name   string
+		modify func(p *Params)
+	}{
+		{"larger n", func(p *Params) { p.N = 2048 }},
+		{"wider error", func(p *Params) { p.Sampler = NewGaussSampler(0, 4*sigma, tau) }},
+		{"smaller q", func(p *Params) { p.Q = big.NewInt(1 << 24) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := base
+			tt.modify(&p)
+			got, err := p.SecurityBits()
+			if err != nil {
+				t.Fatalf("SecurityBits() error = %v", err)
+			}
+			if got <= bits {
+				t.Errorf("SecurityBits() = %v, want more than %v", got, bits)
+			}
+		})
+	}
+
+	// a larger q with the same n and error is easier to attack
+	wide := base
+	wide.Q = new(big.Int).Lsh(big.NewInt(1), 64)
+	if got, _ := wide.SecurityBits(); got >= bits {
+		t.Errorf("SecurityBits() with q = 2^64 is %v, want less than %v", got, bits)
+	}
+}
+
+func TestCheckSecurity(t *testing.T) {
+	params := Params{N: 1024, Q: two32, P: big.NewInt(991), SqrtN: 1024}
+	if err := params.CheckSecurity(100); err != nil {
+		t.Errorf("CheckSecurity(100) error = %v", err)
+	}
+	if err := params.CheckSecurity(128); err == nil {
+		t.Errorf("CheckSecurity(128) succeeded for n = 1024, want error")
+	}
+	params.P = big.NewInt(1 << 14)
+	if err := params.CheckSecurity(100); err == nil {
+		t.Errorf("CheckSecurity(100) succeeded with p = 2^14, want error")
+	}
+}
+
+func TestChooseParams(t *testing.T) {
+	tests := []struct {
+		name       string
+		numEntries int
+		p          int64
+		bits       float64
+		backend    Backend
+		wantErr    bool
+	}{
+		{"paper database", 1 << 20, 991, 128, Uint32Backend, false},
+		{"lower security", 1 << 20, 991, 80, Uint32Backend, false},
+		{"non-square", 1000, 2, 128, Uint32Backend, false},
+		{"p too large for 2^32", 1 << 20, 1 << 16, 128, BigBackend, false},
+		{"p too large", 1 << 20, 1 << 30, 128, 0, true},
+		{"security too high", 1 << 20, 991, 1000, 0, true},
+		{"empty", 0, 2, 128, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := ChooseParams(tt.numEntries, big.NewInt(tt.p), tt.bits)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ChooseParams() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if err := params.CheckSecurity(tt.bits); err != nil {
+				t.Errorf("chosen params fail CheckSecurity(): %v", err)
+			}
+			if params.SqrtN*params.SqrtN < tt.numEntries || (params.SqrtN-1)*(params.SqrtN-1) >= tt.numEntries {
+				t.Errorf("SqrtN = %v is not the smallest that fits %v entries", params.SqrtN, tt.numEntries)
+			}
+			if params.Backend != tt.backend {
+				t.Errorf("Backend = %v, want %v", params.Backend, tt.backend)
+			}
+			// n is the smallest candidate reaching the target
+			smaller := params
+			smaller.N -= 64
+			if bits, _ := smaller.SecurityBits(); smaller.N >= candidateN[0] && bits >= tt.bits {
+				t.Errorf("n = %v also reaches %v bits, but ChooseParams picked %v", smaller.N, bits, params.N)
+			}
+		})
+	}
+}
+
+func TestChooseParamsForLayout(t *testing.T) {
+	layout, err := ChooseLayout(1000, 64, big.NewInt(991), 1024, 1)
+	if err != nil {
+		t.Fatalf("ChooseLayout() error = %v", err)
+	}
+	params, err := ChooseParamsForLayout(layout, big.NewInt(991), 100)
+	if err != nil {
+		t.Fatalf("ChooseParamsForLayout() error = %v", err)
+	}
+	if rows, cols := layout.Shape(); params.Rows != rows || params.Cols != cols || params.SqrtN != 0 {
+		t.Errorf("ChooseParamsForLayout() shape = %v/%vx%v, want %vx%v", params.SqrtN, params.Rows, params.Cols, rows, cols)
+	}
+	if err := params.CheckSecurity(100); err != nil {
+		t.Errorf("chosen params fail CheckSecurity(): %v", err)
+	}
+	if _, err := ChooseParamsForLayout(Layout{}, big.NewInt(991), 100); err == nil {
+		t.Errorf("ChooseParamsForLayout() with empty layout succeeded, want error")
+	}
+}
+
+func TestChosenParamsProtocol(t *testing.T) {
+	params, err := ChooseParams(256, big.NewInt(991), 128)
+	if err != nil {
+		t.Fatalf("ChooseParams() error = %v", err)
+	}
+	db := NewMatWith(params.SqrtN, params.SqrtN, params.Backend).FillRandom(params.P)
+	server, err := NewServer(params, db)
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	client, err := NewClientFromSeed(params, server.Seed(), server.Hint())
+	if err != nil {
+		t.Fatalf("NewClientFromSeed() error = %v", err)
+	}
+	for _, c := range []Cell{{0, 0}, {3, 11}, {params.SqrtN - 1, params.SqrtN - 1}} {
+		st, qu, _ := client.Query(c.Row, c.Col)
+		ans, _ := server.Answer(qu)
+		got, _ := client.Recover(st, ans)
+		if want := db.Get(c.Row, c.Col).Uint64(); got != want {
+			t.Errorf("Recover() at %v = %v, want %v", c, got, want)
+		}
+	}
+}
+
+// empiricalFailureRate runs queries for random entries of a random database under params, and returns the fraction
+// recovered wrongly along with the smallest noise margin seen
+//
+// the randomness comes from a fixed seed, so the rate is the same on every run
+func empiricalFailureRate(t *testing.T, params Params, queries int) (float64, *big.Int) {
+	t.Helper()
+	if err := params.Validate(); err != nil {
+		t.Fatalf("invalid params: %v", err)
+	}
+	params.Rand = NewPRG([]byte(t.Name()))
+	rows, cols := params.Shape()
+	db := NewMatWith(rows, cols, params.Backend).FillRandomFrom(params.Rand, params.P)
+	A := NewMatWith(cols, params.N, params.Backend).FillRandomFrom(params.Rand, params.Q)
+	hintC := pirSetup(db, A, params)
+
+	failures := 0
+	var minMargin *big.Int
+	for range queries {
+		i, j := randIntFrom(params.Rand, 0, rows-1), randIntFrom(params.Rand, 0, cols-1)
+		st, qu := pirQuery(i, j, A, params)
+		ans, err := pirAnswer(db, qu, params)
+		if err != nil {
+			t.Fatalf("pirAnswer() error = %v", err)
+		}
+		got, margin := pirRecover(ans, st, hintC, params)
+		if got != db.Get(i, j).Uint64() {
+			failures++
+		}
+		if minMargin == nil || margin.Cmp(minMargin) < 0 {
+			minMargin = margin
+		}
+	}
+	return float64(failures) / float64(queries), minMargin
+}
+
+func TestEmpiricalFailureRate(t *testing.T) {
+	// m = 64 binary entries give noise with standard deviation about √32·σ/√(2π) ≈ 14, against a threshold of Δ/2
+	tests := []struct {
+		name    string
+		q       int64
+		minRate float64
+		maxRate float64
+	}{
+		{"Δ/2 = 16", 1 << 6, 0.1, 0.5},
+		{"Δ/2 = 32", 1 << 7, 0.001, 0.1},
+		{"Δ/2 = 2^15", 1 << 16, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := Params{N: 16, Q: big.NewInt(tt.q), P: big.NewInt(2), SqrtN: 64, Backend: Uint32Backend}
+			rate, margin := empiricalFailureRate(t, params, 400)
+			if rate < tt.minRate || rate > tt.maxRate {
+				t.Errorf("empirical failure rate = %v, want in [%v, %v]", rate, tt.minRate, tt.maxRate)
+			}
+			bound, err := params.FailureProbability()
+			if err != nil {
+				t.Fatalf("FailureProbability() error = %v", err)
+			}
+			if rate > bound {
+				t.Errorf("empirical failure rate = %v is above the bound FailureProbability() = %v", rate, bound)
+			}
+			// when the tailcut rules out failures, no noise comes near the threshold either
+			if bound == 0 && margin.Sign() <= 0 {
+				t.Errorf("smallest margin = %v with FailureProbability() = 0, want positive", margin)
+			}
+		})
+	}
+}
+
+func TestRootHermite(t *testing.T) {
+	// δ decreases towards 1 as the block size grows
+	prev := math.Inf(1)
+	for _, beta := range []float64{50, 100, 200, 400} {
+		d := rootHermite(beta)
+		if d <= 1 || d >= prev {
+			t.Errorf("rootHermite(%v) = %v, want in (1, %v)", beta, d, prev)
+		}
+		prev = d
+	}
+}
@@ -0,0 +1,144 @@
+package simplepir
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// Server holds the database, the public matrix A and the hint hintC = db·A
+//
+// hidden fields so that the database and hint only change through [Server.UpdateBatch], construct with
+// [NewServer] or [NewServerFromPrepared]
+type Server struct {
+	prep *PreparedDB
+}
+
+// Creates a new [*Server] for db under the given parameters.
+//
+// Runs the offline phase with [Prepare], which expands the public matrix A from a fresh seed and computes the hint.
+//
+// Usage:
+//
+//	server, err := NewServer(params, db)
+//	client, err := NewClientFromSeed(params, server.Seed(), server.Hint())
+//	st, qu, err := client.Query(row, col) // send qu to the server
+//	ans, err := server.Answer(qu)         // send ans back to the client
+//	value, err := client.Recover(st, ans)
+func NewServer(params Params, db *Mat) (*Server, error) {
+	prep, err := Prepare(params, db)
+	if err != nil {
+		return nil, err
+	}
+	return NewServerFromPrepared(prep), nil
+}
+
+// Creates a new [*Server] answering queries for a database whose offline phase has already been run
+func NewServerFromPrepared(prep *PreparedDB) *Server {
+	return &Server{prep: prep}
+}
+
+// Mode returns [LWEMode]
+func (s *Server) Mode() Mode {
+	return LWEMode
+}
+
+// Params returns the parameters the server was set up with
+func (s *Server) Params() Params {
+	return s.prep.params
+}
+
+// Seed returns the seed A was expanded from, clients can regenerate A from it with [ExpandA]
+func (s *Server) Seed() []byte {
+	return s.prep.Seed()
+}
+
+// A returns the public matrix A, which clients need to build queries
+//
+// Clients should prefer regenerating A from [Server.Seed], which is far smaller
+//
+// The returned matrix is shared with the server and must not be modified
+func (s *Server) A() *Mat {
+	return s.prep.a
+}
+
+// Hint returns hintC = db·A, which clients need to recover answers
+//
+// The returned matrix is shared with the server and must not be modified. The server never modifies it either,
+// as updates patch a copy, see [PreparedDB.Hint].
+func (s *Server) Hint() *Mat {
+	return s.prep.Hint()
+}
+
+// Version returns the version of the hint, see [PreparedDB.Version]
+func (s *Server) Version() HintVersion {
+	return s.prep.Version()
+}
+
+// Commitment returns the commitment to the database, which clients pass to [Client.SetCommitment] to verify answers
+func (s *Server) Commitment() *Commitment {
+	return s.prep.Commitment()
+}
+
+// CheckVersion returns an error wrapping [ErrStaleHint] if a client's hint version v is out of date
+func (s *Server) CheckVersion(v HintVersion) error {
+	return s.prep.CheckVersion(v)
+}
+
+// Update sets the database entry at (row, col) to value, see [PreparedDB.UpdateBatch]
+func (s *Server) Update(row, col int, value *big.Int) (*HintDiff, error) {
+	return s.prep.Update(row, col, value)
+}
+
+// UpdateBatch applies changes to the database and patches the hint, see [PreparedDB.UpdateBatch]
+func (s *Server) UpdateBatch(changes []Change) (*HintDiff, error) {
+	return s.prep.UpdateBatch(changes)
+}
+
+// Answer responds to a query vector qu produced by [Client.Query]
+func (s *Server) Answer(qu *Vec) (*Vec, error) {
+	if _, cols := s.prep.params.Shape(); qu == nil || qu.size != cols {
+		return nil, fmt.Errorf("query must be a vector of size %v", cols)
+	}
+	// hold the read lock for the whole pass, as updates modify the database in place
+	s.prep.mu.RLock()
+	defer s.prep.mu.RUnlock()
+	return pirAnswer(s.prep.db, qu, s.prep.params)
+}
+
+// AnswerBatch responds to a batch of queries produced by [Client.QueryBatch] with a single pass over the database
+//
+// qus holds one query per column, and column k of the result is the answer to query k
+func (s *Server) AnswerBatch(qus *Mat) (*Mat, error) {
+	if _, cols := s.prep.params.Shape(); qus == nil || qus.rows != cols || qus.cols == 0 {
+		return nil, fmt.Errorf("batch must be a matrix with %v rows", cols)
+	}
+	// hold the read lock for the whole pass, as updates modify the database in place
+	s.prep.mu.RLock()
+	defer s.prep.mu.RUnlock()
+	return pirAnswerBatch(s.prep.db, qus, s.prep.params)
+}
+
+// checkDatabase checks the parameters, and that db is a matrix over Z_p of the shape they give
+func checkDatabase(params Params, db *Mat) error {
+	if err := params.Validate(); err != nil {
+		return fmt.Errorf("invalid parameters: %v", err)
+	}
+	if rows, cols := params.Shape(); db == nil || db.rows != rows || db.cols != cols {
+		return fmt.Errorf("database must be a %vx%v matrix", rows, cols)
+	}
+	if err := db.checkOperand(); err != nil {
+		return fmt.Errorf("invalid database: %v", err)
+	}
+	if err := db.checkBelow(params.P); err != nil {
+		return fmt.Errorf("database %v", err)
+	}
+	return nil
+}
+
+// checkIndex checks that (row, col) is an entry of the database described by params
+func checkIndex(row, col int, params Params) error {
+	if rows, cols := params.Shape(); row < 0 || row >= rows || col < 0 || col >= cols {
+		return fmt.Errorf("index (%v, %v) out of range for %vx%v database", row, col, rows, cols)
+	}
+	return nil
+}
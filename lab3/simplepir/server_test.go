@@ -0,0 +1,96 @@
+package simplepir
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func TestNewServer(t *testing.T) {
+	params := Params{N: 16, Q: big.NewInt(1 << 16), P: big.NewInt(2), SqrtN: 8}
+
+	t.Run("valid setup", func(t *testing.T) {
+		db := NewMat(8, 8).FillRandom(big.NewInt(2))
+		server, err := NewServer(params, db)
+		if err != nil {
+			t.Fatalf("NewServer() error = %v", err)
+		}
+		if server.A().rows != params.SqrtN || server.A().cols != params.N {
+			t.Errorf("A has dimensions (%d,%d), want (%d,%d)", server.A().rows, server.A().cols, params.SqrtN, params.N)
+		}
+		if !reflect.DeepEqual(server.Hint(), db.MatMul(server.A(), params.Q)) {
+			t.Errorf("Hint() is not db·A")
+		}
+		A, err := ExpandA(server.Seed(), params)
+		if err != nil {
+			t.Fatalf("ExpandA() error = %v", err)
+		}
+		if !reflect.DeepEqual(server.A(), A) {
+			t.Errorf("A() is not the expansion of Seed()")
+		}
+	})
+
+	t.Run("invalid params", func(t *testing.T) {
+		bad := params
+		bad.N = 0
+		if _, err := NewServer(bad, NewMat(8, 8)); err == nil {
+			t.Error("expected error for invalid params")
+		}
+	})
+
+	t.Run("database entries outside Z_p", func(t *testing.T) {
+		db := NewMat(8, 8)
+		db.data[3][4].SetInt64(2)
+		if _, err := NewServer(params, db); err == nil {
+			t.Error("expected error for entry outside Z_p")
+		}
+		db.data[3][4].SetInt64(-1)
+		if _, err := NewServer(params, db); err == nil {
+			t.Error("expected error for negative entry")
+		}
+	})
+
+	t.Run("wrong database shape", func(t *testing.T) {
+		if _, err := NewServer(params, NewMat(8, 4)); err == nil {
+			t.Error("expected error for wrong database shape")
+		}
+		if _, err := NewServer(params, nil); err == nil {
+			t.Error("expected error for nil database")
+		}
+	})
+}
+
+func TestServerAnswer(t *testing.T) {
+	params := Params{N: 16, Q: big.NewInt(1 << 16), P: big.NewInt(2), SqrtN: 8}
+	db := NewMat(8, 8).FillRandom(big.NewInt(2))
+	server, err := NewServer(params, db)
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+
+	t.Run("valid query", func(t *testing.T) {
+		qu := NewVec(8).FillRandom(params.Q)
+		ans, err := server.Answer(qu)
+		if err != nil {
+			t.Fatalf("Answer() error = %v", err)
+		}
+		if !reflect.DeepEqual(ans, db.VecMul(qu, params.Q)) {
+			t.Errorf("Answer() is not db·qu")
+		}
+	})
+
+	t.Run("wrong query size", func(t *testing.T) {
+		if _, err := server.Answer(NewVec(4)); err == nil {
+			t.Error("expected error for wrong query size")
+		}
+		if _, err := server.Answer(nil); err == nil {
+			t.Error("expected error for nil query")
+		}
+		if _, err := server.Answer(&Vec{size: 8, backend: Backend(99)}); err == nil {
+			t.Error("expected error for query with unknown backend")
+		}
+		if _, err := server.AnswerBatch(&Mat{rows: 8, cols: 2}); err == nil {
+			t.Error("expected error for batch with no entries")
+		}
+	})
+}
@@ -0,0 +1,141 @@
+package simplepir
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+)
+
+// Change sets the database entry at (Row, Col) to Value, which must lie in Z_p
+type Change struct {
+	Row, Col int
+	Value    *big.Int
+}
+
+// HintDiff holds the rows of the hint changed by an update, so that clients can patch their hint rather than
+// downloading it again
+//
+// Applying it to a hint with version From gives the hint with version To, see [Client.ApplyDiff]
+type HintDiff struct {
+	From, To HintVersion
+	Rows     []int // indices of the changed rows, in increasing order
+	Values   *Mat  // row k holds the new value of hint row Rows[k], nil if no rows changed
+}
+
+// Update sets the database entry at (row, col) to value, see [PreparedDB.UpdateBatch]
+func (p *PreparedDB) Update(row, col int, value *big.Int) (*HintDiff, error) {
+	return p.UpdateBatch([]Change{{Row: row, Col: col, Value: value}})
+}
+
+// UpdateBatch applies changes to the database and patches the hint to match, returning the diff clients need
+//
+// Since hintC = db·A, changing db[i][j] by δ changes only row i of the hint, by δ·A[j]. Each change therefore
+// costs O(n) rather than the O(ℓ·m·n) of recomputing the hint with [pirSetup]. Later changes to the same entry
+// win. Either every change is applied or, if any is invalid, none are.
+//
+// The database and hint are modified in place under the write lock, so an update waits for queries being answered.
+// They are copied first if they are still shared, the database with the caller of [Prepare] and the hint with
+// callers of [PreparedDB.Hint], so matrices handed out before the update never change.
+// Only the changed rows of the hint are rehashed for the new version, leaving O(ℓ) work to combine the row digests,
+// and only the changed columns of the commitment, each O(ℓ).
+func (p *PreparedDB) UpdateBatch(changes []Change) (*HintDiff, error) {
+	for _, c := range changes {
+		if err := checkIndex(c.Row, c.Col, p.params); err != nil {
+			return nil, err
+		}
+		if c.Value == nil || c.Value.Sign() < 0 || c.Value.Cmp(p.params.P) >= 0 {
+			return nil, fmt.Errorf("value %v for entry (%v, %v) is not in Z_p for p = %v", c.Value, c.Row, c.Col, p.params.P)
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	diff := &HintDiff{From: p.version, To: p.version}
+	if len(changes) == 0 {
+		return diff, nil
+	}
+
+	// copy on write, paying for a copy of the database once and of the hint once per update that follows a Hint call
+	if p.dbShared {
+		p.db, p.dbShared = p.db.clone(), false
+	}
+	if p.hintShared.Load() {
+		p.hint = p.hint.clone()
+		p.hintShared.Store(false)
+	}
+
+	q := p.params.Q
+	db, hint := p.db, p.hint
+	delta, x := new(big.Int), new(big.Int)
+	for _, c := range changes {
+		// hint[row] += (value - db[row][col])·A[col]
+		delta.Sub(c.Value, db.Get(c.Row, c.Col))
+		db.Set(c.Row, c.Col, c.Value)
+		for k := range p.params.N {
+			x.Mul(delta, p.a.Get(c.Col, k))
+			x.Add(x, hint.Get(c.Row, k))
+			hint.Set(c.Row, k, x.Mod(x, q))
+		}
+		if !slices.Contains(diff.Rows, c.Row) {
+			diff.Rows = append(diff.Rows, c.Row)
+		}
+	}
+	slices.Sort(diff.Rows)
+
+	diff.Values = NewMatWith(len(diff.Rows), p.params.N, p.params.Backend)
+	for r, row := range diff.Rows {
+		for k := range p.params.N {
+			diff.Values.Set(r, k, hint.Get(row, k))
+		}
+	}
+	for _, row := range diff.Rows {
+		p.rowDigests[row] = hintRowDigest(hint, row, p.params)
+	}
+	p.version = combineRowDigests(hint, p.rowDigests)
+	diff.To = p.version
+	if p.commitment != nil {
+		p.commitment.Version = p.version
+		for _, c := range changes {
+			p.commitment.Columns[c.Col] = columnHash(c.Col, column(db, c.Col))
+		}
+	}
+	return diff, nil
+}
+
+// ApplyDiff patches the client's hint with the rows changed by a server-side update
+//
+// Returns an error wrapping [ErrStaleHint] if the diff was made against a different hint than the client's,
+// in which case the client needs to download the whole hint again. The client is left unchanged on error.
+func (c *Client) ApplyDiff(diff *HintDiff) error {
+	if diff == nil {
+		return fmt.Errorf("diff must not be nil")
+	}
+	if diff.From != c.version {
+		return fmt.Errorf("%w: diff applies to version %v, client has %v", ErrStaleHint, diff.From, c.version)
+	}
+	if len(diff.Rows) == 0 {
+		if diff.To != diff.From {
+			return fmt.Errorf("diff changes the version without changing any rows")
+		}
+		return nil
+	}
+	if diff.Values == nil || diff.Values.rows != len(diff.Rows) || diff.Values.cols != c.params.N {
+		return fmt.Errorf("diff values must be a %vx%v matrix", len(diff.Rows), c.params.N)
+	}
+
+	hint := c.hint.clone()
+	for r, row := range diff.Rows {
+		if rows, _ := c.params.Shape(); row < 0 || row >= rows {
+			return fmt.Errorf("diff row %v out of range for %v rows", row, rows)
+		}
+		for k := range c.params.N {
+			hint.Set(row, k, diff.Values.Get(r, k))
+		}
+	}
+	version := hintVersion(hint, c.params)
+	if version != diff.To {
+		return fmt.Errorf("patched hint has version %v, diff promised %v", version, diff.To)
+	}
+	c.hint, c.version = hint, version
+	return nil
+}
@@ -0,0 +1,268 @@
+package simplepir
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestUpdate(t *testing.T) { forEachBackend(t, testUpdate) }
+
+func testUpdate(t *testing.T, backend Backend) {
+	params := Params{N: 32, Q: new(big.Int).Lsh(big.NewInt(1), 32), P: big.NewInt(991), SqrtN: 8, Backend: backend}
+	db := NewMatWith(8, 8, backend).FillRandom(params.P)
+	original := db.clone()
+	server, err := NewServer(params, db)
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	oldHint := server.Hint()
+	if server.Hint() != oldHint {
+		t.Errorf("Hint() copied the hint, want it shared until an update")
+	}
+	client, err := NewClientFromSeed(params, server.Seed(), oldHint)
+	if err != nil {
+		t.Fatalf("NewClientFromSeed() error = %v", err)
+	}
+
+	changes := []Change{
+		{Row: 2, Col: 5, Value: big.NewInt(990)},
+		{Row: 2, Col: 1, Value: big.NewInt(0)},
+		{Row: 6, Col: 0, Value: big.NewInt(17)},
+		{Row: 6, Col: 0, Value: big.NewInt(18)}, // later changes win
+	}
+	diff, err := server.UpdateBatch(changes)
+	if err != nil {
+		t.Fatalf("UpdateBatch() error = %v", err)
+	}
+
+	want := original.clone()
+	for _, c := range changes {
+		want.Set(c.Row, c.Col, c.Value)
+	}
+	if got := server.prep.db; !reflect.DeepEqual(got, want) {
+		t.Errorf("database after update does not hold the changes")
+	}
+	wantHint := pirSetup(want, server.A(), params)
+	if !reflect.DeepEqual(server.Hint(), wantHint) {
+		t.Errorf("patched hint is not db·A for the updated database")
+	}
+	if server.Version() != hintVersion(wantHint, params) || diff.To != server.Version() || diff.From != client.Version() {
+		t.Errorf("diff versions %v -> %v, want %v -> %v", diff.From, diff.To, client.Version(), server.Version())
+	}
+	if !reflect.DeepEqual(diff.Rows, []int{2, 6}) {
+		t.Errorf("diff rows = %v, want [2 6]", diff.Rows)
+	}
+	if !reflect.DeepEqual(oldHint, pirSetup(original, server.A(), params)) || !reflect.DeepEqual(db, original) {
+		t.Errorf("update modified the matrices it replaced")
+	}
+
+	// before applying the diff the client's hint is stale
+	if err := server.CheckVersion(client.Version()); !errors.Is(err, ErrStaleHint) {
+		t.Errorf("CheckVersion() before ApplyDiff error = %v, want %v", err, ErrStaleHint)
+	}
+	if err := client.ApplyDiff(diff); err != nil {
+		t.Fatalf("ApplyDiff() error = %v", err)
+	}
+	if err := server.CheckVersion(client.Version()); err != nil {
+		t.Errorf("CheckVersion() after ApplyDiff error = %v", err)
+	}
+	for _, c := range changes[1:] {
+		st, qu, err := client.Query(c.Row, c.Col)
+		if err != nil {
+			t.Fatalf("Query() error = %v", err)
+		}
+		ans, err := server.Answer(qu)
+		if err != nil {
+			t.Fatalf("Answer() error = %v", err)
+		}
+		if got, _ := client.Recover(st, ans); got != want.Get(c.Row, c.Col).Uint64() {
+			t.Errorf("Recover(%v, %v) = %v, want %v", c.Row, c.Col, got, want.Get(c.Row, c.Col))
+		}
+	}
+	if err := client.ApplyDiff(diff); !errors.Is(err, ErrStaleHint) {
+		t.Errorf("ApplyDiff() twice error = %v, want %v", err, ErrStaleHint)
+	}
+
+	// an empty batch changes nothing
+	empty, err := server.UpdateBatch(nil)
+	if err != nil || empty.From != empty.To || empty.To != server.Version() || len(empty.Rows) != 0 {
+		t.Errorf("UpdateBatch(nil) = %+v, %v, want empty diff", empty, err)
+	}
+	if err := client.ApplyDiff(empty); err != nil {
+		t.Errorf("ApplyDiff() of empty diff error = %v", err)
+	}
+}
+
+func TestUpdateConcurrent(t *testing.T) {
+	params := Params{N: 32, Q: big.NewInt(1 << 32), P: big.NewInt(991), SqrtN: 8, Backend: Uint32Backend}
+	server, err := NewServer(params, NewMatWith(8, 8, Uint32Backend))
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	client, err := NewClientFromSeed(params, server.Seed(), server.Hint())
+	if err != nil {
+		t.Fatalf("NewClientFromSeed() error = %v", err)
+	}
+	_, qu, _ := client.Query(3, 4)
+
+	// answers hold the read lock for their whole pass, so run them against updates under the race detector
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				if _, err := server.Answer(qu); err != nil {
+					t.Errorf("Answer() error = %v", err)
+				}
+				server.Hint()
+			}
+		}()
+	}
+	for i := range 20 {
+		if _, err := server.Update(3, i%8, big.NewInt(int64(i))); err != nil {
+			t.Errorf("Update() error = %v", err)
+		}
+	}
+	wg.Wait()
+	if want := hintVersion(server.Hint(), params); server.Version() != want {
+		t.Errorf("Version() after concurrent updates = %v, want %v", server.Version(), want)
+	}
+}
+
+func TestUpdateErrors(t *testing.T) {
+	params := Params{N: 16, Q: big.NewInt(1 << 16), P: big.NewInt(2), SqrtN: 8}
+	server, err := NewServer(params, NewMat(8, 8).FillRandom(params.P))
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	version := server.Version()
+
+	tests := []struct {
+		name    string
+		changes []Change
+	}{
+		{"row out of range", []Change{{Row: 8, Col: 0, Value: big.NewInt(1)}}},
+		{"negative column", []Change{{Row: 0, Col: -1, Value: big.NewInt(1)}}},
+		{"nil value", []Change{{Row: 0, Col: 0}}},
+		{"value outside Z_p", []Change{{Row: 0, Col: 0, Value: big.NewInt(2)}}},
+		{"negative value", []Change{{Row: 0, Col: 0, Value: big.NewInt(-1)}}},
+		{"valid then invalid", []Change{{Row: 0, Col: 0, Value: big.NewInt(1)}, {Row: 0, Col: 9, Value: big.NewInt(1)}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := server.UpdateBatch(tt.changes); err == nil {
+				t.Errorf("UpdateBatch() succeeded, want error")
+			}
+			if server.Version() != version {
+				t.Errorf("failed update changed the version")
+			}
+		})
+	}
+}
+
+func TestApplyDiffErrors(t *testing.T) {
+	params := Params{N: 16, Q: big.NewInt(1 << 16), P: big.NewInt(2), SqrtN: 8}
+	server, err := NewServer(params, NewMat(8, 8))
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	client, err := NewClientFromSeed(params, server.Seed(), server.Hint())
+	if err != nil {
+		t.Fatalf("NewClientFromSeed() error = %v", err)
+	}
+	diff, err := server.Update(3, 4, big.NewInt(1))
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	tampered := *diff
+	tampered.Values = diff.Values.clone()
+	tampered.Values.Set(0, 0, new(big.Int).Add(diff.Values.Get(0, 0), big.NewInt(1)))
+
+	tests := []struct {
+		name string
+		diff *HintDiff
+	}{
+		{"nil diff", nil},
+		{"tampered values", &tampered},
+		{"wrong row", &HintDiff{From: diff.From, To: diff.To, Rows: []int{2}, Values: diff.Values}},
+		{"row out of range", &HintDiff{From: diff.From, To: diff.To, Rows: []int{8}, Values: diff.Values}},
+		{"missing values", &HintDiff{From: diff.From, To: diff.To, Rows: diff.Rows}},
+		{"no rows but new version", &HintDiff{From: diff.From, To: diff.To}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := client.ApplyDiff(tt.diff); err == nil {
+				t.Errorf("ApplyDiff() succeeded, want error")
+			}
+			if client.Version() != diff.From {
+				t.Errorf("failed ApplyDiff() changed the client's version")
+			}
+		})
+	}
+}
+
+func TestLayoutChanges(t *testing.T) {
+	records := make([][]byte, 6)
+	for i := range records {
+		records[i] = make([]byte, 24)
+		rand.Read(records[i])
+	}
+	db, err := NewDatabase(records, big.NewInt(256))
+	if err != nil {
+		t.Fatalf("NewDatabase() error = %v", err)
+	}
+	layout := db.Layout()
+	params := Params{N: 16, Q: big.NewInt(1 << 32), P: big.NewInt(256), SqrtN: layout.SqrtN, Backend: Uint32Backend}
+	server, err := NewServer(params, db.Mat())
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	client, err := NewClientFromSeed(params, server.Seed(), server.Hint())
+	if err != nil {
+		t.Fatalf("NewClientFromSeed() error = %v", err)
+	}
+
+	record := make([]byte, layout.RecordSize)
+	rand.Read(record)
+	changes, err := layout.Changes(4, record)
+	if err != nil {
+		t.Fatalf("Changes() error = %v", err)
+	}
+	diff, err := server.UpdateBatch(changes)
+	if err != nil {
+		t.Fatalf("UpdateBatch() error = %v", err)
+	}
+	if err := client.ApplyDiff(diff); err != nil {
+		t.Fatalf("ApplyDiff() error = %v", err)
+	}
+
+	for i, want := range [][]byte{records[3], record, records[5]} {
+		st, queries, err := client.QueryRecord(layout, i+3)
+		if err != nil {
+			t.Fatalf("QueryRecord() error = %v", err)
+		}
+		answers := make([]*Vec, len(queries))
+		for k, qu := range queries {
+			if answers[k], err = server.Answer(qu); err != nil {
+				t.Fatalf("Answer() error = %v", err)
+			}
+		}
+		if got, err := client.RecoverRecord(st, answers); err != nil || !bytes.Equal(got, want) {
+			t.Errorf("RecoverRecord(%v) = %x, %v, want %x", i+3, got, err, want)
+		}
+	}
+
+	if _, err := layout.Changes(4, record[1:]); err == nil {
+		t.Errorf("Changes() with short record succeeded, want error")
+	}
+	if _, err := layout.Changes(6, record); err == nil {
+		t.Errorf("Changes() with out of range index succeeded, want error")
+	}
+}
@@ -3,45 +3,152 @@ package simplepir
 import (
 	"crypto/rand"
 	"fmt"
+	"io"
 	"math/big"
+	"sync"
 )
 
 type Vec struct {
-	data []*big.Int
-	size int
+	data    []*big.Int // entries for [BigBackend]
+	packed  []uint32   // entries for [Uint32Backend]
+	size    int
+	backend Backend
 }
 
 func NewVec(n int) *Vec {
+	return NewVecWith(n, BigBackend)
+}
+
+// Creates a new zero vector of size n, storing its entries using the given [Backend]
+//
+// Panics on a non-positive size or an unknown backend, see [NewVecChecked]
+func NewVecWith(n int, backend Backend) *Vec {
+	return must(NewVecChecked(n, backend))
+}
+
+// NewVecChecked is [NewVecWith], but returns an error rather than panicking, wrapping [ErrEmpty] if n is not positive
+func NewVecChecked(n int, backend Backend) (*Vec, error) {
 	if n <= 0 {
-		panic("cannot initialise vector with non-positive size")
+		return nil, fmt.Errorf("%w: cannot initialise vector of size %v", ErrEmpty, n)
+	}
+	if err := checkBackend(backend); err != nil {
+		return nil, err
+	}
+	if backend == Uint32Backend {
+		return &Vec{packed: make([]uint32, n), size: n, backend: backend}, nil
 	}
 	result := make([]*big.Int, n)
 	for i := range result {
 		result[i] = big.NewInt(0)
 	}
-	return &Vec{result, n}
+	return &Vec{data: result, size: n, backend: backend}, nil
+}
+
+// checkOperand returns an error wrapping [ErrEmpty] if v is nil or has no entries, or if its backend is unknown
+func (v *Vec) checkOperand() error {
+	if v == nil || v.size <= 0 {
+		return fmt.Errorf("%w: vector operand", ErrEmpty)
+	}
+	if err := checkBackend(v.backend); err != nil {
+		return err
+	}
+	if (v.backend == Uint32Backend && len(v.packed) != v.size) || (v.backend == BigBackend && len(v.data) != v.size) {
+		return fmt.Errorf("%w: vector of size %v has no storage for its entries", ErrEmpty, v.size)
+	}
+	return nil
+}
+
+// checkSameSize returns an error if v1 and v2 cannot be combined entrywise modulo mod
+func checkSameSize(v1, v2 *Vec, mod *big.Int) error {
+	if err := v1.checkOperand(); err != nil {
+		return err
+	}
+	if err := v2.checkOperand(); err != nil {
+		return err
+	}
+	if v1.size != v2.size {
+		return fmt.Errorf("%w: sizes %v and %v", ErrDimensionMismatch, v1.size, v2.size)
+	}
+	return checkMod(v1.backend, mod)
+}
+
+// Size returns the number of entries in the vector
+func (v *Vec) Size() int {
+	return v.size
+}
+
+// Backend returns the [Backend] used to store the vector's entries
+func (v *Vec) Backend() Backend {
+	return v.backend
+}
+
+// Get returns a copy of the entry at index i
+func (v *Vec) Get(i int) *big.Int {
+	if v.backend == Uint32Backend {
+		return new(big.Int).SetUint64(uint64(v.packed[i]))
+	}
+	return new(big.Int).Set(v.data[i])
 }
 
+// Set sets the entry at index i to x
+//
+// For [Uint32Backend], x is stored modulo 2^32
+//
+// returns v
+func (v *Vec) Set(i int, x *big.Int) *Vec {
+	if v.backend == Uint32Backend {
+		v.packed[i] = toUint32(x)
+	} else {
+		v.data[i].Set(x)
+	}
+	return v
+}
+
+// Fills the vector with the data in the int64 slice
+//
+// For [Uint32Backend], values are stored modulo 2^32
+//
+// Returns a pointer to the filled vector for convenience
 func (v *Vec) Fill(values []int64) *Vec {
+	return must(v.FillChecked(values))
+}
+
+// FillChecked is [Vec.Fill], but returns an error rather than panicking, wrapping [ErrEmpty] for an empty vector
+// and [ErrDimensionMismatch] if values does not hold exactly one value per entry
+func (v *Vec) FillChecked(values []int64) (*Vec, error) {
+	if err := v.checkOperand(); err != nil {
+		return nil, err
+	}
 	if v.size != len(values) {
-		panic(fmt.Sprintf("size mismatch, got vector of size %v and values of size %v", v.size, len(values)))
+		return nil, fmt.Errorf("%w: got vector of size %v and %v values", ErrDimensionMismatch, v.size, len(values))
 	}
 	for i := range v.size {
-		v.data[i].SetInt64(values[i])
+		if v.backend == Uint32Backend {
+			v.packed[i] = uint32(values[i])
+		} else {
+			v.data[i].SetInt64(values[i])
+		}
 	}
-	return v
+	return v, nil
 }
 
 // fills the vector with random [*big.Int]s in the range [0,max)
 //
 // returns the newly filled vector
 func (v *Vec) FillRandom(max *big.Int) *Vec {
+	return v.FillRandomFrom(rand.Reader, max)
+}
+
+// fills the vector with values in the range [0,max) read from r, deterministic for a deterministic r
+//
+// r can be e.g. [NewPRG] to make a run reproducible
+func (v *Vec) FillRandomFrom(r io.Reader, max *big.Int) *Vec {
+	if v.backend == Uint32Backend {
+		randUint32s(r, v.packed, max)
+		return v
+	}
 	for i := range v.data {
-		r, err := rand.Int(rand.Reader, max)
-		if err != nil {
-			panic(err)
-		}
-		v.data[i].Set(r)
+		v.data[i].Set(uniformFrom(r, max))
 	}
 	return v
 }
@@ -50,58 +157,194 @@ func (v *Vec) FillRandom(max *big.Int) *Vec {
 //
 // returns v
 func (v *Vec) OneHot(index int) *Vec {
-	for x := range v.data {
+	for x := range v.size {
+		var bit int64
 		if x == index {
-			v.data[x].SetInt64(1)
+			bit = 1
+		}
+		if v.backend == Uint32Backend {
+			v.packed[x] = uint32(bit)
 		} else {
-			v.data[x].SetInt64(0)
+			v.data[x].SetInt64(bit)
 		}
 	}
 	return v
 }
 
+// as returns v stored using backend b, converting (and copying) only if necessary
+func (v *Vec) as(b Backend) *Vec {
+	if v.backend == b {
+		return v
+	}
+	result := NewVecWith(v.size, b)
+	for i := range v.size {
+		result.Set(i, v.Get(i))
+	}
+	return result
+}
+
 // adds v1 and v2 (modulo mod) and return the new vector it creates
+//
+// Allocates the result, see [Vec.SetAdd] to reuse a vector instead
 func (v1 *Vec) Add(v2 *Vec, mod *big.Int) *Vec {
-	if v1.size == 0 || v2.size == 0 {
-		panic("cannot add empty vectors")
+	return must(v1.AddChecked(v2, mod))
+}
+
+// AddChecked is [Vec.Add], but returns an error rather than panicking, wrapping [ErrEmpty] if either vector is
+// nil or empty and [ErrDimensionMismatch] if their sizes differ
+func (v1 *Vec) AddChecked(v2 *Vec, mod *big.Int) (*Vec, error) {
+	if err := checkSameSize(v1, v2, mod); err != nil {
+		return nil, err
 	}
-	if v1.size != v2.size {
-		panic(fmt.Sprintf("sizes must match, got %v and %v", v1.size, v2.size))
+	return NewVecWith(v1.size, v1.backend).add(v1, v2, mod), nil
+}
+
+// SetAdd sets z to x + y (modulo mod) and returns z, like [big.Int.Add]
+//
+// z must be the size of x and y, and may be x or y itself. Panics on invalid operands as for [Vec.Add].
+func (z *Vec) SetAdd(x, y *Vec, mod *big.Int) *Vec {
+	if err := z.checkDest(x, y, mod); err != nil {
+		panic(err)
 	}
-	result := NewVec(v1.size)
+	return z.add(x, y, mod)
+}
 
-	temp := new(big.Int)
-	for x := range v1.data {
-		temp.Add(v1.data[x], v2.data[x])
-		result.data[x].Mod(temp, mod)
+// add sets z to x + y (modulo mod) without checking the operands
+func (z *Vec) add(x, y *Vec, mod *big.Int) *Vec {
+	x, y = x.as(z.backend), y.as(z.backend)
+	if z.backend == Uint32Backend {
+		m, wrap := uint32Mod(mod)
+		for i := range z.packed {
+			if wrap {
+				z.packed[i] = x.packed[i] + y.packed[i]
+			} else {
+				z.packed[i] = uint32((uint64(x.packed[i]) + uint64(y.packed[i])) % m)
+			}
+		}
+		return z
 	}
-	return result
+	quo := new(big.Int)
+	for i := range z.data {
+		reduce(z.data[i].Add(x.data[i], y.data[i]), quo, mod)
+	}
+	return z
 }
 
 // computes v1 - v2 (modulo mod) and return the new vector it creates
+//
+// Allocates the result, see [Vec.SetSub] to reuse a vector instead
 func (v1 *Vec) Sub(v2 *Vec, mod *big.Int) *Vec {
-	if v1.size == 0 || v2.size == 0 {
-		panic("cannot subtract empty vectors")
+	return must(v1.SubChecked(v2, mod))
+}
+
+// SubChecked is [Vec.Sub], but returns an error rather than panicking, as for [Vec.AddChecked]
+func (v1 *Vec) SubChecked(v2 *Vec, mod *big.Int) (*Vec, error) {
+	if err := checkSameSize(v1, v2, mod); err != nil {
+		return nil, err
 	}
-	if v1.size != v2.size {
-		panic(fmt.Sprintf("sizes must match, got %v and %v", v1.size, v2.size))
+	return NewVecWith(v1.size, v1.backend).sub(v1, v2, mod), nil
+}
+
+// SetSub sets z to x - y (modulo mod) and returns z, like [big.Int.Sub]
+//
+// z must be the size of x and y, and may be x or y itself. Panics on invalid operands as for [Vec.Sub].
+func (z *Vec) SetSub(x, y *Vec, mod *big.Int) *Vec {
+	if err := z.checkDest(x, y, mod); err != nil {
+		panic(err)
 	}
-	result := NewVec(v1.size)
+	return z.sub(x, y, mod)
+}
 
-	temp := new(big.Int)
-	for x := range v1.data {
-		temp.Sub(v1.data[x], v2.data[x])
-		result.data[x].Mod(temp, mod)
+// sub sets z to x - y (modulo mod) without checking the operands
+func (z *Vec) sub(x, y *Vec, mod *big.Int) *Vec {
+	x, y = x.as(z.backend), y.as(z.backend)
+	if z.backend == Uint32Backend {
+		m, wrap := uint32Mod(mod)
+		for i := range z.packed {
+			if wrap {
+				z.packed[i] = x.packed[i] - y.packed[i]
+			} else {
+				z.packed[i] = uint32((uint64(x.packed[i])%m + m - uint64(y.packed[i])%m) % m)
+			}
+		}
+		return z
 	}
-	return result
+	quo := new(big.Int)
+	for i := range z.data {
+		reduce(z.data[i].Sub(x.data[i], y.data[i]), quo, mod)
+	}
+	return z
 }
 
+// multiplies every entry of v by value (modulo mod) and returns the new vector it creates
+//
+// Allocates the result, see [Vec.SetScale] to reuse a vector instead
 func (v *Vec) Scale(value *big.Int, mod *big.Int) *Vec {
-	result := NewVec(v.size)
-	temp := new(big.Int)
-	for i := range v.data {
-		temp.Mul(v.data[i], value)
-		result.data[i].Mod(temp, mod)
+	return NewVecWith(v.size, v.backend).SetScale(v, value, mod)
+}
+
+// SetScale sets z to value·x (modulo mod) and returns z, z must be the size of x and may be x itself
+func (z *Vec) SetScale(x *Vec, value *big.Int, mod *big.Int) *Vec {
+	if err := z.checkDest(x, x, mod); err != nil {
+		panic(err)
+	}
+	x = x.as(z.backend)
+	if z.backend == Uint32Backend {
+		m, wrap := uint32Mod(mod)
+		c := new(big.Int).Mod(value, mod).Uint64()
+		for i := range z.packed {
+			if wrap {
+				z.packed[i] = x.packed[i] * uint32(c)
+			} else {
+				z.packed[i] = uint32(uint64(x.packed[i]) % m * c % m)
+			}
+		}
+		return z
+	}
+	quo := new(big.Int)
+	for i := range z.data {
+		reduce(z.data[i].Mul(x.data[i], value), quo, mod)
+	}
+	return z
+}
+
+// checkDest checks that x and y can be combined entrywise modulo mod, with the result stored in z
+func (z *Vec) checkDest(x, y *Vec, mod *big.Int) error {
+	if err := checkSameSize(x, y, mod); err != nil {
+		return err
+	}
+	if err := z.checkOperand(); err != nil {
+		return err
+	}
+	if z.size != x.size {
+		return fmt.Errorf("%w: destination of size %v for operands of size %v", ErrDimensionMismatch, z.size, x.size)
+	}
+	return checkMod(z.backend, mod)
+}
+
+// scratchKey identifies the pool of scratch vectors for one size and backend
+type scratchKey struct {
+	size    int
+	backend Backend
+}
+
+// scratchPools holds a *[sync.Pool] of scratch vectors per [scratchKey], see [getScratch]
+var scratchPools sync.Map
+
+// getScratch returns a vector of size n using backend, reused from an earlier [putScratch] if possible
+//
+// the entries are left over from the previous user, so the caller must overwrite them before reading
+func getScratch(n int, backend Backend) *Vec {
+	pool, _ := scratchPools.LoadOrStore(scratchKey{n, backend}, &sync.Pool{})
+	if v, ok := pool.(*sync.Pool).Get().(*Vec); ok {
+		return v
+	}
+	return NewVecWith(n, backend)
+}
+
+// putScratch returns v to its pool once the caller is done with it, v must not be used afterwards
+func putScratch(v *Vec) {
+	if pool, ok := scratchPools.Load(scratchKey{v.size, v.backend}); ok {
+		pool.(*sync.Pool).Put(v)
 	}
-	return result
 }
@@ -1,17 +1,21 @@
 package simplepir
 
 import (
+	"errors"
 	"math/big"
 	"testing"
 )
 
-func TestNewVec(t *testing.T) {
+func TestNewVec(t *testing.T) { forEachBackend(t, testNewVec) }
+
+func testNewVec(t *testing.T, backend Backend) {
 	t.Run("valid size", func(t *testing.T) {
-		v := NewVec(5)
+		v := NewVecWith(5, backend)
 		if v.size != 5 {
 			t.Errorf("NewVec(5).Size() = %v, want 5", v.size)
 		}
-		for i, val := range v.data {
+		for i := range v.size {
+			val := v.Get(i)
 			if val.Int64() != 0 {
 				t.Errorf("NewVec(5).vec[%v] = %v, want 0", i, val)
 			}
@@ -24,16 +28,19 @@ func TestNewVec(t *testing.T) {
 				t.Errorf("NewVec(0) did not panic")
 			}
 		}()
-		NewVec(0)
+		NewVecWith(0, backend)
 	})
 }
 
-func TestFill(t *testing.T) {
+func TestFill(t *testing.T) { forEachBackend(t, testFill) }
+
+func testFill(t *testing.T, backend Backend) {
 	t.Run("valid fill", func(t *testing.T) {
-		v := NewVec(3)
+		v := NewVecWith(3, backend)
 		values := []int64{1, 2, 3}
 		v.Fill(values)
-		for i, val := range v.data {
+		for i := range v.size {
+			val := v.Get(i)
 			if val.Int64() != values[i] {
 				t.Errorf("Fill() index %v = %v, want %v", i, val, values[i])
 			}
@@ -46,30 +53,60 @@ func TestFill(t *testing.T) {
 				t.Errorf("Fill() with size mismatch did not panic")
 			}
 		}()
-		v := NewVec(3)
+		v := NewVecWith(3, backend)
 		v.Fill([]int64{1, 2})
 	})
 }
 
-func TestFillRandom(t *testing.T) {
+func TestFillRandom(t *testing.T) { forEachBackend(t, testFillRandom) }
+
+func testFillRandom(t *testing.T, backend Backend) {
 	max := big.NewInt(100)
-	v := NewVec(1000)
+	v := NewVecWith(1000, backend)
 	v.FillRandom(max)
 
-	for i, val := range v.data {
+	for i := range v.size {
+		val := v.Get(i)
 		if val.Cmp(max) >= 0 || val.Sign() < 0 {
 			t.Errorf("FillRandom() index %v = %v, want value in [0,%v)", i, val, max)
 		}
 	}
 }
 
-func TestOneHot(t *testing.T) {
+func TestFillRandomFrom(t *testing.T) { forEachBackend(t, testFillRandomFrom) }
+
+func testFillRandomFrom(t *testing.T, backend Backend) {
+	max := big.NewInt(100)
+	seed := NewSeed()
+	v1 := NewVecWith(64, backend).FillRandomFrom(NewPRG(seed), max)
+	v2 := NewVecWith(64, backend).FillRandomFrom(NewPRG(seed), max)
+	v3 := NewVecWith(64, backend).FillRandomFrom(NewPRG(NewSeed()), max)
+	same, differ := true, false
+	for i := range v1.size {
+		if v1.Get(i).Cmp(max) >= 0 {
+			t.Errorf("FillRandomFrom() index %v = %v, want value in [0,%v)", i, v1.Get(i), max)
+		}
+		same = same && v1.Get(i).Cmp(v2.Get(i)) == 0
+		differ = differ || v1.Get(i).Cmp(v3.Get(i)) != 0
+	}
+	if !same {
+		t.Errorf("FillRandomFrom() gave different vectors for the same seed")
+	}
+	if !differ {
+		t.Errorf("FillRandomFrom() gave the same vector for different seeds")
+	}
+}
+
+func TestOneHot(t *testing.T) { forEachBackend(t, testOneHot) }
+
+func testOneHot(t *testing.T, backend Backend) {
 	size := 5
 	for idx := range size {
-		v := NewVec(size)
+		v := NewVecWith(size, backend)
 		v.OneHot(idx)
 
-		for i, val := range v.data {
+		for i := range v.size {
+			val := v.Get(i)
 			expected := int64(0)
 			if i == idx {
 				expected = 1
@@ -81,16 +118,19 @@ func TestOneHot(t *testing.T) {
 	}
 }
 
-func TestAdd(t *testing.T) {
+func TestAdd(t *testing.T) { forEachBackend(t, testAdd) }
+
+func testAdd(t *testing.T, backend Backend) {
 	t.Run("basic addition", func(t *testing.T) {
-		v1 := NewVec(3).Fill([]int64{1, 2, 3})
-		v2 := NewVec(3).Fill([]int64{4, 5, 6})
+		v1 := NewVecWith(3, backend).Fill([]int64{1, 2, 3})
+		v2 := NewVecWith(3, backend).Fill([]int64{4, 5, 6})
 		mod := big.NewInt(100)
 
 		result := v1.Add(v2, mod)
 		expected := []int64{5, 7, 9}
 
-		for i, val := range result.data {
+		for i := range result.size {
+			val := result.Get(i)
 			if val.Int64() != expected[i] {
 				t.Errorf("Add() index %v = %v, want %v", i, val, expected[i])
 			}
@@ -98,14 +138,15 @@ func TestAdd(t *testing.T) {
 	})
 
 	t.Run("modular addition", func(t *testing.T) {
-		v1 := NewVec(2).Fill([]int64{7, 8})
-		v2 := NewVec(2).Fill([]int64{5, 6})
+		v1 := NewVecWith(2, backend).Fill([]int64{7, 8})
+		v2 := NewVecWith(2, backend).Fill([]int64{5, 6})
 		mod := big.NewInt(10)
 
 		result := v1.Add(v2, mod)
 		expected := []int64{2, 4} // (7+5)%10=2, (8+6)%10=4
 
-		for i, val := range result.data {
+		for i := range result.size {
+			val := result.Get(i)
 			if val.Int64() != expected[i] {
 				t.Errorf("Add() with mod index %v = %v, want %v", i, val, expected[i])
 			}
@@ -113,16 +154,19 @@ func TestAdd(t *testing.T) {
 	})
 }
 
-func TestSub(t *testing.T) {
+func TestSub(t *testing.T) { forEachBackend(t, testSub) }
+
+func testSub(t *testing.T, backend Backend) {
 	t.Run("basic subtraction", func(t *testing.T) {
-		v1 := NewVec(3).Fill([]int64{4, 5, 6})
-		v2 := NewVec(3).Fill([]int64{1, 2, 3})
+		v1 := NewVecWith(3, backend).Fill([]int64{4, 5, 6})
+		v2 := NewVecWith(3, backend).Fill([]int64{1, 2, 3})
 		mod := big.NewInt(100)
 
 		result := v1.Sub(v2, mod)
 		expected := []int64{3, 3, 3}
 
-		for i, val := range result.data {
+		for i := range result.size {
+			val := result.Get(i)
 			if val.Int64() != expected[i] {
 				t.Errorf("Sub() index %v = %v, want %v", i, val, expected[i])
 			}
@@ -130,14 +174,15 @@ func TestSub(t *testing.T) {
 	})
 
 	t.Run("modular subtraction", func(t *testing.T) {
-		v1 := NewVec(2).Fill([]int64{2, 3})
-		v2 := NewVec(2).Fill([]int64{5, 7})
+		v1 := NewVecWith(2, backend).Fill([]int64{2, 3})
+		v2 := NewVecWith(2, backend).Fill([]int64{5, 7})
 		mod := big.NewInt(10)
 
 		result := v1.Sub(v2, mod)
 		expected := []int64{7, 6} // (2-5)%10=7, (3-7)%10=6
 
-		for i, val := range result.data {
+		for i := range result.size {
+			val := result.Get(i)
 			if val.Int64() != expected[i] {
 				t.Errorf("Sub() with mod index %v = %v, want %v", i, val, expected[i])
 			}
@@ -145,16 +190,19 @@ func TestSub(t *testing.T) {
 	})
 }
 
-func TestScale(t *testing.T) {
+func TestScale(t *testing.T) { forEachBackend(t, testScale) }
+
+func testScale(t *testing.T, backend Backend) {
 	t.Run("basic scaling", func(t *testing.T) {
-		v := NewVec(3).Fill([]int64{1, 2, 3})
+		v := NewVecWith(3, backend).Fill([]int64{1, 2, 3})
 		scale := big.NewInt(2)
 		mod := big.NewInt(100)
 
 		result := v.Scale(scale, mod)
 		expected := []int64{2, 4, 6}
 
-		for i, val := range result.data {
+		for i := range result.size {
+			val := result.Get(i)
 			if val.Int64() != expected[i] {
 				t.Errorf("Scale() index %v = %v, want %v", i, val, expected[i])
 			}
@@ -162,17 +210,180 @@ func TestScale(t *testing.T) {
 	})
 
 	t.Run("modular scaling", func(t *testing.T) {
-		v := NewVec(3).Fill([]int64{4, 5, 6})
+		v := NewVecWith(3, backend).Fill([]int64{4, 5, 6})
 		scale := big.NewInt(3)
 		mod := big.NewInt(10)
 
 		result := v.Scale(scale, mod)
 		expected := []int64{2, 5, 8} // (4*3)%10=2, (5*3)%10=5, (6*3)%10=8
 
-		for i, val := range result.data {
+		for i := range result.size {
+			val := result.Get(i)
 			if val.Int64() != expected[i] {
 				t.Errorf("Scale() with mod index %v = %v, want %v", i, val, expected[i])
 			}
 		}
 	})
 }
+
+func TestVecChecked(t *testing.T) { forEachBackend(t, testVecChecked) }
+
+func testVecChecked(t *testing.T, backend Backend) {
+	v := NewVecWith(3, backend).Fill([]int64{1, 2, 3})
+	mod := big.NewInt(10)
+
+	tests := []struct {
+		name    string
+		op      func() (*Vec, error)
+		wantErr error
+	}{
+		{"new", func() (*Vec, error) { return NewVecChecked(3, backend) }, nil},
+		{"new empty", func() (*Vec, error) { return NewVecChecked(0, backend) }, ErrEmpty},
+		{"fill", func() (*Vec, error) { return NewVecWith(3, backend).FillChecked([]int64{1, 2, 3}) }, nil},
+		{"fill wrong size", func() (*Vec, error) { return NewVecWith(3, backend).FillChecked([]int64{1, 2}) }, ErrDimensionMismatch},
+		{"fill nil", func() (*Vec, error) { return (*Vec)(nil).FillChecked(nil) }, ErrEmpty},
+		{"add", func() (*Vec, error) { return v.AddChecked(v, mod) }, nil},
+		{"add zero value", func() (*Vec, error) { return v.AddChecked(&Vec{}, mod) }, ErrEmpty},
+		{"add wrong size", func() (*Vec, error) { return v.AddChecked(NewVecWith(4, backend), mod) }, ErrDimensionMismatch},
+		{"sub nil", func() (*Vec, error) { return (*Vec)(nil).SubChecked(v, mod) }, ErrEmpty},
+		{"sub wrong size", func() (*Vec, error) { return v.SubChecked(NewVecWith(2, backend), mod) }, ErrDimensionMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.op()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if (got == nil) != (tt.wantErr != nil) {
+				t.Errorf("result = %v with error %v", got, err)
+			}
+		})
+	}
+
+	t.Run("invalid modulus", func(t *testing.T) {
+		for _, mod := range []*big.Int{nil, big.NewInt(0), big.NewInt(-3)} {
+			if _, err := v.AddChecked(v, mod); err == nil {
+				t.Errorf("AddChecked() with modulus %v succeeded, want error", mod)
+			}
+		}
+	})
+
+	t.Run("unknown backend", func(t *testing.T) {
+		if _, err := NewVecChecked(3, Backend(99)); err == nil {
+			t.Errorf("NewVecChecked() with unknown backend succeeded, want error")
+		}
+	})
+}
+
+func TestSetOps(t *testing.T) { forEachBackend(t, testSetOps) }
+
+func testSetOps(t *testing.T, backend Backend) {
+	mod := big.NewInt(97)
+	x := NewVecWith(4, backend).Fill([]int64{1, 50, 96, 0})
+	y := NewVecWith(4, backend).Fill([]int64{96, 60, 3, 0})
+	c := big.NewInt(5)
+
+	tests := []struct {
+		name     string
+		op       func(z *Vec) *Vec
+		expected *Vec
+	}{
+		{"add", func(z *Vec) *Vec { return z.SetAdd(x, y, mod) }, x.Add(y, mod)},
+		{"sub", func(z *Vec) *Vec { return z.SetSub(x, y, mod) }, x.Sub(y, mod)},
+		{"scale", func(z *Vec) *Vec { return z.SetScale(x, c, mod) }, x.Scale(c, mod)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// a destination holding other values is overwritten
+			z := NewVecWith(4, backend).Fill([]int64{7, 7, 7, 7})
+			if got := tt.op(z); got != z || !equalEntries(z, tt.expected) {
+				t.Errorf("result = %v, want %v stored in the receiver", got, tt.expected)
+			}
+		})
+	}
+
+	t.Run("aliasing", func(t *testing.T) {
+		z := NewVecWith(4, backend).Fill([]int64{1, 50, 96, 0})
+		if z.SetAdd(z, y, mod); !equalEntries(z, x.Add(y, mod)) {
+			t.Errorf("SetAdd(z, y) = %v, want %v", z, x.Add(y, mod))
+		}
+		z = NewVecWith(4, backend).Fill([]int64{96, 60, 3, 0})
+		if z.SetSub(x, z, mod); !equalEntries(z, x.Sub(y, mod)) {
+			t.Errorf("SetSub(x, z) = %v, want %v", z, x.Sub(y, mod))
+		}
+	})
+
+	t.Run("invalid operands", func(t *testing.T) {
+		assertPanic(t, func() { NewVecWith(3, backend).SetAdd(x, y, mod) }, "SetAdd() into the wrong size did not panic")
+		assertPanic(t, func() { NewVecWith(4, backend).SetSub(x, NewVecWith(3, backend), mod) }, "SetSub() of mismatched sizes did not panic")
+		assertPanic(t, func() { (*Vec)(nil).SetScale(x, c, mod) }, "SetScale() into nil did not panic")
+	})
+}
+
+// equalEntries reports whether v1 and v2 hold the same values, regardless of how they are stored
+func equalEntries(v1, v2 *Vec) bool {
+	if v1.size != v2.size {
+		return false
+	}
+	for i := range v1.size {
+		if v1.Get(i).Cmp(v2.Get(i)) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSetOpsAllocs(t *testing.T) {
+	q := big.NewInt(1 << 32)
+	x := NewVecWith(256, Uint32Backend).FillRandom(q)
+	z := NewVecWith(256, Uint32Backend)
+	m := NewMatWith(256, 256, Uint32Backend).FillRandom(q)
+	tests := []struct {
+		name string
+		op   func()
+	}{
+		{"SetAdd", func() { z.SetAdd(z, x, q) }},
+		{"SetSub", func() { z.SetSub(z, x, q) }},
+		{"SetVecMul", func() { z.SetVecMul(m, x, q) }},
+	}
+	for _, tt := range tests {
+		if allocs := testing.AllocsPerRun(10, tt.op); allocs != 0 {
+			t.Errorf("%v allocated %v times per call, want 0", tt.name, allocs)
+		}
+	}
+}
+
+// BenchmarkVecOps compares the allocating vector operations against their in-place counterparts
+func BenchmarkVecOps(b *testing.B) {
+	q := big.NewInt(1 << 32)
+	for _, backend := range []Backend{BigBackend, Uint32Backend} {
+		x := NewVecWith(1024, backend).FillRandom(q)
+		y := NewVecWith(1024, backend).FillRandom(q)
+		z := NewVecWith(1024, backend)
+		b.Run(backend.String()+"/Add", func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				x.Add(y, q)
+			}
+		})
+		b.Run(backend.String()+"/SetAdd", func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				z.SetAdd(x, y, q)
+			}
+		})
+		b.Run(backend.String()+"/Scale", func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				x.Scale(y.Get(0), q)
+			}
+		})
+		b.Run(backend.String()+"/SetScale", func(b *testing.B) {
+			b.ReportAllocs()
+			c := y.Get(0)
+			for b.Loop() {
+				z.SetScale(x, c, q)
+			}
+		})
+	}
+}
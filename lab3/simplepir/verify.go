@@ -0,0 +1,148 @@
+package simplepir
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+	"slices"
+)
+
+// ErrInconsistentAnswer is returned when an answer does not decode to the database the server committed to,
+// so the server answered from a different database or tampered with the answer
+var ErrInconsistentAnswer = errors.New("answer does not match the committed database")
+
+// columnDomain separates column hashes from other uses of SHA-256
+const columnDomain = "simplepir column"
+
+// ColumnHash is the SHA-256 digest of one column of the database, see [Commitment]
+type ColumnHash [sha256.Size]byte
+
+// Commitment binds a server to its database, for clients that do not trust the server to answer honestly
+//
+// A query for (row, col) lets the client decode the whole of column col (see [pirRecoverColumn]), so the client
+// hashes the decoded column and compares it to Columns[col]. A server answering from any other database, or
+// tampering with the answer, changes the decoded column and is caught. The client holds the hash of every column
+// rather than a Merkle path to one, as asking for the path would reveal col.
+//
+// [Commitment.Root] is short enough to publish, so clients can compare it to check they were all committed to the
+// same database. Version is the version of the hint the commitment was made alongside.
+type Commitment struct {
+	Version HintVersion
+	Columns []ColumnHash
+}
+
+// columnHash digests column col of the database, given its values from top to bottom
+//
+// the index is included so that a server cannot answer a query for one column from another column
+func columnHash(col int, values []uint64) ColumnHash {
+	h := sha256.New()
+	h.Write([]byte(columnDomain))
+	buf := binary.LittleEndian.AppendUint64(nil, uint64(col))
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(len(values)))
+	for _, v := range values {
+		buf = binary.LittleEndian.AppendUint64(buf, v)
+	}
+	h.Write(buf)
+	return ColumnHash(h.Sum(nil))
+}
+
+// column returns the values of column j of db
+func column(db *Mat, j int) []uint64 {
+	values := make([]uint64, db.rows)
+	for i := range db.rows {
+		values[i] = db.Get(i, j).Uint64()
+	}
+	return values
+}
+
+// commit hashes every column of db, for the hint with the given version
+func commit(db *Mat, version HintVersion) *Commitment {
+	c := &Commitment{Version: version, Columns: make([]ColumnHash, db.cols)}
+	for j := range db.cols {
+		c.Columns[j] = columnHash(j, column(db, j))
+	}
+	return c
+}
+
+// clone returns a deep copy of c
+func (c *Commitment) clone() *Commitment {
+	return &Commitment{Version: c.Version, Columns: slices.Clone(c.Columns)}
+}
+
+// Root returns the Merkle root over the column hashes
+//
+// The tree is built as in RFC 6962, with leaves hashed as SHA-256(0x00 | column hash) and nodes as
+// SHA-256(0x01 | left | right), splitting n leaves at the largest power of two below n
+func (c *Commitment) Root() [sha256.Size]byte {
+	return merkleRoot(c.Columns)
+}
+
+// merkleRoot returns the root of the RFC 6962 Merkle tree over leaves, the hash of the empty string if there are none
+func merkleRoot(leaves []ColumnHash) [sha256.Size]byte {
+	switch len(leaves) {
+	case 0:
+		return sha256.Sum256(nil)
+	case 1:
+		return sha256.Sum256(append([]byte{0}, leaves[0][:]...))
+	}
+	k := 1 << (bits.Len(uint(len(leaves)-1)) - 1)
+	left, right := merkleRoot(leaves[:k]), merkleRoot(leaves[k:])
+	node := append([]byte{1}, left[:]...)
+	return sha256.Sum256(append(node, right[:]...))
+}
+
+// verify returns an error wrapping [ErrInconsistentAnswer] if values are not column col of the committed database
+func (c *Commitment) verify(col int, values []uint64) error {
+	if columnHash(col, values) != c.Columns[col] {
+		return fmt.Errorf("%w: column %v does not match its hash", ErrInconsistentAnswer, col)
+	}
+	return nil
+}
+
+// Commitment returns the commitment to the current database, computing it on first use
+//
+// Updates rehash only the columns they change
+func (p *PreparedDB) Commitment() *Commitment {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.commitment == nil {
+		p.commitment = commit(p.db, p.version)
+	}
+	return p.commitment.clone() // defensive clone
+}
+
+// SetCommitment turns on verification of the server's answers against commitment, which the server sends
+// alongside the hint
+//
+// Every later recovery decodes the whole of the queried column and returns an error wrapping [ErrInconsistentAnswer]
+// if its hash does not match. Callers should also check [Commitment.Root] against a root published for the database.
+// Returns an error wrapping [ErrStaleHint] if the commitment was made for a different hint than the client's.
+//
+// [Client.ApplyDiff] leaves the commitment behind, so set the commitment for the new version after applying a diff.
+// There is no equivalent for [DoubleClient], as DoublePIR only decodes the one entry that was queried.
+func (c *Client) SetCommitment(commitment *Commitment) error {
+	if commitment == nil {
+		return fmt.Errorf("commitment must not be nil")
+	}
+	if commitment.Version != c.version {
+		return fmt.Errorf("%w: commitment is for version %v, client has %v", ErrStaleHint, commitment.Version, c.version)
+	}
+	if _, cols := c.params.Shape(); len(commitment.Columns) != cols {
+		return fmt.Errorf("commitment must hold %v column hashes, got %v", cols, len(commitment.Columns))
+	}
+	c.commitment = commitment.clone()
+	return nil
+}
+
+// checkColumn verifies a decoded column against the client's commitment, if it has one
+func (c *Client) checkColumn(col int, values []uint64) error {
+	if c.commitment == nil {
+		return nil
+	}
+	if c.commitment.Version != c.version {
+		return fmt.Errorf("%w: commitment is for version %v, client has %v", ErrStaleHint, c.commitment.Version, c.version)
+	}
+	return c.commitment.verify(col, values)
+}
@@ -0,0 +1,200 @@
+package simplepir
+
+import (
+	"crypto/sha256"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestMerkleRoot(t *testing.T) {
+	leaves := make([]ColumnHash, 5)
+	for i := range leaves {
+		leaves[i] = columnHash(i, []uint64{uint64(i)})
+	}
+	leaf := func(h ColumnHash) [sha256.Size]byte { return sha256.Sum256(append([]byte{0}, h[:]...)) }
+	node := func(l, r [sha256.Size]byte) [sha256.Size]byte {
+		return sha256.Sum256(append(append([]byte{1}, l[:]...), r[:]...))
+	}
+
+	tests := []struct {
+		name     string
+		leaves   []ColumnHash
+		expected [sha256.Size]byte
+	}{
+		{"empty", nil, sha256.Sum256(nil)},
+		{"one leaf", leaves[:1], leaf(leaves[0])},
+		{"two leaves", leaves[:2], node(leaf(leaves[0]), leaf(leaves[1]))},
+		{"three leaves", leaves[:3], node(node(leaf(leaves[0]), leaf(leaves[1])), leaf(leaves[2]))},
+		{"five leaves", leaves, node(
+			node(node(leaf(leaves[0]), leaf(leaves[1])), node(leaf(leaves[2]), leaf(leaves[3]))),
+			leaf(leaves[4]),
+		)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Commitment{Columns: tt.leaves}
+			if got := c.Root(); got != tt.expected {
+				t.Errorf("Root() = %x, want %x", got, tt.expected)
+			}
+		})
+	}
+
+	// swapping two columns changes the root, as well as both column hashes
+	swapped := []ColumnHash{leaves[1], leaves[0]}
+	if merkleRoot(swapped) == merkleRoot(leaves[:2]) {
+		t.Errorf("merkleRoot() is unchanged by swapping leaves")
+	}
+	if columnHash(0, []uint64{1, 2}) == columnHash(1, []uint64{1, 2}) {
+		t.Errorf("columnHash() does not depend on the column index")
+	}
+}
+
+func TestVerifiedRecovery(t *testing.T) { forEachBackend(t, testVerifiedRecovery) }
+
+func testVerifiedRecovery(t *testing.T, backend Backend) {
+	params := Params{N: 16, Q: big.NewInt(1 << 32), P: big.NewInt(256), Rows: 6, Cols: 10, Backend: backend}
+	db := NewMatWith(6, 10, backend).FillRandom(params.P)
+	server, err := NewServer(params, db)
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	client, err := NewClientFromSeed(params, server.Seed(), server.Hint())
+	if err != nil {
+		t.Fatalf("NewClientFromSeed() error = %v", err)
+	}
+	commitment := server.Commitment()
+	if err := client.SetCommitment(commitment); err != nil {
+		t.Fatalf("SetCommitment() error = %v", err)
+	}
+	if commitment.Root() != commit(db, server.Version()).Root() {
+		t.Errorf("Commitment() does not commit to the database")
+	}
+
+	// tampered returns db with the entry at (row, col) incremented
+	tampered := func(row, col int) *Mat {
+		m := db.clone()
+		m.Set(row, col, new(big.Int).Mod(new(big.Int).Add(m.Get(row, col), big.NewInt(1)), params.P))
+		return m
+	}
+	const row, col = 2, 7
+	tests := []struct {
+		name    string
+		answer  func(qu *Vec) *Vec
+		wantErr error
+	}{
+		{"honest", func(qu *Vec) *Vec { ans, _ := server.Answer(qu); return ans }, nil},
+		{"other entry in the queried column", func(qu *Vec) *Vec { ans, _ := pirAnswer(tampered(4, col), qu, params); return ans }, ErrInconsistentAnswer},
+		{"entry in another column", func(qu *Vec) *Vec { ans, _ := pirAnswer(tampered(row, 0), qu, params); return ans }, ErrInconsistentAnswer},
+		{"shifted answer", func(qu *Vec) *Vec {
+			ans, _ := server.Answer(qu)
+			return ans.Add(NewVecWith(6, backend).OneHot(5).Scale(params.delta(), params.Q), params.Q)
+		}, ErrInconsistentAnswer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// tampering with another column only adds noise, which decodes to the right entry with probability 1/p,
+			// so retry with fresh queries and only fail if every one misses the tampering
+			var err error
+			for range 4 {
+				st, qu, _ := client.Query(row, col)
+				var got uint64
+				got, err = client.Recover(st, tt.answer(qu))
+				if err == nil && got != db.Get(row, col).Uint64() {
+					t.Errorf("Recover() = %v, want %v", got, db.Get(row, col))
+				}
+				if err != nil || tt.wantErr == nil {
+					break
+				}
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Recover() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("batch", func(t *testing.T) {
+		st, qus, _ := client.QueryBatch([]Cell{{0, 0}, {row, col}})
+		ans, _ := server.AnswerBatch(qus)
+		if _, err := client.RecoverBatch(st, ans); err != nil {
+			t.Errorf("RecoverBatch() error = %v", err)
+		}
+		ans, _ = pirAnswerBatch(tampered(0, col), qus, params)
+		if _, err := client.RecoverBatch(st, ans); !errors.Is(err, ErrInconsistentAnswer) {
+			t.Errorf("RecoverBatch() from tampered database error = %v, want %v", err, ErrInconsistentAnswer)
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		// a value other than the current one, so that the version changes
+		value := tampered(row, col).Get(row, col)
+		diff, err := server.Update(row, col, value)
+		if err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+		updated := server.Commitment()
+		if want := commit(server.prep.db, server.Version()); updated.Root() != want.Root() || updated.Version != want.Version {
+			t.Errorf("Commitment() after Update() does not match the updated database")
+		}
+		if err := client.ApplyDiff(diff); err != nil {
+			t.Fatalf("ApplyDiff() error = %v", err)
+		}
+		st, qu, _ := client.Query(row, col)
+		ans, _ := server.Answer(qu)
+		if _, err := client.Recover(st, ans); !errors.Is(err, ErrStaleHint) {
+			t.Errorf("Recover() with old commitment error = %v, want %v", err, ErrStaleHint)
+		}
+		if err := client.SetCommitment(updated); err != nil {
+			t.Fatalf("SetCommitment() error = %v", err)
+		}
+		if got, err := client.Recover(st, ans); err != nil || got != value.Uint64() {
+			t.Errorf("Recover() after update = %v, %v, want %v", got, err, value)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		if err := client.SetCommitment(nil); err == nil {
+			t.Errorf("SetCommitment(nil) succeeded, want error")
+		}
+		if err := client.SetCommitment(commitment); !errors.Is(err, ErrStaleHint) {
+			t.Errorf("SetCommitment() for old version error = %v, want %v", err, ErrStaleHint)
+		}
+		short := server.Commitment()
+		short.Columns = short.Columns[1:]
+		if err := client.SetCommitment(short); err == nil {
+			t.Errorf("SetCommitment() with too few columns succeeded, want error")
+		}
+	})
+}
+
+func TestVerifiedRecords(t *testing.T) {
+	records := [][]byte{[]byte("alpha"), []byte("bravo"), []byte("chaos"), []byte("delta")}
+	params := Params{N: 16, Q: big.NewInt(1 << 32), P: big.NewInt(991), Backend: Uint32Backend}
+	db, err := NewDatabase(records, params.P)
+	if err != nil {
+		t.Fatalf("NewDatabase() error = %v", err)
+	}
+	params.SqrtN = db.Layout().SqrtN
+	server, _ := NewServer(params, db.Mat())
+	client, _ := NewClientFromSeed(params, server.Seed(), server.Hint())
+	if err := client.SetCommitment(server.Commitment()); err != nil {
+		t.Fatalf("SetCommitment() error = %v", err)
+	}
+
+	st, qus, _ := client.QueryRecord(db.Layout(), 2)
+	answers := make([]*Vec, len(qus))
+	for k, qu := range qus {
+		answers[k], _ = server.Answer(qu)
+	}
+	if got, err := client.RecoverRecord(st, answers); err != nil || string(got) != "chaos" {
+		t.Errorf("RecoverRecord() = %q, %v, want %q", got, err, "chaos")
+	}
+
+	// the last answer comes from a database where another record was changed
+	other := db.Mat().clone()
+	other.Set(0, 0, new(big.Int).Add(other.Get(0, 0), big.NewInt(1)))
+	answers[len(answers)-1], _ = pirAnswer(other, qus[len(qus)-1], params)
+	if _, err := client.RecoverRecord(st, answers); !errors.Is(err, ErrInconsistentAnswer) {
+		t.Errorf("RecoverRecord() error = %v, want %v", err, ErrInconsistentAnswer)
+	}
+}